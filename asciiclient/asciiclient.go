@@ -1,199 +1,50 @@
 package asciiclient
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
 	"math"
-	"net"
-	"reflect"
-	"time"
 
 	"github.com/clagraff/pitch/comms/requests"
-	"github.com/clagraff/pitch/comms/responses"
 	"github.com/clagraff/pitch/entities"
 	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/keybindings"
 	"github.com/clagraff/pitch/logging"
 	"github.com/go-errors/errors"
 	termbox "github.com/nsf/termbox-go"
 	uuid "github.com/satori/go.uuid"
 )
 
-var delimiter = byte('\n')
-
 const chanBuffSize = 20
 
-type connHandshake struct {
-	ID        uuid.UUID `json:"id"`
-	Subscribe bool      `json:"subscribe"`
-}
-
-func handshake(id uuid.UUID, conn net.Conn, subscribe bool) error {
-	h := connHandshake{
-		ID:        id,
-		Subscribe: subscribe,
-	}
-
-	logger, closeLog := logging.Logger("asciiclient.handshake")
-	defer closeLog()
-
-	// Do handshake
-	logger.Println("starting handshake for ID:", id.String())
-
-	bites, err := json.Marshal(h)
-	if err != nil {
-		return errors.New(err)
-	}
-
-	logger.Println("sending client id for handshake")
-
-	conn.Write(bites)
-	conn.Write([]byte{delimiter})
-	conn.SetDeadline(time.Now().Add(2 * time.Minute))
-
-	logger.Println("waiting for handshake response on:", id.String())
-
-	message, err := bufio.NewReader(conn).ReadBytes(delimiter)
-	if err != nil {
-		return errors.New(err)
-	}
-	message = message[:len(message)-1]
-
-	validationID := uuid.UUID{}
-	err = json.Unmarshal(message, &validationID)
-	if err != nil {
-		return errors.New(err)
-	}
-
-	logger.Println("validating handshake response for:", id.String())
-	if !uuid.Equal(id, validationID) {
-		logger.Println("handshake failed due to mismatched UUIDs on", id.String())
-		return errors.New("handshake failed due to mismatched UUIDs")
-	}
-
-	logger.Println("handshake successful with:", id.String())
-	return nil
-}
-
-func ManageRequests(host string, port int, id uuid.UUID) (chan<- requests.Request, error) {
-	logger, closeLog := logging.Logger("asciiclient.ManageRequests")
-	defer closeLog()
-
-	reqs := make(chan requests.Request, chanBuffSize)
-
-	logger.Printf("dialing server %s:%d\n", host, port)
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
-	if err != nil {
-		return reqs, errors.New(err)
-	}
-
-	err = handshake(id, conn, false)
-	if err != nil {
-		logger.Println("handshake failed")
-		return reqs, errors.New(err)
-	}
-
-	go func(c net.Conn, r <-chan requests.Request) {
-		logger, closeLog := logging.Logger("asciiclient.ManageRequests.goFunc")
-		defer closeLog()
-		defer conn.Close()
-
-		logger.Println("awaiting requests to send")
-		for req := range r {
-			bites, err := requests.Marshal(req)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
-			}
-
-			logger.Println("sending request:", reflect.TypeOf(req))
-			logger.Println("request json:", string(bites))
-
-			conn.Write(bites)
-			conn.Write([]byte{delimiter})
-			conn.SetDeadline(time.Now().Add(2 * time.Minute))
-
-			logger.Println("request sent:", reflect.TypeOf(req))
-		}
-	}(conn, reqs)
+// rememberedForeground is the colour remembered entities, which are out of
+// view, are drawn in.
+const rememberedForeground = termbox.ColorBlue
 
-	return reqs, nil
-}
-
-func ManageResponses(host string, port int, id uuid.UUID) (<-chan entities.World, error) {
-	logger, closeLog := logging.Logger("asciiclient.ManageResponses")
+// Run connects to the server, authenticating with the account name and
+// password, and plays as the entity with the specified ID. What the player
+// remembers of the map is loaded from, and saved back to, the memory
+// directory.
+func Run(host string, port int, id uuid.UUID, name, password, memoryDir string) error {
+	logger, closeLog := logging.Logger("asciiclient.Run")
 	defer closeLog()
 
-	worlds := make(chan entities.World, chanBuffSize)
-
-	logger.Printf("dialing server %s:%d\n", host, port)
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
-	if err != nil {
-		return worlds, errors.New(err)
-	}
-
-	err = handshake(id, conn, true)
+	remembered, err := LoadMemory(memoryDir, id)
 	if err != nil {
-		logger.Println("handshake failed")
-		return worlds, errors.New(err)
+		return err
 	}
 
-	go func(c net.Conn, w chan<- entities.World) {
-		logger, closeLog := logging.Logger("asciiclient.ManageResponses.goFunc")
-		defer closeLog()
-		defer conn.Close()
-
-		world := entities.MakeWorld()
-
-		buff := bufio.NewReader(conn)
-		for {
-			logger.Println("awaiting responses")
-			message, err := buff.ReadBytes(delimiter)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
-			}
-			conn.SetDeadline(time.Now().Add(2 * time.Minute))
-			message = message[:len(message)-1]
-			logger.Println("unmarshalling json response")
-
-			resp, err := responses.Unmarshal(message)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
-			}
-
-			logger.Println("applying response:", reflect.TypeOf(resp))
-			world, err = resp.Apply(world)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
-			}
-			logger.Println("applied response:", reflect.TypeOf(resp))
+	reqs, worlds, messages, err := Connect(host, port, id, name, password, remembered)
+	if err == ErrLegacyServer {
+		logger.Println("falling back to legacy protocol")
 
-			logger.Println("sending world into worldChan")
-			w <- world
-			logger.Println("world sent into worldChan successfully")
+		var token string
+		reqs, token, err = ManageRequests(host, port, id, name, password)
+		if err != nil {
+			return err
 		}
-	}(conn, worlds)
-
-	return worlds, nil
-}
 
-func Run(host string, port int, id uuid.UUID) error {
-	logger, closeLog := logging.Logger("asciiclient.Run")
-	defer closeLog()
-
-	reqs, err := ManageRequests(host, port, id)
-	if err != nil {
-		return err
+		worlds, messages, err = ManageResponses(host, port, id, token, remembered)
 	}
-
-	worlds, err := ManageResponses(host, port, id)
 	if err != nil {
 		return err
 	}
@@ -218,33 +69,34 @@ func Run(host string, port int, id uuid.UUID) error {
 	go asyncEventPoll(events)
 	var ev termbox.Event
 
-	logger.Println("emitting initial view request")
-	reqs <- requests.ViewRequest{ActorID: id}
-	logger.Println("emitted initial view request")
-
-	world := <-worlds
+	// The server sends everything in view once subscribed, and then only
+	// what changes, so the world is kept up to date without polling.
+	logger.Println("awaiting initial view")
 
-	logger.Println("beginning gameplay loop")
-
-	ticker := time.NewTicker(100 * time.Millisecond)
-	quit := make(chan struct{})
-	go func(reqs chan<- requests.Request) {
-		for {
-			select {
-			case <-ticker.C:
-				reqs <- requests.ViewRequest{ActorID: id}
+	world, ok := <-worlds
+	if !ok {
+		return errors.New("lost connection to server")
+	}
+	message := ""
 
-			case <-quit:
-				ticker.Stop()
-				return
-			}
+	defer func() {
+		err := SaveMemory(memoryDir, id, world.Remembered)
+		if err != nil {
+			logger.Printf("%s\n", errors.New(err).ErrorStack())
 		}
-	}(reqs)
+	}()
+
+	logger.Println("beginning gameplay loop")
 
 	for {
 		for doLoop := true; doLoop; {
 			select {
-			case world = <-worlds:
+			case w, ok := <-worlds:
+				if !ok {
+					return errors.New("lost connection to server")
+				}
+				world = w
+			case message = <-messages:
 			default:
 				doLoop = false
 				break
@@ -258,7 +110,7 @@ func Run(host string, port int, id uuid.UUID) error {
 			logger.Printf("%s\n", stack)
 			panic(stack)
 		}
-		renderWorld(world, player.ID)
+		renderWorld(world, player.ID, message)
 
 		select {
 		case ev = <-events:
@@ -274,9 +126,8 @@ func Run(host string, port int, id uuid.UUID) error {
 				return nil
 			}
 
-			switch ev.Key {
-			case termbox.KeyArrowUp, termbox.KeyArrowDown, termbox.KeyArrowLeft, termbox.KeyArrowRight:
-				sendMoveRequest(ev.Key, player.ID, reqs)
+			if dir, ok := keybindings.Direction(ev); ok {
+				sendMoveRequest(dir, player.ID, reqs)
 			}
 
 			switch ev.Ch {
@@ -292,13 +143,10 @@ func Run(host string, port int, id uuid.UUID) error {
 			logger.Printf("%s\n", stack)
 			panic(stack)
 		}
-
 	}
-
-	return nil
 }
 
-func renderWorld(world entities.World, playerID uuid.UUID) {
+func renderWorld(world entities.World, playerID uuid.UUID, message string) {
 	logger, closeLog := logging.Logger("asciiclient.renderWorld")
 	defer closeLog()
 
@@ -309,10 +157,17 @@ func renderWorld(world entities.World, playerID uuid.UUID) {
 		panic(stack)
 	}
 
-	for _, e := range world.Objects {
+	// Remembered entities are drawn dimmed, beneath what is in view.
+	for _, e := range world.Remembered.Entities() {
+		if _, ok := world.Objects.FromID(e.ID); !ok {
+			renderCell(e, playerID, true)
+		}
+	}
+	for _, e := range world.Objects.Entities() {
 		logger.Println("Rendering entity:", e)
-		renderCell(e, playerID)
+		renderCell(e, playerID, false)
 	}
+	renderMessage(message)
 
 	err = termbox.Flush()
 	if err != nil {
@@ -337,91 +192,111 @@ func PosDiff(x1, y1, x2, y2 int) int {
 	return sq
 }
 
+// renderMessage draws the message along the bottom line of the screen.
+func renderMessage(message string) {
+	_, height := termbox.Size()
+
+	x := 0
+	for _, ch := range message {
+		termbox.SetCell(x, height-1, ch, termbox.ColorRed, termbox.ColorBlack)
+		x++
+	}
+}
+
 func asyncEventPoll(events chan termbox.Event) {
 	for {
 		events <- termbox.PollEvent()
 	}
 }
 
-func renderCell(entity objects.Entity, playerID uuid.UUID) {
-	logger, closeLog := logging.Logger("asciiclient.renderCell")
+// renderCell draws the entity at its position. Remembered entities are drawn
+// dimmed, as they are out of view.
+func renderCell(entity objects.Entity, playerID uuid.UUID, remembered bool) {
+	pos, ok := entity.Position()
+	if !ok {
+		return
+	}
+
+	passability, _ := entity.Passability()
+
+	glyph := defaultGlyph(entity, passability, playerID)
+	if renderable, ok := entity.Renderable(); ok {
+		glyph = renderable.For(passability)
+	}
+	if remembered {
+		glyph.Foreground = int(rememberedForeground)
+	}
+
+	termbox.SetCell(
+		pos.X,
+		pos.Y,
+		glyph.Character,
+		termbox.Attribute(glyph.Foreground),
+		termbox.Attribute(glyph.Background),
+	)
+}
+
+// defaultGlyph returns the glyph drawn for entities which do not say how they
+// should be rendered.
+func defaultGlyph(entity objects.Entity, passability objects.Passability, playerID uuid.UUID) objects.Glyph {
+	logger, closeLog := logging.Logger("asciiclient.defaultGlyph")
 	defer closeLog()
 
-	ch := '#'
+	glyph := objects.Glyph{
+		Character:  '#',
+		Foreground: int(termbox.ColorWhite),
+		Background: int(termbox.ColorBlack),
+	}
 	if entity.ID == playerID {
-		ch = '@'
+		glyph.Character = '@'
 	}
-	if entity.Passability.Type == objects.Toggleable {
+	if passability.Type == objects.Toggleable {
 		logger.Println("Rendering toggleable entity:", entity)
-		switch entity.Passability.IsOpen {
+		switch passability.IsOpen {
 		case true:
-			ch = '\''
+			glyph.Character = '\''
 		case false:
-			ch = '+'
+			glyph.Character = '+'
 		}
 	}
-	termbox.SetCell(
-		entity.Position.X,
-		entity.Position.Y,
-		ch,
-		termbox.ColorWhite,
-		termbox.ColorBlack,
-	)
+
+	return glyph
 }
 
 func isExitEvent(ev termbox.Event) bool {
 	return ev.Ch == 'q' || ev.Key == termbox.KeyCtrlC || ev.Key == termbox.KeyEsc
 }
 
-func sendMoveRequest(key termbox.Key, actorID uuid.UUID, reqs chan<- requests.Request) {
-	logger, closeLog := logging.Logger("asciiclient.sendMoveRequest")
-	defer closeLog()
-
-	var dir requests.Direction
-
-	switch key {
-	case termbox.KeyArrowUp:
-		dir = requests.North
-	case termbox.KeyArrowDown:
-		dir = requests.South
-	case termbox.KeyArrowLeft:
-		dir = requests.West
-	case termbox.KeyArrowRight:
-		dir = requests.East
-	default:
-		err := fmt.Errorf("invalid direction type")
-		stack := errors.New(err).ErrorStack()
-		logger.Printf("%s\n", stack)
-		panic(stack)
-	}
-
+func sendMoveRequest(dir requests.Direction, actorID uuid.UUID, reqs chan<- requests.Request) {
 	reqs <- requests.MoveRequest{ActorID: actorID, Direction: dir}
-	reqs <- requests.ViewRequest{ActorID: actorID}
 }
 
-func tryGetTogglable(targets []objects.Entity) (objects.Entity, bool) {
+func tryGetTogglable(targets []objects.Entity) (objects.Entity, bool, bool) {
 	logger, closeLog := logging.Logger("asciiclient.tryGetTogglable")
 	defer closeLog()
 
 	for _, e := range targets {
-		if e.Passability.Type == objects.Toggleable {
+		if passability, ok := e.Passability(); ok && passability.Type == objects.Toggleable {
 			logger.Println("entity:", e.ID.String(), "can be toggled")
-			logger.Println("entity:", e.ID.String(), "is open?", e.Passability.IsOpen)
-			logger.Printf("%p\n", e)
-			return e, e.Passability.IsOpen
+			logger.Println("entity:", e.ID.String(), "is open?", passability.IsOpen)
+			return e, passability.IsOpen, true
 		}
 		logger.Println("entity:", e.ID.String(), "is not toggleable")
 	}
 
-	return objects.Entity{}, false
+	return objects.Entity{}, false, false
 }
 
 func sendToggleRequest(world entities.World, actor objects.Entity, reqs chan<- requests.Request) {
 	logger, closeLog := logging.Logger("asciiclient.sendToggleRequest")
 	defer closeLog()
 
-	x := actor.Position.X
-	y := actor.Position.Y
+	pos, ok := actor.Position()
+	if !ok {
+		return
+	}
+	x := pos.X
+	y := pos.Y
 
 	down := world.Objects.FromXY(x, y+1)
 	up := world.Objects.FromXY(x, y-1)
@@ -430,8 +305,8 @@ func sendToggleRequest(world entities.World, actor objects.Entity, reqs chan<- r
 
 	directions := [][]objects.Entity{down, up, left, right}
 	for _, direction := range directions {
-		target, isOpen := tryGetTogglable(direction)
-		if target != (objects.Entity{}) {
+		target, isOpen, ok := tryGetTogglable(direction)
+		if ok {
 			if isOpen {
 				reqs <- requests.CloseRequest{ActorID: actor.ID, TargetID: target.ID}
 				logger.Println("Open; closing target")
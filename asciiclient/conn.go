@@ -0,0 +1,524 @@
+package asciiclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/codec"
+	"github.com/clagraff/pitch/comms/protocol"
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/logging"
+)
+
+// Connection policy; these mirror the server defaults so that neither side
+// idles the other out.
+const (
+	idleTimeout       = 2 * time.Minute
+	heartbeatInterval = 30 * time.Second
+)
+
+// requiredRequests are the request types the server must accept for the
+// client to be playable.
+var requiredRequests = []string{"MoveRequest"}
+
+// requiredResponses are the response types the server must send for the
+// client to keep its world in sync without polling.
+var requiredResponses = []string{"EntityEnteredView", "EntityChanged", "EntityLeftView"}
+
+// preferredCodecs are the codecs the client can use, most preferred first.
+var preferredCodecs = []string{codec.Binary.Name(), codec.JSON.Name()}
+
+// ErrLegacyServer is returned by Connect when the server only supports the
+// legacy two-connection protocol.
+var ErrLegacyServer = errors.New("server does not support multiplexed connections")
+
+// address returns the address of the server at the host and port, bracketing
+// IPv6 hosts.
+func address(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// handshake sends the handshake to the server, advertising every request and
+// response type the client understands, and returns the server's reply.
+func handshake(h protocol.Handshake, conn net.Conn, reader *bufio.Reader) (protocol.HandshakeReply, error) {
+	logger, closeLog := logging.Logger("asciiclient.handshake")
+	defer closeLog()
+
+	reply := protocol.HandshakeReply{}
+	h.Requests = requests.Names()
+	h.Responses = responses.Names()
+
+	// Do handshake
+	logger.Println("starting handshake for ID:", h.ID.String())
+
+	bites, err := json.Marshal(h)
+	if err != nil {
+		return reply, errors.New(err)
+	}
+
+	logger.Println("sending client id for handshake")
+
+	conn.SetDeadline(time.Now().Add(idleTimeout))
+	_, err = conn.Write(append(bites, protocol.Delimiter))
+	if err != nil {
+		return reply, errors.New(err)
+	}
+
+	logger.Println("waiting for handshake response on:", h.ID.String())
+
+	message, err := reader.ReadBytes(protocol.Delimiter)
+	if err != nil {
+		return reply, errors.New(err)
+	}
+	message = message[:len(message)-1]
+
+	err = json.Unmarshal(message, &reply)
+	if err != nil {
+		return reply, errors.New(err)
+	}
+
+	logger.Println("validating handshake response for:", h.ID.String())
+	if reply.Error != "" {
+		logger.Println("handshake refused:", reply.Error)
+		return reply, errors.Errorf("handshake refused: %s", reply.Error)
+	}
+	if !uuid.Equal(h.ID, reply.ID) {
+		logger.Println("handshake failed due to mismatched UUIDs on", h.ID.String())
+		return reply, errors.New("handshake failed due to mismatched UUIDs")
+	}
+
+	missing := protocol.Missing(reply.Requests, requiredRequests)
+	if len(missing) > 0 {
+		logger.Println("server does not support required requests:", missing)
+		return reply, errors.Errorf("server does not support required request types: %s", strings.Join(missing, ", "))
+	}
+
+	missing = protocol.Missing(reply.Responses, requiredResponses)
+	if len(missing) > 0 {
+		logger.Println("server does not support required responses:", missing)
+		return reply, errors.Errorf("server does not support required response types: %s", strings.Join(missing, ", "))
+	}
+
+	logger.Println("handshake successful with:", h.ID.String())
+	return reply, nil
+}
+
+// pendingRequests keeps track of requests which have been sent, but not yet
+// responded to, by their correlation ID.
+type pendingRequests struct {
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]pendingRequest
+}
+
+type pendingRequest struct {
+	Type string
+	Sent time.Time
+}
+
+// Add records the request as sent, and returns its correlation ID.
+func (p *pendingRequests) Add(req requests.Request) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name, _ := requests.NameOf(req)
+
+	p.nextID++
+	p.pending[p.nextID] = pendingRequest{
+		Type: name,
+		Sent: time.Now(),
+	}
+
+	return p.nextID
+}
+
+// Resolve removes and returns the request with the specified correlation ID.
+func (p *pendingRequests) Resolve(id uint64) (pendingRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	req, ok := p.pending[id]
+	delete(p.pending, id)
+
+	return req, ok
+}
+
+// Connect authenticates with the server using the account name and password,
+// over a single connection shared by requests and responses. It returns a
+// channel of requests to send for the specified ID, a channel of the resulting
+// worlds and a channel of messages to show the player. The worlds start out
+// remembering the provided static entities.
+//
+// If the server only supports the legacy protocol, ErrLegacyServer is
+// returned and ManageRequests and ManageResponses should be used instead.
+func Connect(host string, port int, id uuid.UUID, name, password string, remembered objects.Collection) (chan<- requests.Request, <-chan entities.World, <-chan string, error) {
+	logger, closeLog := logging.Logger("asciiclient.Connect")
+	defer closeLog()
+
+	reqs := make(chan requests.Request, chanBuffSize)
+	worlds := make(chan entities.World, chanBuffSize)
+	messages := make(chan string, chanBuffSize)
+
+	logger.Println("dialing server", address(host, port))
+	conn, err := net.Dial("tcp", address(host, port))
+	if err != nil {
+		return reqs, worlds, messages, errors.New(err)
+	}
+
+	buff := bufio.NewReader(conn)
+	h := protocol.Handshake{
+		ID:       id,
+		Version:  protocol.MultiplexVersion,
+		Codecs:   preferredCodecs,
+		Name:     name,
+		Password: password,
+	}
+	reply, err := handshake(h, conn, buff)
+	if err != nil {
+		logger.Println("handshake failed")
+		conn.Close()
+		return reqs, worlds, messages, errors.New(err)
+	}
+	if reply.Version < protocol.MultiplexVersion {
+		logger.Println("server negotiated protocol version:", reply.Version)
+		conn.Close()
+		return reqs, worlds, messages, ErrLegacyServer
+	}
+
+	c := codec.JSON
+	if reply.Codec != "" {
+		var ok bool
+		c, ok = codec.FromName(reply.Codec)
+		if !ok {
+			logger.Println("server negotiated unknown codec:", reply.Codec)
+			conn.Close()
+			return reqs, worlds, messages, errors.Errorf("server negotiated unknown codec: %s", reply.Codec)
+		}
+	}
+	logger.Println("using codec:", c.Name())
+
+	pending := &pendingRequests{pending: make(map[uint64]pendingRequest)}
+
+	go func(conn net.Conn, r <-chan requests.Request) {
+		logger, closeLog := logging.Logger("asciiclient.Connect.writer")
+		defer closeLog()
+		defer conn.Close()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		logger.Println("awaiting requests to send")
+		for {
+			var message []byte
+
+			select {
+			case req, ok := <-r:
+				if !ok {
+					logger.Println("request channel closed; disconnecting")
+					return
+				}
+
+				bites, err := requests.Encode(c, req)
+				if err == nil {
+					bites, err = c.Marshal(protocol.Envelope{
+						Direction: protocol.RequestDirection,
+						ID:        pending.Add(req),
+						Payload:   codec.RawMessage(bites),
+					})
+				}
+				if err != nil {
+					stack := errors.New(err).ErrorStack()
+					logger.Printf("%s\n", stack)
+					continue
+				}
+
+				logger.Println("sending request:", reflect.TypeOf(req))
+				message = bites
+
+			case <-heartbeat.C:
+				logger.Println("sending heartbeat")
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(idleTimeout))
+			err := c.WriteFrame(conn, message)
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				return
+			}
+		}
+	}(conn, reqs)
+
+	go func(conn net.Conn, w chan<- entities.World, m chan<- string) {
+		logger, closeLog := logging.Logger("asciiclient.Connect.reader")
+		defer closeLog()
+		defer conn.Close()
+		defer close(w)
+
+		world := entities.MakeWorld()
+		world.Remembered = remembered.Clone()
+
+		for {
+			logger.Println("awaiting responses")
+			conn.SetReadDeadline(time.Now().Add(idleTimeout))
+			message, err := c.ReadFrame(buff)
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				m <- "lost connection to server"
+				return
+			}
+			if len(message) == 0 {
+				logger.Println("received heartbeat")
+				continue
+			}
+
+			env := protocol.Envelope{}
+			err = c.Unmarshal(message, &env)
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				continue
+			}
+
+			if req, ok := pending.Resolve(env.ID); ok {
+				logger.Printf("received response to %s #%d after %s\n", req.Type, env.ID, time.Since(req.Sent))
+			}
+
+			resp, err := responses.Decode(c, env.Payload)
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				continue
+			}
+
+			world = applyResponse(logger, world, resp, w, m)
+		}
+	}(conn, worlds, messages)
+
+	return reqs, worlds, messages, nil
+}
+
+// ManageRequests authenticates with the server using the account name and
+// password, and returns a channel of requests to send for the specified ID
+// along with the session token for any further connections.
+//
+// Deprecated: ManageRequests uses the legacy two-connection protocol; use
+// Connect instead.
+func ManageRequests(host string, port int, id uuid.UUID, name, password string) (chan<- requests.Request, string, error) {
+	logger, closeLog := logging.Logger("asciiclient.ManageRequests")
+	defer closeLog()
+
+	reqs := make(chan requests.Request, chanBuffSize)
+
+	logger.Println("dialing server", address(host, port))
+	conn, err := net.Dial("tcp", address(host, port))
+	if err != nil {
+		return reqs, "", errors.New(err)
+	}
+
+	h := protocol.Handshake{
+		ID:       id,
+		Version:  protocol.LegacyVersion,
+		Name:     name,
+		Password: password,
+	}
+	reply, err := handshake(h, conn, bufio.NewReader(conn))
+	if err != nil {
+		logger.Println("handshake failed")
+		conn.Close()
+		return reqs, "", errors.New(err)
+	}
+
+	go func(c net.Conn, r <-chan requests.Request) {
+		logger, closeLog := logging.Logger("asciiclient.ManageRequests.goFunc")
+		defer closeLog()
+		defer conn.Close()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		logger.Println("awaiting requests to send")
+		for {
+			var message []byte
+
+			select {
+			case req, ok := <-r:
+				if !ok {
+					logger.Println("request channel closed; disconnecting")
+					return
+				}
+
+				bites, err := requests.Marshal(req)
+				if err != nil {
+					stack := errors.New(err).ErrorStack()
+					logger.Printf("%s\n", stack)
+					continue
+				}
+
+				logger.Println("sending request:", reflect.TypeOf(req))
+				logger.Println("request json:", string(bites))
+				message = bites
+
+			case <-heartbeat.C:
+				logger.Println("sending heartbeat")
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(idleTimeout))
+			_, err := conn.Write(append(message, protocol.Delimiter))
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				return
+			}
+		}
+	}(conn, reqs)
+
+	return reqs, reply.Token, nil
+}
+
+// ManageResponses subscribes to responses for the specified ID using the
+// session token from ManageRequests, and returns a channel of the resulting
+// worlds and a channel of messages to show the player, such as why one of
+// their requests failed. The worlds start out remembering the provided static
+// entities.
+//
+// Deprecated: ManageResponses uses the legacy two-connection protocol; use
+// Connect instead.
+func ManageResponses(host string, port int, id uuid.UUID, token string, remembered objects.Collection) (<-chan entities.World, <-chan string, error) {
+	logger, closeLog := logging.Logger("asciiclient.ManageResponses")
+	defer closeLog()
+
+	worlds := make(chan entities.World, chanBuffSize)
+	messages := make(chan string, chanBuffSize)
+
+	logger.Println("dialing server", address(host, port))
+	conn, err := net.Dial("tcp", address(host, port))
+	if err != nil {
+		return worlds, messages, errors.New(err)
+	}
+
+	buff := bufio.NewReader(conn)
+	h := protocol.Handshake{
+		ID:        id,
+		Version:   protocol.LegacyVersion,
+		Subscribe: true,
+		Token:     token,
+	}
+	_, err = handshake(h, conn, buff)
+	if err != nil {
+		logger.Println("handshake failed")
+		conn.Close()
+		return worlds, messages, errors.New(err)
+	}
+
+	go func(c net.Conn, w chan<- entities.World, m chan<- string) {
+		logger, closeLog := logging.Logger("asciiclient.ManageResponses.goFunc")
+		defer closeLog()
+		defer conn.Close()
+		defer close(w)
+
+		done := make(chan struct{})
+		defer close(done)
+		go sendHeartbeats(conn, done)
+
+		world := entities.MakeWorld()
+		world.Remembered = remembered.Clone()
+
+		for {
+			logger.Println("awaiting responses")
+			conn.SetReadDeadline(time.Now().Add(idleTimeout))
+			message, err := buff.ReadBytes(protocol.Delimiter)
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				m <- "lost connection to server"
+				return
+			}
+			message = message[:len(message)-1]
+			if len(message) == 0 {
+				logger.Println("received heartbeat")
+				continue
+			}
+			logger.Println("unmarshalling json response")
+
+			resp, err := responses.Unmarshal(message)
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				continue
+			}
+
+			world = applyResponse(logger, world, resp, w, m)
+		}
+	}(conn, worlds, messages)
+
+	return worlds, messages, nil
+}
+
+// applyResponse applies the response to the world, sending the resulting
+// world to w. Error responses are instead sent to m as a message for the
+// player.
+func applyResponse(logger *log.Logger, world entities.World, resp responses.Response, w chan<- entities.World, m chan<- string) entities.World {
+	if errResp, ok := resp.(responses.ErrorResponse); ok {
+		logger.Println("received error response:", errResp.Message)
+		m <- fmt.Sprintf("%s failed: %s", errResp.RequestType, errResp.Message)
+		return world
+	}
+
+	logger.Println("applying response:", reflect.TypeOf(resp))
+	world, err := resp.Apply(world)
+	if err != nil {
+		stack := errors.New(err).ErrorStack()
+		logger.Printf("%s\n", stack)
+		return world
+	}
+	logger.Println("applied response:", reflect.TypeOf(resp))
+
+	// The world is modified in place as responses are applied, so the
+	// receiver is given its own copy.
+	logger.Println("sending world into worldChan")
+	w <- world.Clone()
+	logger.Println("world sent into worldChan successfully")
+
+	return world
+}
+
+// sendHeartbeats periodically writes an empty message to the connection, so
+// the server knows the client is still alive, until done is closed.
+func sendHeartbeats(conn net.Conn, done <-chan struct{}) {
+	logger, closeLog := logging.Logger("asciiclient.sendHeartbeats")
+	defer closeLog()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-heartbeat.C:
+			conn.SetWriteDeadline(time.Now().Add(idleTimeout))
+			_, err := conn.Write([]byte{protocol.Delimiter})
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				return
+			}
+		}
+	}
+}
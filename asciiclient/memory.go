@@ -0,0 +1,70 @@
+package asciiclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/saves"
+)
+
+// memoryPath returns the file the entity's memory is kept in, within the
+// directory.
+func memoryPath(dir string, id uuid.UUID) string {
+	return filepath.Join(dir, id.String()+".json")
+}
+
+// LoadMemory reads the static entities the player of the entity remembers
+// from the directory. Nothing is remembered when the directory is empty, or
+// the entity has no memory yet.
+//
+// Memory is only kept on the machine the client runs on; the server knows
+// nothing of it, so a player connecting from elsewhere starts out remembering
+// nothing.
+func LoadMemory(dir string, id uuid.UUID) (objects.Collection, error) {
+	remembered := objects.MakeCollection()
+	if dir == "" {
+		return remembered, nil
+	}
+
+	data, err := ioutil.ReadFile(memoryPath(dir, id))
+	if os.IsNotExist(err) {
+		return remembered, nil
+	}
+	if err != nil {
+		return remembered, errors.New(err)
+	}
+
+	err = json.Unmarshal(data, &remembered)
+	if err != nil {
+		return remembered, errors.Errorf("invalid memory for %s: %s", id, err)
+	}
+
+	return remembered, nil
+}
+
+// SaveMemory writes the static entities the player of the entity remembers to
+// the directory, creating it if it does not exist. Nothing is written when the
+// directory is empty.
+func SaveMemory(dir string, id uuid.UUID, remembered objects.Collection) error {
+	if dir == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	data, err := json.Marshal(remembered)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return saves.WriteFile(memoryPath(dir, id), append(data, '\n'), 0644)
+}
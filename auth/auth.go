@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/clagraff/pitch/saves"
+)
+
+// DefaultSessionTTL is how long a session remains valid after being created.
+const DefaultSessionTTL = 24 * time.Hour
+
+// tokenSize is the number of random bytes used to generate a session token.
+const tokenSize = 32
+
+// Account represents a player's credentials, and the entities they are
+// allowed to control.
+type Account struct {
+	Name         string      `json:"name"`
+	PasswordHash string      `json:"password_hash"`
+	EntityIDs    []uuid.UUID `json:"entity_ids"`
+}
+
+// NewAccount returns an account for the provided name, with the password
+// hashed, which owns the specified entities.
+func NewAccount(name, password string, ids ...uuid.UUID) (Account, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return Account{}, errors.New(err)
+	}
+
+	a := Account{
+		Name:         name,
+		PasswordHash: string(hash),
+		EntityIDs:    ids,
+	}
+
+	return a, nil
+}
+
+// Owns returns true when the account is allowed to control the entity with
+// the specified UUID.
+func (a Account) Owns(id uuid.UUID) bool {
+	for _, owned := range a.EntityIDs {
+		if uuid.Equal(owned, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Accounts represents a collection of Accounts, keyed by account name.
+type Accounts struct {
+	mapping map[string]Account
+}
+
+// MakeAccounts instantiates a new collection and returns the instance.
+func MakeAccounts() Accounts {
+	a := Accounts{}
+	a.mapping = make(map[string]Account)
+
+	return a
+}
+
+// MarshalJSON marshals the current Accounts as a list (as opposed to a map).
+func (a Accounts) MarshalJSON() ([]byte, error) {
+	l := make([]Account, len(a.mapping))
+	i := 0
+
+	for _, acct := range a.mapping {
+		l[i] = acct
+		i++
+	}
+
+	return json.Marshal(l)
+}
+
+// UnmarshalJSON unmarshals JSON bytes into the current Accounts, assuming the
+// JSON represents a list (as opposed to a map).
+func (a *Accounts) UnmarshalJSON(data []byte) error {
+	l := make([]Account, 0)
+	err := json.Unmarshal(data, &l)
+	if err != nil {
+		return err
+	}
+
+	for _, acct := range l {
+		a.Insert(acct)
+	}
+
+	return nil
+}
+
+// Insert will insert the provided account into the collection, replacing any
+// existing account with the same name.
+func (a *Accounts) Insert(acct Account) {
+	if a.mapping == nil {
+		a.mapping = make(map[string]Account)
+	}
+
+	a.mapping[acct.Name] = acct
+}
+
+// FromName will attempt to return an Account from the current collection, as
+// specified by the provided name.
+func (a Accounts) FromName(name string) (Account, bool) {
+	acct, ok := a.mapping[name]
+	return acct, ok
+}
+
+// Authenticate returns the named account if the password matches.
+func (a Accounts) Authenticate(name, password string) (Account, error) {
+	acct, ok := a.FromName(name)
+	if !ok {
+		return Account{}, errors.New("invalid account name or password")
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(acct.PasswordHash), []byte(password))
+	if err != nil {
+		return Account{}, errors.New("invalid account name or password")
+	}
+
+	return acct, nil
+}
+
+// LoadAccounts reads accounts from the JSON file at the specified path. A
+// missing file results in an empty collection.
+func LoadAccounts(path string) (Accounts, error) {
+	accounts := MakeAccounts()
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return accounts, nil
+	}
+	if err != nil {
+		return accounts, errors.New(err)
+	}
+
+	err = json.Unmarshal(data, &accounts)
+	if err != nil {
+		return accounts, errors.New(err)
+	}
+
+	return accounts, nil
+}
+
+// SaveAccounts atomically writes the accounts as JSON to the file at the
+// specified path, readable only by its owner.
+func SaveAccounts(path string, accounts Accounts) error {
+	data, err := json.MarshalIndent(accounts, "", "    ")
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return saves.WriteFile(path, data, 0600)
+}
+
+// Session represents an authenticated account, identified by a token which
+// the client presents on every connection.
+type Session struct {
+	Token   string
+	Account Account
+	Expires time.Time
+}
+
+// Owns returns true when the session's account is allowed to control the
+// entity with the specified UUID.
+func (s Session) Owns(id uuid.UUID) bool {
+	return s.Account.Owns(id)
+}
+
+// Sessions is a concurrency-safe store of active sessions, keyed by token.
+type Sessions struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	mapping map[string]Session
+}
+
+// NewSessions returns a pointer to an empty session store.
+func NewSessions() *Sessions {
+	return &Sessions{
+		TTL:     DefaultSessionTTL,
+		mapping: make(map[string]Session),
+	}
+}
+
+// Create starts a new session for the provided account.
+func (s *Sessions) Create(acct Account) (Session, error) {
+	bites := make([]byte, tokenSize)
+	_, err := rand.Read(bites)
+	if err != nil {
+		return Session{}, errors.New(err)
+	}
+
+	session := Session{
+		Token:   hex.EncodeToString(bites),
+		Account: acct,
+		Expires: time.Now().Add(s.TTL),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mapping[session.Token] = session
+	return session, nil
+}
+
+// FromToken will attempt to return an unexpired Session as specified by the
+// provided token.
+func (s *Sessions) FromToken(token string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.mapping[token]
+	if !ok {
+		return Session{}, false
+	}
+
+	if time.Now().After(session.Expires) {
+		delete(s.mapping, token)
+		return Session{}, false
+	}
+
+	return session, true
+}
+
+// Revoke ends the session specified by the provided token.
+func (s *Sessions) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.mapping, token)
+}
@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/auth"
+)
+
+func TestAuthenticate(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	acct, err := auth.NewAccount("player", "password", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acct.PasswordHash == "password" {
+		t.Error("password was stored without being hashed")
+	}
+
+	accounts := auth.MakeAccounts()
+	accounts.Insert(acct)
+
+	tests := []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{"player", "password", true},
+		{"player", "Password", false},
+		{"player", "", false},
+		{"player2", "password", false},
+	}
+
+	for _, test := range tests {
+		got, err := accounts.Authenticate(test.name, test.password)
+		if (err == nil) != test.ok {
+			t.Errorf("authenticating %s with %q: error = %v, want success %t", test.name, test.password, err, test.ok)
+			continue
+		}
+		if test.ok && (got.Name != test.name || !got.Owns(id)) {
+			t.Errorf("authenticating %s gave account %+v", test.name, got)
+		}
+	}
+}
+
+// Without an accounts file, nobody can log in until accounts are created.
+func TestLoadMissingAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	accounts, err := auth.LoadAccounts(filepath.Join(dir, "accounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = accounts.Authenticate("player", "password")
+	if err == nil {
+		t.Error("authenticated without any accounts")
+	}
+}
+
+func TestSaveAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	acct, err := auth.NewAccount("player", "password", uuid.Must(uuid.NewV4()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := auth.MakeAccounts()
+	accounts.Insert(acct)
+
+	path := filepath.Join(dir, "accounts.json")
+	err = auth.SaveAccounts(path, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Password hashes are only readable by the server's user.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("accounts saved with permissions %s, want -rw-------", info.Mode().Perm())
+	}
+
+	loaded, err := auth.LoadAccounts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loaded.Authenticate("player", "password")
+	if err != nil {
+		t.Errorf("authenticating the saved account: %s", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("saving left %d files behind, want only accounts.json", len(files))
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/config"
+	"github.com/clagraff/pitch/logging"
+)
+
+// command is one of pitch's subcommands, such as server or client.
+type command struct {
+	Name    string
+	Args    string
+	Summary string
+
+	// MinArgs and MaxArgs are how many arguments the command takes, after
+	// its flags. MaxArgs is unlimited when negative.
+	MinArgs int
+	MaxArgs int
+
+	// Flags defines the command's flags, which override the config.
+	Flags func(fs *flag.FlagSet, cfg *config.Config)
+	Run   func(cfg config.Config, args []string) error
+}
+
+// usageError is returned when pitch or one of its commands is used
+// incorrectly, so that its usage is shown along with the error.
+type usageError struct {
+	message string
+	usage   func(w io.Writer)
+}
+
+func (err usageError) Error() string {
+	return err.message
+}
+
+// serverFlags are the flags for commands which run the game server.
+func serverFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "host to listen on")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "port to listen on")
+	fs.StringVar(&cfg.Save, "save", cfg.Save, "save to load the world from, and save it to; `file`s ending in .db are databases")
+	fs.IntVar(&cfg.Snapshots, "snapshots", cfg.Snapshots, "number of snapshots of the world to keep")
+	fs.Var(&cfg.AutosaveInterval, "autosave", "`duration` between autosaves, or 0 to never autosave")
+	fs.StringVar(&cfg.Accounts, "accounts", cfg.Accounts, "`file` to load player accounts from")
+	fs.StringVar(&cfg.Journal, "journal", cfg.Journal, "`file` to journal requests to, or empty to not journal them")
+	fs.Var(&cfg.TickInterval, "tick", "real `duration` between each game tick")
+	fs.Var(&cfg.ViewInterval, "view-interval", "`duration` between sending players changes to their view, or 0 for every tick")
+	fs.IntVar(&cfg.MaxPlayers, "max-players", cfg.MaxPlayers, "number of players who may be connected at once, or 0 for no limit")
+}
+
+// clientFlags are the flags for commands which connect to a server.
+func clientFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "host of the server")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "port of the server")
+	fs.StringVar(&cfg.Memory, "memory", cfg.Memory, "`directory` to keep what the player remembers of the map in, or empty to forget it")
+}
+
+// saveFlags are the flags for commands which work on the saved world.
+func saveFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Save, "save", cfg.Save, "the saved world; `file`s ending in .db are databases")
+	fs.IntVar(&cfg.Snapshots, "snapshots", cfg.Snapshots, "number of snapshots of the world to keep")
+}
+
+// accountFlags are the flags for commands which manage player accounts.
+func accountFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Accounts, "accounts", cfg.Accounts, "`file` player accounts are kept in")
+}
+
+// find returns the command named by the start of the arguments, along with
+// the arguments which follow its name. Names may be more than one word, such
+// as "save migrate".
+func find(commands []command, args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].Name)
+		if len(args) < len(words) {
+			continue
+		}
+
+		matches := true
+		for j, word := range words {
+			if args[j] != word {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return &commands[i], args[len(words):]
+		}
+	}
+
+	return nil, args
+}
+
+// run parses the arguments and runs the command they name. Usage is written
+// to the writer when asked for with -h.
+func run(commands []command, args []string, w io.Writer) error {
+	global := flag.NewFlagSet("pitch", flag.ContinueOnError)
+	global.SetOutput(ioutil.Discard)
+	configPath := global.String("config", "", fmt.Sprintf("config `file` to read; %s is read if it exists", config.DefaultPath))
+	logDir := global.String("log-dir", "", "`directory` to write logs to")
+	globalUsage := func(w io.Writer) { usage(w, commands, global) }
+
+	err := global.Parse(args)
+	if err == flag.ErrHelp {
+		globalUsage(w)
+		return nil
+	}
+	if err != nil {
+		return usageError{message: err.Error(), usage: globalUsage}
+	}
+
+	if global.NArg() == 0 {
+		return usageError{message: "no command given", usage: globalUsage}
+	}
+
+	cmd, rest := find(commands, global.Args())
+	if cmd == nil {
+		return usageError{message: fmt.Sprintf("unknown command: %s", global.Arg(0)), usage: globalUsage}
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		cfg, err = config.Load(*configPath)
+	} else {
+		cfg, err = config.LoadDefault()
+	}
+	if err != nil {
+		return err
+	}
+	if *logDir != "" {
+		cfg.LogDir = *logDir
+	}
+
+	fs := flag.NewFlagSet("pitch "+cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if cmd.Flags != nil {
+		cmd.Flags(fs, &cfg)
+	}
+	cmdUsage := func(w io.Writer) { commandUsage(w, cmd, fs) }
+
+	err = fs.Parse(rest)
+	if err == flag.ErrHelp {
+		cmdUsage(w)
+		return nil
+	}
+	if err != nil {
+		return usageError{message: err.Error(), usage: cmdUsage}
+	}
+
+	if fs.NArg() < cmd.MinArgs || (cmd.MaxArgs >= 0 && fs.NArg() > cmd.MaxArgs) {
+		return usageError{message: "wrong number of arguments", usage: cmdUsage}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = logging.SetDir(cfg.LogDir)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return cmd.Run(cfg, fs.Args())
+}
+
+// usage writes how to use pitch, its global flags and its commands.
+func usage(w io.Writer, commands []command, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: pitch [flags] <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'pitch <command> -h' for a command's flags.")
+}
+
+// commandUsage writes how to use the command, and its flags.
+func commandUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	fmt.Fprintln(w, strings.TrimSpace(fmt.Sprintf("Usage: pitch %s [flags] %s", cmd.Name, cmd.Args)))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, cmd.Summary)
+
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+// exit reports the error, if any, and exits with a matching status: 2 for
+// usage errors, along with the usage, and 1 for anything else.
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+
+	fmt.Fprintln(os.Stderr, "pitch:", err)
+	if uerr, ok := err.(usageError); ok {
+		fmt.Fprintln(os.Stderr)
+		uerr.usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	os.Exit(1)
+}
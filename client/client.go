@@ -39,78 +39,84 @@ func asyncEventPoll(events chan termbox.Event) {
 }
 
 func renderCell(entity objects.Entity, playerID uuid.UUID) {
-	logger, closeLog := logging.Logger("client.renderCell")
+	pos, ok := entity.Position()
+	if !ok {
+		return
+	}
+
+	passability, _ := entity.Passability()
+
+	glyph := defaultGlyph(entity, passability, playerID)
+	if renderable, ok := entity.Renderable(); ok {
+		glyph = renderable.For(passability)
+	}
+
+	termbox.SetCell(
+		pos.X,
+		pos.Y,
+		glyph.Character,
+		termbox.Attribute(glyph.Foreground),
+		termbox.Attribute(glyph.Background),
+	)
+}
+
+// defaultGlyph returns the glyph drawn for entities which do not say how they
+// should be rendered.
+func defaultGlyph(entity objects.Entity, passability objects.Passability, playerID uuid.UUID) objects.Glyph {
+	logger, closeLog := logging.Logger("client.defaultGlyph")
 	defer closeLog()
 
-	ch := '#'
+	glyph := objects.Glyph{
+		Character:  '#',
+		Foreground: int(termbox.ColorWhite),
+		Background: int(termbox.ColorBlack),
+	}
 	if entity.ID == playerID {
-		ch = '@'
+		glyph.Character = '@'
 	}
-	if entity.Passability.Type == objects.Toggleable {
+	if passability.Type == objects.Toggleable {
 		logger.Println("Rendering toggleable entity:", entity)
-		switch entity.Passability.IsOpen {
+		switch passability.IsOpen {
 		case true:
-			ch = '\''
+			glyph.Character = '\''
 		case false:
-			ch = '+'
+			glyph.Character = '+'
 		}
 	}
-	termbox.SetCell(
-		entity.Position.X,
-		entity.Position.Y,
-		ch,
-		termbox.ColorWhite,
-		termbox.ColorBlack,
-	)
-}
 
-func sendMoveRequest(key termbox.Key, actorID uuid.UUID, reqs chan requests.Request) {
-	logger, closeLog := logging.Logger("client.sendMoveRequest")
-	defer closeLog()
-
-	var dir requests.Direction
-
-	switch key {
-	case termbox.KeyArrowUp:
-		dir = requests.North
-	case termbox.KeyArrowDown:
-		dir = requests.South
-	case termbox.KeyArrowLeft:
-		dir = requests.West
-	case termbox.KeyArrowRight:
-		dir = requests.East
-	default:
-		logger.Println("Invalid termbox key")
-		panic("invalid termbox key")
-	}
+	return glyph
+}
 
+func sendMoveRequest(dir requests.Direction, actorID uuid.UUID, reqs chan requests.Request) {
 	reqs <- requests.MoveRequest{ActorID: actorID, Direction: dir}
-	reqs <- requests.ViewRequest{ActorID: actorID}
 }
 
-func tryGetTogglable(targets []objects.Entity) (objects.Entity, bool) {
+func tryGetTogglable(targets []objects.Entity) (objects.Entity, bool, bool) {
 	logger, closeLog := logging.Logger("client.tryGetTogglable")
 	defer closeLog()
 
 	for _, e := range targets {
-		if e.Passability.Type == objects.Toggleable {
+		if passability, ok := e.Passability(); ok && passability.Type == objects.Toggleable {
 			logger.Println("entity:", e.ID.String(), "can be toggled")
-			logger.Println("entity:", e.ID.String(), "is open?", e.Passability.IsOpen)
-			logger.Printf("%p\n", e)
-			return e, e.Passability.IsOpen
+			logger.Println("entity:", e.ID.String(), "is open?", passability.IsOpen)
+			return e, passability.IsOpen, true
 		}
 		logger.Println("entity:", e.ID.String(), "is not toggleable")
 	}
 
-	return objects.Entity{}, false
+	return objects.Entity{}, false, false
 }
 
 func sendToggleRequest(world entities.World, actor objects.Entity, reqs chan requests.Request) {
 	logger, closeLog := logging.Logger("client.sendToggleRequest")
 	defer closeLog()
 
-	x := actor.Position.X
-	y := actor.Position.Y
+	pos, ok := actor.Position()
+	if !ok {
+		return
+	}
+	x := pos.X
+	y := pos.Y
 
 	down := world.Objects.FromXY(x, y+1)
 	up := world.Objects.FromXY(x, y-1)
@@ -119,8 +125,8 @@ func sendToggleRequest(world entities.World, actor objects.Entity, reqs chan req
 
 	directions := [][]objects.Entity{down, up, left, right}
 	for _, direction := range directions {
-		target, isOpen := tryGetTogglable(direction)
-		if target != (objects.Entity{}) {
+		target, isOpen, ok := tryGetTogglable(direction)
+		if ok {
 			if isOpen {
 				reqs <- requests.CloseRequest{ActorID: actor.ID, TargetID: target.ID}
 				logger.Println("Open; closing target")
@@ -143,7 +149,7 @@ func renderWorld(world entities.World, playerID uuid.UUID) {
 		panic(err)
 	}
 
-	for _, e := range world.Objects {
+	for _, e := range world.Objects.Entities() {
 		logger.Println("Rendering entity:", e)
 		renderCell(e, playerID)
 	}
@@ -4,6 +4,7 @@ import (
 	"github.com/clagraff/pitch/comms/requests"
 	"github.com/clagraff/pitch/comms/responses"
 	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/keybindings"
 	"github.com/clagraff/pitch/logging"
 	termbox "github.com/nsf/termbox-go"
 	uuid "github.com/satori/go.uuid"
@@ -55,9 +56,8 @@ func Gameplay(reqs chan requests.Request, resps chan responses.Response, world e
 				return nil
 			}
 
-			switch ev.Key {
-			case termbox.KeyArrowUp, termbox.KeyArrowDown, termbox.KeyArrowLeft, termbox.KeyArrowRight:
-				sendMoveRequest(ev.Key, player.ID, reqs)
+			if dir, ok := keybindings.Direction(ev); ok {
+				sendMoveRequest(dir, player.ID, reqs)
 			}
 
 			switch ev.Ch {
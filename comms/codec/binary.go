@@ -0,0 +1,99 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/go-errors/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Binary is a compact codec which encodes messages as MessagePack, with each
+// message prefixed by its length as a uvarint. Struct fields are keyed by
+// their JSON names, so types need no additional tags.
+var Binary Codec = binaryCodec{}
+
+// maxFrameSize is the largest frame the binary codec will read, protecting
+// against a corrupt or malicious length prefix.
+const maxFrameSize = 16 * 1024 * 1024
+
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string {
+	return "msgpack"
+}
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buff)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	err := dec.Decode(v)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+func (binaryCodec) WriteFrame(w io.Writer, frame []byte) error {
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(frame)))
+
+	_, err := w.Write(append(prefix[:n], frame...))
+	return err
+}
+
+func (binaryCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxFrameSize {
+		return nil, errors.Errorf("frame of %d bytes exceeds maximum of %d", size, maxFrameSize)
+	}
+
+	frame := make([]byte, size)
+	_, err = io.ReadFull(r, frame)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// EncodeMsgpack writes the raw message as-is.
+func (m RawMessage) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if m == nil {
+		return enc.EncodeNil()
+	}
+
+	return enc.Encode(msgpack.RawMessage(m))
+}
+
+// DecodeMsgpack sets the raw message to the next encoded value.
+func (m *RawMessage) DecodeMsgpack(dec *msgpack.Decoder) error {
+	raw, err := dec.DecodeRaw()
+	if err != nil {
+		return err
+	}
+
+	*m = RawMessage(raw)
+	return nil
+}
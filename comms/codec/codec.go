@@ -0,0 +1,69 @@
+package codec
+
+import (
+	"bufio"
+	"io"
+	"sort"
+
+	"github.com/go-errors/errors"
+)
+
+// Codec is used to encode the messages exchanged between the server and its
+// clients, and to frame them on the connection. An empty frame is a heartbeat.
+type Codec interface {
+	// Name returns the name used to negotiate the codec in the handshake.
+	Name() string
+
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+
+	// WriteFrame writes a single message to the writer.
+	WriteFrame(w io.Writer, frame []byte) error
+	// ReadFrame reads a single message from the reader.
+	ReadFrame(r *bufio.Reader) ([]byte, error)
+}
+
+// Available codecs, keyed by name.
+var codecs = map[string]Codec{
+	JSON.Name():   JSON,
+	Binary.Name(): Binary,
+}
+
+// Names returns the sorted names of every available codec.
+func Names() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// FromName will attempt to return the codec with the specified name.
+func FromName(name string) (Codec, bool) {
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// Negotiate returns the first of the client's preferred codecs which is
+// available. Clients which do not list any codecs are assumed to use JSON.
+func Negotiate(preferred []string) (Codec, error) {
+	if len(preferred) == 0 {
+		return JSON, nil
+	}
+
+	for _, name := range preferred {
+		if c, ok := FromName(name); ok {
+			return c, nil
+		}
+	}
+
+	return nil, errors.Errorf("none of the codecs %v are supported", preferred)
+}
+
+// RawMessage is an already encoded value, which is embedded as-is when
+// marshalled by either codec. It allows a message to be decoded in stages,
+// such as when the type of a payload must be known before its body can be
+// unmarshalled.
+type RawMessage []byte
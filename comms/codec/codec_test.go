@@ -0,0 +1,322 @@
+package codec_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/codec"
+	"github.com/clagraff/pitch/comms/protocol"
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/generation"
+	"github.com/clagraff/pitch/logging"
+	"github.com/clagraff/pitch/utils"
+)
+
+// viewResponse returns the response to a view request by an actor standing in
+// the centre of a 50 by 50 rectangle of open doors, which fill its view.
+func viewResponse() responses.ViewResponse {
+	source := objects.Entity{}
+	source.Set(objects.Passability{Type: objects.Toggleable, IsOpen: true})
+	source.Set(objects.Attributes{Dexterity: 12, Luck: 8, Strength: 14, Wisdom: 10})
+	source.Set(objects.Health{Current: 10, Max: 10})
+
+	world := entities.MakeWorld()
+	for _, e := range generation.Fill(source, 50, 50) {
+		world.Objects = world.Objects.Append(e)
+	}
+
+	actor := objects.New()
+	actor.Set(objects.Position{X: 25, Y: 25})
+	actor.Set(objects.Attributes{Wisdom: 10})
+	world.Objects = world.Objects.Append(*actor)
+
+	return responses.ViewResponse{
+		ActorID: actor.ID,
+		Objects: requests.Perceive(world, *actor),
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	resp := viewResponse()
+
+	for _, name := range codec.Names() {
+		c, _ := codec.FromName(name)
+
+		b.Run(name, func(b *testing.B) {
+			bites, err := responses.Encode(c, resp)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(bites)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := responses.Encode(c, resp)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	resp := viewResponse()
+
+	for _, name := range codec.Names() {
+		c, _ := codec.FromName(name)
+
+		b.Run(name, func(b *testing.B) {
+			// Decoding straight into the response avoids the logging performed
+			// when decoding a payload, which would otherwise dominate.
+			body, err := c.Marshal(resp)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				r := responses.ViewResponse{}
+				err := c.Unmarshal(body, &r)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// samples returns an example of every registered response, by name, with as
+// many of its fields set as possible.
+func samples() map[string]responses.Response {
+	actor := uuid.FromStringOrNil("b5d9c244-b17d-4845-bd56-07c710536008")
+	target := uuid.FromStringOrNil("6da0648c-5fda-4d7a-a086-2f38b6e1fba0")
+	item := uuid.FromStringOrNil("f3a3c1a2-5b7e-4d8e-9c61-0e8f6b2d7a41")
+
+	e := objects.Entity{ID: target}
+	e.Set(objects.Position{X: 3, Y: -4})
+	e.Set(objects.Health{Current: 7, Max: 10})
+	e.Set(objects.Energy{Speed: 100, Points: 25})
+	e.Set(objects.Passability{Type: objects.Toggleable, IsOpen: true})
+	e.Set(objects.Inventory{ItemIDs: []uuid.UUID{item}, Capacity: 5})
+	e.Set(objects.Equipment{PrimaryItemID: item})
+	e.Set(objects.Renderable{Glyph: objects.Glyph{Character: '+', Foreground: 4}})
+	e.Set(objects.Static{})
+
+	roll := &utils.DiceResult{
+		Dice:     "2d6!kh1+1",
+		Rolls:    []utils.DieRoll{{Value: 6, Exploded: true}, {Value: 2}, {Value: 3, Dropped: true}},
+		Modifier: 1,
+		Total:    9,
+	}
+	equipment := responses.EquipmentResponse{
+		ActorID:   actor,
+		Equipment: objects.Equipment{PrimaryItemID: item, SecondaryItemID: item},
+		Inventory: objects.Inventory{ItemIDs: []uuid.UUID{target}, Capacity: 3},
+	}
+	changed := responses.EntityChanged{
+		ActorID:  actor,
+		EntityID: target,
+		Diff: objects.Diff{
+			Changed: map[string]interface{}{"health.current": 6, "passability.is_open": false},
+			Removed: []string{"static"},
+		},
+	}
+
+	return map[string]responses.Response{
+		"MeleeAttackResponse": responses.MeleeAttackResponse{AttackerID: actor, TargetID: target, DidHit: true, Damage: 9, DamageRoll: roll, HealthRemaining: 1},
+		"RangeAttackResponse": responses.RangeAttackResponse{AttackerID: actor, TargetID: target, DidHit: true, Damage: 9, DamageRoll: roll},
+		"MoveResponse":        responses.MoveResponse{ActorID: actor, X: -1, Y: 2},
+		"ToggleResponse":      responses.ToggleResponse{ActorID: actor, TargetID: target, IsOpen: true},
+		"PickUpResponse":      responses.PickUpResponse{ActorID: actor, ItemID: item},
+		"DropResponse":        responses.DropResponse{ActorID: actor, ItemID: item, X: 3, Y: 4},
+		"GiveResponse":        responses.GiveResponse{ActorID: actor, TargetID: target, ItemID: item},
+		"EquipmentResponse":   equipment,
+		"ViewResponse":        responses.ViewResponse{ActorID: actor, Objects: []objects.Entity{e, {ID: actor}}, Static: []uuid.UUID{target}},
+		"EntityEnteredView":   responses.EntityEnteredView{ActorID: actor, Entity: e, Static: true},
+		"EntityChanged":       changed,
+		"EntityLeftView":      responses.EntityLeftView{ActorID: actor, EntityID: target, Static: true},
+		"ErrorResponse":       responses.ErrorResponse{ActorID: actor, Code: responses.BlockedError, Message: "blocked", RequestType: "MoveRequest"},
+		"Wrapper": responses.MakeWrapper(
+			responses.EntityEnteredView{ActorID: actor, Entity: e},
+			changed,
+			responses.MakeWrapper(equipment),
+		),
+	}
+}
+
+// Every registered response comes back the same from both codecs, including
+// within an envelope. Decoded responses are compared as JSON, as numbers
+// within diffs come back as whichever type the codec decodes them to.
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = logging.SetDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	examples := samples()
+
+	for _, name := range responses.Names() {
+		resp, ok := examples[name]
+		if !ok {
+			t.Errorf("%s has no sample response", name)
+			continue
+		}
+		want, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, codecName := range codec.Names() {
+			c, _ := codec.FromName(codecName)
+
+			bites, err := responses.Encode(c, resp)
+			if err != nil {
+				t.Errorf("%s: encoding %s: %s", codecName, name, err)
+				continue
+			}
+			bites, err = c.Marshal(protocol.Envelope{
+				Direction: protocol.ResponseDirection,
+				ID:        42,
+				Payload:   codec.RawMessage(bites),
+			})
+			if err != nil {
+				t.Errorf("%s: enveloping %s: %s", codecName, name, err)
+				continue
+			}
+
+			env := protocol.Envelope{}
+			err = c.Unmarshal(bites, &env)
+			if err != nil {
+				t.Errorf("%s: unenveloping %s: %s", codecName, name, err)
+				continue
+			}
+			if env.Direction != protocol.ResponseDirection || env.ID != 42 {
+				t.Errorf("%s: %s came in envelope %+v", codecName, name, env)
+			}
+
+			decoded, err := responses.Decode(c, env.Payload)
+			if err != nil {
+				t.Errorf("%s: decoding %s: %s", codecName, name, err)
+				continue
+			}
+			if reflect.TypeOf(decoded) != reflect.TypeOf(resp) {
+				t.Errorf("%s: %s decoded as %T", codecName, name, decoded)
+				continue
+			}
+
+			got, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s decoded as\n%s\nwant\n%s", codecName, name, got, want)
+			}
+		}
+	}
+}
+
+// The binary codec encodes entities as an extension, rather than as a map.
+func TestBinaryEntityExtension(t *testing.T) {
+	e := samples()["EntityEnteredView"].(responses.EntityEnteredView).Entity
+
+	bites, err := codec.Binary.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Extensions start with one of the fixext or ext formats.
+	if b := bites[0]; !(b >= 0xd4 && b <= 0xd8) && !(b >= 0xc7 && b <= 0xc9) {
+		t.Errorf("entity was encoded starting with %#x, want an extension", b)
+	}
+
+	decoded := objects.Entity{}
+	err = codec.Binary.Unmarshal(bites, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, e) {
+		t.Errorf("entity decoded as %+v, want %+v", decoded, e)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	frames := [][]byte{
+		[]byte("first"),
+		{},
+		bytes.Repeat([]byte("x"), 300),
+	}
+
+	for _, name := range codec.Names() {
+		c, _ := codec.FromName(name)
+
+		var buff bytes.Buffer
+		for _, frame := range frames {
+			if err := c.WriteFrame(&buff, frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := bufio.NewReader(&buff)
+		for i, want := range frames {
+			got, err := c.ReadFrame(r)
+			if err != nil {
+				t.Errorf("%s: reading frame %d: %s", name, i, err)
+				break
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: frame %d is %q, want %q", name, i, got, want)
+			}
+		}
+
+		if _, err := c.ReadFrame(r); err != io.EOF {
+			t.Errorf("%s: reading past the last frame gave %v, want EOF", name, err)
+		}
+	}
+}
+
+func TestBinaryBadFrames(t *testing.T) {
+	uvarint := func(n uint64) []byte {
+		prefix := make([]byte, binary.MaxVarintLen64)
+		return prefix[:binary.PutUvarint(prefix, n)]
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"a truncated length", []byte{0x80, 0x80}},
+		{"a truncated frame", append(uvarint(10), "short"...)},
+		{"a frame over the maximum size", append(uvarint(16*1024*1024+1), "data"...)},
+		{"the largest possible length", uvarint(1<<64 - 1)},
+		{"a length which overflows", bytes.Repeat([]byte{0xff}, binary.MaxVarintLen64+1)},
+	}
+
+	for _, test := range tests {
+		_, err := codec.Binary.ReadFrame(bufio.NewReader(bytes.NewReader(test.data)))
+		if err == nil || err == io.EOF {
+			t.Errorf("reading %s gave %v, want an error", test.name, err)
+		}
+	}
+}
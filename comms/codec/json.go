@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/go-errors/errors"
+)
+
+// JSON is a codec which encodes messages as JSON, with each message on its own
+// line.
+var JSON Codec = jsonCodec{}
+
+var delimiter = byte('\n')
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	bites, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	return bites, nil
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+func (jsonCodec) WriteFrame(w io.Writer, frame []byte) error {
+	_, err := w.Write(append(frame, delimiter))
+	return err
+}
+
+func (jsonCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	frame, err := r.ReadBytes(delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame[:len(frame)-1], nil
+}
+
+// MarshalJSON returns the raw message as-is.
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	return m, nil
+}
+
+// UnmarshalJSON sets the raw message to a copy of the data.
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	*m = append((*m)[0:0], data...)
+	return nil
+}
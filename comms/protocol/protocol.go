@@ -0,0 +1,149 @@
+package protocol
+
+import (
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/codec"
+)
+
+// Delimiter separates handshakes, and the messages sent over LegacyVersion
+// connections. An empty message is a heartbeat.
+var Delimiter = byte('\n')
+
+// Protocol versions understood by the server and clients.
+const (
+	// LegacyVersion uses two connections per player: one to send requests, and
+	// one subscribed to responses. Messages are bare request/response payloads.
+	//
+	// Deprecated: use MultiplexVersion.
+	LegacyVersion = 1
+	// MultiplexVersion uses a single connection per player, over which
+	// requests and responses are exchanged wrapped in Envelopes.
+	MultiplexVersion = 2
+
+	// CurrentVersion is the newest protocol version.
+	CurrentVersion = MultiplexVersion
+	// MinimumVersion is the oldest protocol version still accepted.
+	MinimumVersion = LegacyVersion
+)
+
+// Handshake is sent by clients when opening a connection. A client first
+// authenticates with its account name and password; subsequent connections
+// present the session token returned by the server instead.
+//
+// Clients list the names of the request and response types they understand.
+// A missing Version is treated as LegacyVersion, and missing type lists are
+// treated as supporting everything the server does.
+//
+// Codecs lists the names of the codecs the client can use for Envelopes, in
+// order of preference. When missing, JSON is used.
+type Handshake struct {
+	ID        uuid.UUID `json:"id"`
+	Version   int       `json:"version,omitempty"`
+	Requests  []string  `json:"requests,omitempty"`
+	Responses []string  `json:"responses,omitempty"`
+	Codecs    []string  `json:"codecs,omitempty"`
+	Subscribe bool      `json:"subscribe"`
+	Name      string    `json:"name,omitempty"`
+	Password  string    `json:"password,omitempty"`
+	Token     string    `json:"token,omitempty"`
+}
+
+// HandshakeReply is the server's answer to a Handshake, containing the
+// protocol version, codec, and the request and response types to be used for
+// the rest of the connection. Error is set when the handshake was refused,
+// after which the connection is closed.
+type HandshakeReply struct {
+	ID        uuid.UUID `json:"id"`
+	Version   int       `json:"version"`
+	Requests  []string  `json:"requests"`
+	Responses []string  `json:"responses"`
+	Codec     string    `json:"codec,omitempty"`
+	Token     string    `json:"token,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Negotiate returns the newest protocol version supported by both sides, given
+// the newest version supported by the client.
+func Negotiate(clientVersion int) (int, error) {
+	if clientVersion == 0 {
+		clientVersion = LegacyVersion
+	}
+	if clientVersion < MinimumVersion {
+		return 0, errors.Errorf(
+			"protocol version %d is no longer supported; versions %d to %d are",
+			clientVersion,
+			MinimumVersion,
+			CurrentVersion,
+		)
+	}
+	if clientVersion > CurrentVersion {
+		return CurrentVersion, nil
+	}
+
+	return clientVersion, nil
+}
+
+// Intersect returns the type names supported by both the client and the
+// server, in the server's order. When the client does not list any names, all
+// of the server's are assumed to be supported.
+func Intersect(client, server []string) []string {
+	if len(client) == 0 {
+		return server
+	}
+
+	names := make([]string, 0)
+	for _, name := range server {
+		if Contains(client, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Missing returns the required type names which are not in the list of names.
+func Missing(names, required []string) []string {
+	missing := make([]string, 0)
+	for _, name := range required {
+		if !Contains(names, name) {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// Contains returns true when the name is in the list of type names.
+func Contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Direction specifies which way an Envelope is travelling.
+type Direction string
+
+// Available directions:
+const (
+	RequestDirection  Direction = "request"
+	ResponseDirection Direction = "response"
+)
+
+// Envelope wraps a request or response payload when using MultiplexVersion.
+// Clients number their requests with an ID, which the server copies onto the
+// response caused by that request. Responses caused by other players' requests
+// have an ID of zero.
+//
+// Envelopes, and their payloads, are encoded and framed using the codec
+// negotiated in the handshake.
+type Envelope struct {
+	Direction Direction        `json:"direction"`
+	ID        uint64           `json:"id,omitempty"`
+	Payload   codec.RawMessage `json:"payload"`
+}
@@ -0,0 +1,74 @@
+package protocol_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/clagraff/pitch/comms/protocol"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		client int
+		want   int
+		fails  bool
+	}{
+		{0, protocol.LegacyVersion, false},
+		{protocol.LegacyVersion, protocol.LegacyVersion, false},
+		{protocol.MultiplexVersion, protocol.MultiplexVersion, false},
+		{protocol.CurrentVersion + 1, protocol.CurrentVersion, false},
+		{-1, 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := protocol.Negotiate(test.client)
+		if (err != nil) != test.fails {
+			t.Errorf("version %d: got error %v, want failure %t", test.client, err, test.fails)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("version %d: negotiated %d, want %d", test.client, got, test.want)
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	server := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name   string
+		client []string
+		want   []string
+	}{
+		{"a client listing nothing supports everything", nil, []string{"a", "b", "c"}},
+		{"names are in the server's order", []string{"c", "a"}, []string{"a", "c"}},
+		{"names the server lacks are ignored", []string{"b", "d"}, []string{"b"}},
+		{"nothing may be shared", []string{"d"}, []string{}},
+	}
+
+	for _, test := range tests {
+		got := protocol.Intersect(test.client, server)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	tests := []struct {
+		names    []string
+		required []string
+		want     []string
+	}{
+		{[]string{"a", "b"}, []string{"a"}, []string{}},
+		{[]string{"a"}, []string{"a", "b", "c"}, []string{"b", "c"}},
+		{nil, []string{"a"}, []string{"a"}},
+	}
+
+	for _, test := range tests {
+		got := protocol.Missing(test.names, test.required)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("missing from %v: got %v, want %v", test.names, got, test.want)
+		}
+	}
+}
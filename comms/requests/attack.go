@@ -1,7 +1,6 @@
 package requests
 
 import (
-	"github.com/go-errors/errors"
 	uuid "github.com/satori/go.uuid"
 
 	"github.com/clagraff/pitch/comms/responses"
@@ -12,15 +11,24 @@ import (
 	"github.com/clagraff/pitch/utils"
 )
 
+// Dice rolled during attacks.
+var (
+	luckDice   = utils.MustParseDice("d%")
+	attackDice = utils.MustParseDice("1d20")
+	// unarmedDamage is rolled for attackers without a primary item.
+	unarmedDamage = utils.MustParseDice("1d4")
+)
+
 // calcLuckyCritical will calculate whether a critical hit has occurred based on
 // a roll against the Luck attribute of the specified actor.
-func calcLuckyCritical(actor objects.Entity) bool {
+func calcLuckyCritical(rng utils.RNG, actor objects.Entity) bool {
 	// If Luck modifier + 5 is greater than the roll, a crit has occurred.
 	//		0% chance at lowest Luck level, Luck <= 1.
 	//		15% change at highest Luck leve, Luck >= 30
 
-	luck := actor.Attributes.Luck.Modifier() + 5
-	luckRoll := utils.Roll(1, 100)
+	attributes, _ := actor.Attributes()
+	luck := attributes.Luck.Modifier() + 5
+	luckRoll := luckDice.Roll(rng).Total
 
 	return luck >= luckRoll
 }
@@ -29,11 +37,12 @@ func calcLuckyCritical(actor objects.Entity) bool {
 // has occurred.
 // A crit occurs either due to a good Luck roll, or if their attack roll is a
 // perfect `20`.
-func calcAttackRoll(attacker objects.Entity) (int, bool) {
-	critical := calcLuckyCritical(attacker)
+func calcAttackRoll(rng utils.RNG, attacker objects.Entity) (int, bool) {
+	critical := calcLuckyCritical(rng, attacker)
 
-	attackRoll := utils.Roll(1, 20)
-	attackModifier := attacker.Attributes.Strength.Modifier()
+	attributes, _ := attacker.Attributes()
+	attackRoll := attackDice.Roll(rng).Total
+	attackModifier := attributes.Strength.Modifier()
 
 	if attackRoll == 20 {
 		critical = true
@@ -43,21 +52,25 @@ func calcAttackRoll(attacker objects.Entity) (int, bool) {
 }
 
 // calcDamageAmount determines the amount of damage an attacker does with their
-// current primary item.
+// current primary item, along with the dice rolled for it.
 // Takes into account adding the Strength modifier for melee items, and
 // Dexterity modifier for range items.
-func calcDamageAmount(world entities.World, attacker objects.Entity) (int, items.DamageType) {
+func calcDamageAmount(rng utils.RNG, world entities.World, attacker objects.Entity) (int, utils.DiceResult, items.DamageType) {
 	damageType := items.MeleeDamage
-	damageRoll := utils.Roll(1, 4) // Unarmed attack is default. 1d4+0
-
-	if item, ok := world.Items.FromID(attacker.Equipment.PrimaryItemID); ok {
-		damageRoll = item.Damage.Calculate()
+	roll := unarmedDamage.Roll(rng)
+	damageRoll := roll.Total
+
+	attributes, _ := attacker.Attributes()
+	equipment, _ := attacker.Equipment()
+	if item, ok := world.Items.FromID(equipment.PrimaryItemID); ok {
+		roll = item.Damage.Roll(rng)
+		damageRoll = roll.Total
 		damageType = item.Damage.Type
 
 		if damageType == items.MeleeDamage {
-			damageRoll = damageRoll + attacker.Attributes.Strength.Modifier()
+			damageRoll = damageRoll + attributes.Strength.Modifier()
 		} else if damageType == items.RangeDamage {
-			damageRoll = damageRoll + attacker.Attributes.Dexterity.Modifier()
+			damageRoll = damageRoll + attributes.Dexterity.Modifier()
 		}
 	}
 
@@ -65,7 +78,7 @@ func calcDamageAmount(world entities.World, attacker objects.Entity) (int, items
 		damageRoll = 0
 	}
 
-	return damageRoll, damageType
+	return damageRoll, roll, damageType
 }
 
 func isHit(isCritical bool, armorClass int, attackRoll int) bool {
@@ -79,24 +92,44 @@ type MeleeAttackRequest struct {
 	TargetID   uuid.UUID `json:"target_id"`
 }
 
+// Actor returns the ID of the entity performing the request.
+func (req MeleeAttackRequest) Actor() uuid.UUID {
+	return req.AttackerID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req MeleeAttackRequest) EnergyCost() int {
+	return AttackCost
+}
+
 // Execute performs the melee request.
-func (req MeleeAttackRequest) Execute(world entities.World) (entities.World, responses.Response, error) {
+func (req MeleeAttackRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
 	logger, closeLog := logging.Logger("requests.melee_attack_request")
 	defer closeLog()
 
 	attacker, ok := world.Objects.FromID(req.AttackerID)
 	if !ok {
-		return world, nil, errors.Errorf("attacker could not be found")
+		return world, nil, newError(responses.NotFoundError, "attacker could not be found")
 	}
 
 	target, ok := world.Objects.FromID(req.TargetID)
 	if !ok {
-		return world, nil, errors.Errorf("target could not be found")
+		return world, nil, newError(responses.NotFoundError, "target could not be found")
+	}
+
+	if timer, _ := attacker.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	health, ok := target.Health()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "target cannot be attacked")
 	}
 
-	attackRoll, critical := calcAttackRoll(attacker)
+	attackRoll, critical := calcAttackRoll(ctx.RNG, attacker)
 
-	ac := 10 + target.Attributes.Dexterity.Modifier()
+	attributes, _ := target.Attributes()
+	ac := 10 + attributes.Dexterity.Modifier()
 
 	// To hit, the attack roll + modifier must be greater than target AC.
 	// Always hits if is critical.
@@ -106,14 +139,14 @@ func (req MeleeAttackRequest) Execute(world entities.World) (entities.World, res
 			TargetID:        req.TargetID,
 			DidHit:          false,
 			Damage:          0,
-			HealthRemaining: target.Health,
+			HealthRemaining: health.Current,
 		}
 		return world, resp, nil
 	}
 
-	damageAmount, damateType := calcDamageAmount(world, attacker)
-	if damateType != items.MeleeDamage {
-		return world, nil, errors.Errorf("tried to melee attack with non-melee weapon")
+	damageAmount, damageRoll, damageType := calcDamageAmount(ctx.RNG, world, attacker)
+	if damageType != items.MeleeDamage {
+		return world, nil, newError(responses.InvalidWeaponError, "tried to melee attack with non-melee weapon")
 	}
 
 	// When a critical occurs, the attack always hits and the damage is doubled.
@@ -123,7 +156,8 @@ func (req MeleeAttackRequest) Execute(world entities.World) (entities.World, res
 	}
 
 	armor := 0
-	if item, ok := world.Items.FromID(target.Equipment.ChestID); ok {
+	equipment, _ := target.Equipment()
+	if item, ok := world.Items.FromID(equipment.ChestID); ok {
 		armor = armor + item.Armor.MeleeReduction
 	}
 
@@ -143,16 +177,17 @@ func (req MeleeAttackRequest) Execute(world entities.World) (entities.World, res
 		totalDamage,
 	)
 
-	target.Health = target.Health - totalDamage
-	if target.Health <= 0 {
-		target.Health = 0
-		world.Objects = world.Objects.MustRemove(target)
+	health.Current = health.Current - totalDamage
+	if health.Current <= 0 {
+		health.Current = 0
+		world = Kill(world, target)
 
 		logger.Printf(
 			"%s has been killed and removed",
 			target.ID.String(),
 		)
 	} else {
+		target.Set(health)
 		world.Objects = world.Objects.MustUpdate(target)
 	}
 
@@ -161,7 +196,8 @@ func (req MeleeAttackRequest) Execute(world entities.World) (entities.World, res
 		TargetID:        req.TargetID,
 		DidHit:          true,
 		Damage:          totalDamage,
-		HealthRemaining: target.Health,
+		DamageRoll:      &damageRoll,
+		HealthRemaining: health.Current,
 	}
 	return world, resp, nil
 }
@@ -173,24 +209,48 @@ type RangeAttackRequest struct {
 	TargetID   uuid.UUID `json:"target_id"`
 }
 
+// Actor returns the ID of the entity performing the request.
+func (req RangeAttackRequest) Actor() uuid.UUID {
+	return req.AttackerID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req RangeAttackRequest) EnergyCost() int {
+	return AttackCost
+}
+
 // Execute performs the range attack request.
-func (req RangeAttackRequest) Execute(world entities.World) (entities.World, responses.Response, error) {
+func (req RangeAttackRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
 	logger, closeLog := logging.Logger("requests.range_attack_request")
 	defer closeLog()
 
 	attacker, ok := world.Objects.FromID(req.AttackerID)
 	if !ok {
-		return world, nil, errors.Errorf("attacker could not be found")
+		return world, nil, newError(responses.NotFoundError, "attacker could not be found")
 	}
 
 	target, ok := world.Objects.FromID(req.TargetID)
 	if !ok {
-		return world, nil, errors.Errorf("target could not be found")
+		return world, nil, newError(responses.NotFoundError, "target could not be found")
+	}
+
+	if timer, _ := attacker.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	health, ok := target.Health()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "target cannot be attacked")
+	}
+
+	if !InSight(world, attacker, target) {
+		return world, nil, newError(responses.InvalidTargetError, "target is not in sight")
 	}
 
-	attackRoll, critical := calcAttackRoll(attacker)
+	attackRoll, critical := calcAttackRoll(ctx.RNG, attacker)
 
-	ac := 10 + target.Attributes.Dexterity.Modifier()
+	attributes, _ := target.Attributes()
+	ac := 10 + attributes.Dexterity.Modifier()
 
 	// To hit, the attack roll + modifier must be greater than target AC.
 	// Always hits if is critical.
@@ -200,14 +260,14 @@ func (req RangeAttackRequest) Execute(world entities.World) (entities.World, res
 			TargetID:        req.TargetID,
 			DidHit:          false,
 			Damage:          0,
-			HealthRemaining: target.Health,
+			HealthRemaining: health.Current,
 		}
 		return world, resp, nil
 	}
 
-	damageAmount, damageType := calcDamageAmount(world, attacker)
+	damageAmount, damageRoll, damageType := calcDamageAmount(ctx.RNG, world, attacker)
 	if damageType != items.RangeDamage {
-		return world, nil, errors.Errorf("tried to range attack with non-range weapon")
+		return world, nil, newError(responses.InvalidWeaponError, "tried to range attack with non-range weapon")
 	}
 
 	// When a critical occurs, the attack always hits and the damage is doubled.
@@ -217,7 +277,8 @@ func (req RangeAttackRequest) Execute(world entities.World) (entities.World, res
 	}
 
 	armor := 0
-	if item, ok := world.Items.FromID(target.Equipment.ChestID); ok {
+	equipment, _ := target.Equipment()
+	if item, ok := world.Items.FromID(equipment.ChestID); ok {
 		armor = armor + item.Armor.RangeReduction
 	}
 
@@ -237,16 +298,17 @@ func (req RangeAttackRequest) Execute(world entities.World) (entities.World, res
 		totalDamage,
 	)
 
-	target.Health = target.Health - totalDamage
-	if target.Health <= 0 {
-		target.Health = 0
-		world.Objects = world.Objects.MustRemove(target)
+	health.Current = health.Current - totalDamage
+	if health.Current <= 0 {
+		health.Current = 0
+		world = Kill(world, target)
 
 		logger.Printf(
 			"%s has been killed and removed",
 			target.ID.String(),
 		)
 	} else {
+		target.Set(health)
 		world.Objects = world.Objects.MustUpdate(target)
 	}
 
@@ -255,7 +317,8 @@ func (req RangeAttackRequest) Execute(world entities.World) (entities.World, res
 		TargetID:        req.TargetID,
 		DidHit:          true,
 		Damage:          totalDamage,
-		HealthRemaining: target.Health,
+		DamageRoll:      &damageRoll,
+		HealthRemaining: health.Current,
 	}
 	return world, resp, nil
 }
@@ -1,7 +1,6 @@
 package requests
 
 import (
-	"github.com/go-errors/errors"
 	uuid "github.com/satori/go.uuid"
 
 	"github.com/clagraff/pitch/comms/responses"
@@ -15,27 +14,39 @@ type CloseRequest struct {
 	TargetID uuid.UUID `json:"target_id"`
 }
 
+// Actor returns the ID of the entity performing the request.
+func (req CloseRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req CloseRequest) EnergyCost() int {
+	return CloseCost
+}
+
 // Execute will attempt to close the specified target by the provided actor.
-func (req CloseRequest) Execute(world entities.World) (entities.World, responses.Response, error) {
+func (req CloseRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
 	actor, ok := world.Objects.FromID(req.ActorID)
 	if !ok {
-		return world, nil, errors.Errorf("actor could not be found")
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
 	}
 
 	target, ok := world.Objects.FromID(req.TargetID)
 	if !ok {
-		return world, nil, errors.Errorf("target could not be found")
+		return world, nil, newError(responses.NotFoundError, "target could not be found")
 	}
 
-	if !actor.Timer.Ready() {
-		return world, nil, errors.Errorf("timer not ready")
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
 	}
 
-	if target.Passability.Type != objects.Toggleable {
-		return world, nil, errors.Errorf("target is not closable")
+	passability, ok := target.Passability()
+	if !ok || passability.Type != objects.Toggleable {
+		return world, nil, newError(responses.InvalidTargetError, "target is not closable")
 	}
 
-	target.Passability.IsOpen = false
+	passability.IsOpen = false
+	target.Set(passability)
 	world.Objects = world.Objects.MustUpdate(target)
 
 	return world, responses.ToggleResponse{
@@ -0,0 +1,19 @@
+package requests
+
+import (
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/utils"
+)
+
+// Context carries what a request needs while being executed, other than the
+// world itself.
+type Context struct {
+	// RNG is the source which all of the request's randomness is drawn from.
+	RNG utils.RNG
+}
+
+// NewContext returns the context for executing requests against the world,
+// drawing randomness from the world's own RNG.
+func NewContext(world entities.World) Context {
+	return Context{RNG: world.RNG}
+}
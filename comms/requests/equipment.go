@@ -0,0 +1,148 @@
+package requests
+
+import (
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// EquipRequest represents a request to equip an item from the actor's
+// inventory into one of its equipment slots. Anything already in the slot is
+// put back into the inventory.
+type EquipRequest struct {
+	ActorID uuid.UUID    `json:"actor_id"`
+	ItemID  uuid.UUID    `json:"item_id"`
+	Slot    objects.Slot `json:"slot"`
+}
+
+// Actor returns the ID of the entity performing the request.
+func (req EquipRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req EquipRequest) EnergyCost() int {
+	return EquipCost
+}
+
+// Execute will attempt to equip the item in the requested slot.
+func (req EquipRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
+	actor, ok := world.Objects.FromID(req.ActorID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
+	}
+
+	item, ok := world.Items.FromID(req.ItemID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "item could not be found")
+	}
+
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	if !item.Fits(req.Slot) {
+		return world, nil, newError(responses.InvalidSlotError, "item cannot be equipped in the %s slot", req.Slot)
+	}
+
+	inventory, _ := actor.Inventory()
+	if !inventory.Take(item.ID) {
+		return world, nil, newError(responses.NotOwnedError, "item is not in the actor's inventory")
+	}
+
+	slots := []objects.Slot{req.Slot}
+	if item.TwoHanded {
+		slots = []objects.Slot{objects.PrimarySlot, objects.SecondarySlot}
+	}
+
+	// Whatever is in the way goes back into the inventory. A two-handed item
+	// in the way is removed from both hands.
+	equipment, _ := actor.Equipment()
+	for _, slot := range slots {
+		displaced, ok := equipment.Item(slot)
+		if !ok {
+			continue
+		}
+
+		for _, s := range equipment.Equipped(displaced) {
+			equipment.Put(s, uuid.Nil)
+		}
+		if !inventory.Add(displaced) {
+			return world, nil, newError(responses.InventoryFullError, "no room in the inventory for %s", displaced)
+		}
+	}
+
+	for _, slot := range slots {
+		equipment.Put(slot, item.ID)
+	}
+
+	actor.Set(inventory)
+	actor.Set(equipment)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	return world, responses.EquipmentResponse{
+		ActorID:   req.ActorID,
+		Equipment: equipment,
+		Inventory: inventory,
+	}, nil
+}
+
+// UnequipRequest represents a request to put the item equipped in one of the
+// actor's slots back into its inventory.
+type UnequipRequest struct {
+	ActorID uuid.UUID    `json:"actor_id"`
+	Slot    objects.Slot `json:"slot"`
+}
+
+// Actor returns the ID of the entity performing the request.
+func (req UnequipRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req UnequipRequest) EnergyCost() int {
+	return UnequipCost
+}
+
+// Execute will attempt to move the item in the requested slot into the
+// actor's inventory.
+func (req UnequipRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
+	actor, ok := world.Objects.FromID(req.ActorID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
+	}
+
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	equipment, _ := actor.Equipment()
+	id, ok := equipment.Item(req.Slot)
+	if !ok {
+		return world, nil, newError(responses.InvalidSlotError, "nothing is equipped in the %s slot", req.Slot)
+	}
+
+	inventory, ok := actor.Inventory()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "actor cannot carry items")
+	}
+	if !inventory.Add(id) {
+		return world, nil, newError(responses.InventoryFullError, "inventory is full")
+	}
+
+	for _, slot := range equipment.Equipped(id) {
+		equipment.Put(slot, uuid.Nil)
+	}
+
+	actor.Set(inventory)
+	actor.Set(equipment)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	return world, responses.EquipmentResponse{
+		ActorID:   req.ActorID,
+		Equipment: equipment,
+		Inventory: inventory,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package requests_test
+
+import (
+	"testing"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+func TestEquipmentRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		req      func(s stock) requests.Request
+		want     string
+		where    map[string]string
+	}{
+		{
+			"equipping an item in an empty slot",
+			5,
+			func(s stock) requests.Request {
+				return requests.EquipRequest{ActorID: s.actor, ItemID: s.items["helmet"], Slot: objects.HeadSlot}
+			},
+			"EquipmentResponse",
+			map[string]string{"helmet": "head"},
+		},
+		{
+			"equipping an item in a slot it does not fit",
+			5,
+			func(s stock) requests.Request {
+				return requests.EquipRequest{ActorID: s.actor, ItemID: s.items["helmet"], Slot: objects.ChestSlot}
+			},
+			string(responses.InvalidSlotError),
+			map[string]string{"helmet": "inventory"},
+		},
+		{
+			"equipping an item which is not in the inventory",
+			5,
+			func(s stock) requests.Request {
+				return requests.EquipRequest{ActorID: s.actor, ItemID: s.items["circlet"], Slot: objects.HeadSlot}
+			},
+			string(responses.NotOwnedError),
+			map[string]string{"circlet": "here"},
+		},
+		{
+			"equipping a two-handed item with both hands full",
+			5,
+			func(s stock) requests.Request {
+				return requests.EquipRequest{ActorID: s.actor, ItemID: s.items["axe"], Slot: objects.PrimarySlot}
+			},
+			"EquipmentResponse",
+			map[string]string{"axe": "primary+secondary", "sword": "inventory", "shield": "inventory"},
+		},
+		{
+			"equipping a two-handed item in the off-hand",
+			5,
+			func(s stock) requests.Request {
+				return requests.EquipRequest{ActorID: s.actor, ItemID: s.items["axe"], Slot: objects.SecondarySlot}
+			},
+			"EquipmentResponse",
+			map[string]string{"axe": "primary+secondary", "sword": "inventory", "shield": "inventory"},
+		},
+		{
+			"equipping a two-handed item without room for what the hands hold",
+			3,
+			func(s stock) requests.Request {
+				return requests.EquipRequest{ActorID: s.actor, ItemID: s.items["axe"], Slot: objects.PrimarySlot}
+			},
+			string(responses.InventoryFullError),
+			map[string]string{"axe": "inventory", "sword": "primary", "shield": "secondary"},
+		},
+		{
+			"unequipping an item",
+			5,
+			func(s stock) requests.Request {
+				return requests.UnequipRequest{ActorID: s.actor, Slot: objects.PrimarySlot}
+			},
+			"EquipmentResponse",
+			map[string]string{"sword": "inventory", "shield": "secondary"},
+		},
+		{
+			"unequipping an empty slot",
+			5,
+			func(s stock) requests.Request {
+				return requests.UnequipRequest{ActorID: s.actor, Slot: objects.HeadSlot}
+			},
+			string(responses.InvalidSlotError),
+			map[string]string{"helmet": "inventory"},
+		},
+		{
+			"unequipping an item into a full inventory",
+			3,
+			func(s stock) requests.Request {
+				return requests.UnequipRequest{ActorID: s.actor, Slot: objects.SecondarySlot}
+			},
+			string(responses.InventoryFullError),
+			map[string]string{"shield": "secondary"},
+		},
+	}
+
+	for _, test := range tests {
+		s := stocked(test.capacity)
+
+		got := s.perform(test.req(s))
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+
+		for item, want := range test.where {
+			if where := s.locate(item); where != want {
+				t.Errorf("%s: %s is at %q, want %q", test.name, item, where, want)
+			}
+		}
+	}
+}
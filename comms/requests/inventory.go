@@ -0,0 +1,264 @@
+package requests
+
+import (
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// adjacent returns true when the positions are the same or next to each
+// other, including diagonally.
+func adjacent(a, b objects.Position) bool {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+
+	return dx >= -1 && dx <= 1 && dy >= -1 && dy <= 1
+}
+
+// holder returns the entity whose inventory holds the item, if any.
+func holder(world entities.World, itemID uuid.UUID) (objects.Entity, bool) {
+	for _, e := range world.Objects.With(objects.InventoryComponent) {
+		if inventory, _ := e.Inventory(); inventory.Contains(itemID) {
+			return e, true
+		}
+	}
+
+	return objects.Entity{}, false
+}
+
+// Kill removes the entity from the world, such as when its health runs out,
+// dropping its belongings where it stood.
+func Kill(world entities.World, e objects.Entity) entities.World {
+	world = dropBelongings(world, e)
+	world.Objects = world.Objects.MustRemove(e)
+
+	return world
+}
+
+// dropBelongings puts every item held or equipped by the entity onto the
+// ground where it stands, such as when it is killed, so that the items are not
+// lost with it.
+func dropBelongings(world entities.World, e objects.Entity) entities.World {
+	pos, ok := e.Position()
+	if !ok {
+		return world
+	}
+
+	inventory, _ := e.Inventory()
+	ids := append([]uuid.UUID(nil), inventory.ItemIDs...)
+
+	// Two-handed items appear in both hands, but are simply dropped twice.
+	equipment, _ := e.Equipment()
+	for _, slot := range objects.Slots() {
+		if id, ok := equipment.Item(slot); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range ids {
+		if item, ok := world.Items.FromID(id); ok {
+			dropped := pos
+			item.Position = &dropped
+			world.Items.Insert(item)
+		}
+	}
+
+	return world
+}
+
+// PickUpRequest represents a request to pick up an item lying on the ground
+// where the actor stands, putting it into the actor's inventory.
+type PickUpRequest struct {
+	ActorID uuid.UUID `json:"actor_id"`
+	ItemID  uuid.UUID `json:"item_id"`
+}
+
+// Actor returns the ID of the entity performing the request.
+func (req PickUpRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req PickUpRequest) EnergyCost() int {
+	return PickUpCost
+}
+
+// Execute will attempt to move the item from the ground into the actor's
+// inventory.
+func (req PickUpRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
+	actor, ok := world.Objects.FromID(req.ActorID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
+	}
+
+	item, ok := world.Items.FromID(req.ItemID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "item could not be found")
+	}
+
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	inventory, ok := actor.Inventory()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "actor cannot carry items")
+	}
+
+	pos, ok := actor.Position()
+	if !ok || !item.OnGround() || *item.Position != pos {
+		return world, nil, newError(responses.InvalidTargetError, "item is not on the ground here")
+	}
+	if owner, ok := holder(world, item.ID); ok {
+		return world, nil, newError(responses.NotOwnedError, "item is held by %s", owner.ID)
+	}
+
+	if !inventory.Add(item.ID) {
+		return world, nil, newError(responses.InventoryFullError, "inventory is full")
+	}
+
+	actor.Set(inventory)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	item.Position = nil
+	world.Items.Insert(item)
+
+	return world, responses.PickUpResponse{
+		ActorID: req.ActorID,
+		ItemID:  req.ItemID,
+	}, nil
+}
+
+// DropRequest represents a request to drop an item from the actor's inventory
+// onto the ground where the actor stands.
+type DropRequest struct {
+	ActorID uuid.UUID `json:"actor_id"`
+	ItemID  uuid.UUID `json:"item_id"`
+}
+
+// Actor returns the ID of the entity performing the request.
+func (req DropRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req DropRequest) EnergyCost() int {
+	return DropCost
+}
+
+// Execute will attempt to move the item from the actor's inventory onto the
+// ground.
+func (req DropRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
+	actor, ok := world.Objects.FromID(req.ActorID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
+	}
+
+	item, ok := world.Items.FromID(req.ItemID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "item could not be found")
+	}
+
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	pos, ok := actor.Position()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "actor has no position to drop items at")
+	}
+
+	inventory, _ := actor.Inventory()
+	if !inventory.Take(item.ID) {
+		return world, nil, newError(responses.NotOwnedError, "item is not in the actor's inventory")
+	}
+
+	actor.Set(inventory)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	item.Position = &pos
+	world.Items.Insert(item)
+
+	return world, responses.DropResponse{
+		ActorID: req.ActorID,
+		ItemID:  req.ItemID,
+		X:       pos.X,
+		Y:       pos.Y,
+	}, nil
+}
+
+// GiveRequest represents a request to hand an item from the actor's inventory
+// to an adjacent target.
+type GiveRequest struct {
+	ActorID  uuid.UUID `json:"actor_id"`
+	TargetID uuid.UUID `json:"target_id"`
+	ItemID   uuid.UUID `json:"item_id"`
+}
+
+// Actor returns the ID of the entity performing the request.
+func (req GiveRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req GiveRequest) EnergyCost() int {
+	return GiveCost
+}
+
+// Execute will attempt to move the item from the actor's inventory into the
+// target's inventory.
+func (req GiveRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
+	actor, ok := world.Objects.FromID(req.ActorID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
+	}
+
+	target, ok := world.Objects.FromID(req.TargetID)
+	if !ok {
+		return world, nil, newError(responses.NotFoundError, "target could not be found")
+	}
+
+	if uuid.Equal(actor.ID, target.ID) {
+		return world, nil, newError(responses.InvalidTargetError, "cannot give an item to oneself")
+	}
+
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
+	}
+
+	actorPos, ok := actor.Position()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "actor has no position")
+	}
+	targetPos, ok := target.Position()
+	if !ok || !adjacent(actorPos, targetPos) {
+		return world, nil, newError(responses.InvalidTargetError, "target is not within reach")
+	}
+
+	actorInventory, _ := actor.Inventory()
+	if !actorInventory.Take(req.ItemID) {
+		return world, nil, newError(responses.NotOwnedError, "item is not in the actor's inventory")
+	}
+
+	targetInventory, ok := target.Inventory()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "target cannot carry items")
+	}
+	if !targetInventory.Add(req.ItemID) {
+		return world, nil, newError(responses.InventoryFullError, "target's inventory is full")
+	}
+
+	actor.Set(actorInventory)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	target.Set(targetInventory)
+	world.Objects = world.Objects.MustUpdate(target)
+
+	return world, responses.GiveResponse{
+		ActorID:  req.ActorID,
+		TargetID: req.TargetID,
+		ItemID:   req.ItemID,
+	}, nil
+}
@@ -0,0 +1,234 @@
+package requests_test
+
+import (
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/items"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// stock is a world in which the actor carries a coin, a helmet and a
+// two-handed axe, holding a sword and a shield. A circlet lies where the actor
+// stands and a rock lies elsewhere, while another actor next to it carries a
+// ring.
+type stock struct {
+	world entities.World
+	actor uuid.UUID
+	other uuid.UUID
+	items map[string]uuid.UUID
+}
+
+// stocked returns the stock, with the actor able to carry capacity items.
+func stocked(capacity int) stock {
+	s := stock{
+		world: entities.MakeWorld(),
+		items: make(map[string]uuid.UUID),
+	}
+
+	here := objects.Position{X: 1, Y: 1}
+	elsewhere := objects.Position{X: 0, Y: 0}
+	add := func(name string, slot objects.Slot, pos *objects.Position) uuid.UUID {
+		item := items.Item{ID: uuid.Must(uuid.NewV4()), Slot: slot, Position: pos}
+		item.TwoHanded = name == "axe"
+		s.world.Items.Insert(item)
+		s.items[name] = item.ID
+		return item.ID
+	}
+
+	actor := objects.New()
+	actor.Set(here)
+	actor.Set(objects.Inventory{
+		ItemIDs: []uuid.UUID{
+			add("coin", "", nil),
+			add("helmet", objects.HeadSlot, nil),
+			add("axe", objects.PrimarySlot, nil),
+		},
+		Capacity: capacity,
+	})
+	actor.Set(objects.Equipment{
+		PrimaryItemID:   add("sword", objects.PrimarySlot, nil),
+		SecondaryItemID: add("shield", objects.SecondarySlot, nil),
+	})
+	s.world.Objects = s.world.Objects.Append(*actor)
+	s.actor = actor.ID
+
+	other := objects.New()
+	other.Set(objects.Position{X: 2, Y: 1})
+	other.Set(objects.Inventory{ItemIDs: []uuid.UUID{add("ring", objects.HandsSlot, nil)}, Capacity: 2})
+	s.world.Objects = s.world.Objects.Append(*other)
+	s.other = other.ID
+
+	add("circlet", objects.HeadSlot, &here)
+	add("rock", "", &elsewhere)
+
+	return s
+}
+
+// locate describes where the named item is: in the inventory of the actor or
+// the other actor, in the actor's equipment slots, on the ground where the
+// actor stands, or on the ground elsewhere.
+func (s stock) locate(name string) string {
+	id := s.items[name]
+
+	actor, _ := s.world.Objects.FromID(s.actor)
+	if inventory, _ := actor.Inventory(); inventory.Contains(id) {
+		return "inventory"
+	}
+	if equipment, _ := actor.Equipment(); len(equipment.Equipped(id)) > 0 {
+		slots := make([]string, 0)
+		for _, slot := range equipment.Equipped(id) {
+			slots = append(slots, string(slot))
+		}
+		return strings.Join(slots, "+")
+	}
+
+	other, _ := s.world.Objects.FromID(s.other)
+	if inventory, _ := other.Inventory(); inventory.Contains(id) {
+		return "other"
+	}
+
+	item, _ := s.world.Items.FromID(id)
+	if pos, _ := actor.Position(); item.OnGround() && *item.Position == pos {
+		return "here"
+	}
+	if item.OnGround() {
+		return "ground"
+	}
+
+	return ""
+}
+
+// perform executes the request against the stock, returning the name of the
+// response, or the code of the error.
+func (s *stock) perform(req requests.Request) string {
+	world, resp, err := req.Execute(requests.NewContext(s.world), s.world)
+	if err != nil {
+		return string(requests.NewErrorResponse(req, err).Code)
+	}
+
+	s.world = world
+	name, _ := responses.NameOf(resp)
+	return name
+}
+
+func TestInventoryRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		req      func(s stock) requests.Request
+		want     string
+		where    map[string]string
+	}{
+		{
+			"picking up an item where the actor stands",
+			5,
+			func(s stock) requests.Request {
+				return requests.PickUpRequest{ActorID: s.actor, ItemID: s.items["circlet"]}
+			},
+			"PickUpResponse",
+			map[string]string{"circlet": "inventory"},
+		},
+		{
+			"picking up an item from another cell",
+			5,
+			func(s stock) requests.Request {
+				return requests.PickUpRequest{ActorID: s.actor, ItemID: s.items["rock"]}
+			},
+			string(responses.InvalidTargetError),
+			map[string]string{"rock": "ground"},
+		},
+		{
+			"picking up an item into a full inventory",
+			3,
+			func(s stock) requests.Request {
+				return requests.PickUpRequest{ActorID: s.actor, ItemID: s.items["circlet"]}
+			},
+			string(responses.InventoryFullError),
+			map[string]string{"circlet": "here"},
+		},
+		{
+			"picking up an item someone is carrying",
+			5,
+			func(s stock) requests.Request {
+				return requests.PickUpRequest{ActorID: s.actor, ItemID: s.items["ring"]}
+			},
+			string(responses.InvalidTargetError),
+			map[string]string{"ring": "other"},
+		},
+		{
+			"dropping an item from the inventory",
+			5,
+			func(s stock) requests.Request {
+				return requests.DropRequest{ActorID: s.actor, ItemID: s.items["coin"]}
+			},
+			"DropResponse",
+			map[string]string{"coin": "here"},
+		},
+		{
+			"dropping an equipped item",
+			5,
+			func(s stock) requests.Request {
+				return requests.DropRequest{ActorID: s.actor, ItemID: s.items["sword"]}
+			},
+			string(responses.NotOwnedError),
+			map[string]string{"sword": "primary"},
+		},
+		{
+			"dropping an item the actor does not carry",
+			5,
+			func(s stock) requests.Request {
+				return requests.DropRequest{ActorID: s.actor, ItemID: s.items["ring"]}
+			},
+			string(responses.NotOwnedError),
+			map[string]string{"ring": "other"},
+		},
+		{
+			"giving an item to an adjacent actor",
+			5,
+			func(s stock) requests.Request {
+				return requests.GiveRequest{ActorID: s.actor, TargetID: s.other, ItemID: s.items["coin"]}
+			},
+			"GiveResponse",
+			map[string]string{"coin": "other"},
+		},
+		{
+			"giving an equipped item",
+			5,
+			func(s stock) requests.Request {
+				return requests.GiveRequest{ActorID: s.actor, TargetID: s.other, ItemID: s.items["shield"]}
+			},
+			string(responses.NotOwnedError),
+			map[string]string{"shield": "secondary"},
+		},
+		{
+			"giving an item to oneself",
+			5,
+			func(s stock) requests.Request {
+				return requests.GiveRequest{ActorID: s.actor, TargetID: s.actor, ItemID: s.items["coin"]}
+			},
+			string(responses.InvalidTargetError),
+			map[string]string{"coin": "inventory"},
+		},
+	}
+
+	for _, test := range tests {
+		s := stocked(test.capacity)
+
+		got := s.perform(test.req(s))
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+
+		for item, want := range test.where {
+			if where := s.locate(item); where != want {
+				t.Errorf("%s: %s is at %q, want %q", test.name, item, where, want)
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package requests
 
 import (
-	"github.com/go-errors/errors"
 	uuid "github.com/satori/go.uuid"
 
 	"github.com/clagraff/pitch/comms/responses"
@@ -9,68 +8,148 @@ import (
 	"github.com/clagraff/pitch/entities/objects"
 )
 
-func coordsFromDirection(actor objects.Entity, direction Direction) (int, int) {
-	x := actor.Position.X
-	y := actor.Position.Y
-
-	switch direction {
-	case North:
-		y--
-	case East:
-		x++
-	case South:
-		y++
-	case West:
-		x--
+func coordsFromDirection(pos objects.Position, direction Direction) (int, int) {
+	dx, dy := direction.Offset()
+	return pos.X + dx, pos.Y + dy
+}
+
+// doorway returns true when there is a door at the position, whether open or
+// closed.
+func doorway(world entities.World, x, y int) bool {
+	for _, e := range world.Objects.FromXY(x, y) {
+		if passability, ok := e.Passability(); ok && passability.Type == objects.Toggleable {
+			return true
+		}
 	}
 
-	return x, y
+	return false
+}
+
+// walled returns true when there is a static entity at the position which
+// cannot be passed, such as a wall or a closed door. Actors do not count, as
+// they can be stepped around.
+func walled(world entities.World, x, y int) bool {
+	for _, e := range world.Objects.FromXY(x, y) {
+		if !e.Static() {
+			continue
+		}
+
+		passability, ok := e.Passability()
+		if !ok {
+			continue
+		}
+		if passability.Type == objects.AlwaysImpassible {
+			return true
+		}
+		if passability.Type == objects.Toggleable && !passability.IsOpen {
+			return true
+		}
+	}
+
+	return false
 }
 
 // MoveRequest is used to request that the specified target moves in a desired
 // direction.
 // This action assumes changing by 1 unit-space at a time. Hense the use of a
 // `direction` rather than xy-position.
+//
+// Moving into an impassible entity attacks it, and moving into a closed door
+// opens it. Diagonal moves cannot cut the corner of a wall or closed door, and
+// doorways can only be entered and left orthogonally. The same goes for the
+// attacks and doors which moves lead to, so neither can be made diagonally
+// where the move could not.
 type MoveRequest struct {
 	ActorID   uuid.UUID `json:"actor_id"`
 	Direction Direction `json:"direction"`
 }
 
+// Actor returns the ID of the entity performing the request.
+func (req MoveRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+// EnergyCost returns the energy used by the request in turn-based worlds.
+func (req MoveRequest) EnergyCost() int {
+	return MoveCost
+}
+
+// OutcomeCost returns the energy used by the move, given its response. Moves
+// which bump into a door or another entity cost as much as opening the door or
+// attacking the entity.
+func (req MoveRequest) OutcomeCost(resp responses.Response) int {
+	switch resp.(type) {
+	case responses.ToggleResponse:
+		return OpenCost
+	case responses.MeleeAttackResponse:
+		return AttackCost
+	}
+
+	return MoveCost
+}
+
 // Execute will perform the movement request for the specified actor.
-func (req MoveRequest) Execute(world entities.World) (entities.World, responses.Response, error) {
+func (req MoveRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
 	actor, ok := world.Objects.FromID(req.ActorID)
 	if !ok {
-		return world, nil, errors.Errorf("actor %s could not be found", req.ActorID)
+		return world, nil, newError(responses.NotFoundError, "actor %s could not be found", req.ActorID)
+	}
+
+	pos, ok := actor.Position()
+	if !ok {
+		return world, nil, newError(responses.InvalidTargetError, "actor %s has no position", req.ActorID)
+	}
+
+	if timer, _ := actor.Timer(); !timer.Ready(world.Tick) {
+		return world, nil, newError(responses.NotReadyError, "timer not ready")
 	}
 
-	x, y := coordsFromDirection(actor, req.Direction)
+	if !req.Direction.Valid() {
+		return world, nil, newError(responses.MalformedRequestError, "invalid direction: %d", req.Direction)
+	}
+
+	x, y := coordsFromDirection(pos, req.Direction)
+
+	// Attacking or opening a door is subject to the same rules as moving.
+	if req.Direction.Diagonal() {
+		if doorway(world, pos.X, pos.Y) || doorway(world, x, y) {
+			return world, nil, newError(responses.BlockedError, "doorways cannot be entered or left diagonally")
+		}
+		if walled(world, x, pos.Y) || walled(world, pos.X, y) {
+			return world, nil, newError(responses.BlockedError, "cannot cut the corner")
+		}
+	}
 
 	// It is okay if there are no objects at the new coords. That is why we
 	// ignore the second return arg.
 	nearbyEntities := world.Objects.FromXY(x, y)
 	for _, e := range nearbyEntities {
-		if e.Passability.Type == objects.AlwaysImpassible {
+		passability, ok := e.Passability()
+		if !ok {
+			continue
+		}
+
+		if passability.Type == objects.AlwaysImpassible {
 			attackReq := MeleeAttackRequest{
 				AttackerID: actor.ID,
 				TargetID:   e.ID,
 			}
-			world, resp, err := attackReq.Execute(world)
+			world, resp, err := attackReq.Execute(ctx, world)
 			return world, resp, err
 		}
-		if e.Passability.Type == objects.Toggleable {
-			if !e.Passability.IsOpen {
+		if passability.Type == objects.Toggleable {
+			if !passability.IsOpen {
 				openReq := OpenRequest{
 					ActorID:  actor.ID,
 					TargetID: e.ID,
 				}
-				world, resp, err := openReq.Execute(world)
+				world, resp, err := openReq.Execute(ctx, world)
 				return world, resp, err
 			}
 		}
 	}
 
-	actor.Position.X = x
-	actor.Position.Y = y
+	actor.Set(objects.Position{X: x, Y: y})
 
 	world.Objects = world.Objects.MustUpdate(actor)
 
@@ -0,0 +1,170 @@
+package requests_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/logging"
+)
+
+// build returns a world made from a small fixed map, in which # is a wall, +
+// is a closed door, ' is an open door and o is another actor. The actor is
+// either @, or & when standing in an open doorway, and is returned too.
+func build(m string) (entities.World, objects.Entity) {
+	world := entities.MakeWorld()
+	actor := objects.Entity{}
+
+	for y, row := range strings.Split(strings.TrimPrefix(m, "\n"), "\n") {
+		for x, c := range row {
+			door := func(open bool) {
+				e := objects.New()
+				e.Set(objects.Position{X: x, Y: y})
+				e.Set(objects.Passability{Type: objects.Toggleable, IsOpen: open})
+				e.Set(objects.Static{})
+				world.Objects = world.Objects.Append(*e)
+			}
+
+			switch c {
+			case '#':
+				e := objects.New()
+				e.Set(objects.Position{X: x, Y: y})
+				e.Set(objects.Passability{Type: objects.AlwaysImpassible})
+				e.Set(objects.Health{Current: 100, Max: 100})
+				e.Set(objects.Static{})
+				world.Objects = world.Objects.Append(*e)
+			case '+':
+				door(false)
+			case '\'':
+				door(true)
+			case 'o', '@', '&':
+				if c == '&' {
+					door(true)
+				}
+				e := objects.New()
+				e.Set(objects.Position{X: x, Y: y})
+				e.Set(objects.Passability{Type: objects.AlwaysImpassible})
+				e.Set(objects.Health{Current: 10, Max: 10})
+				world.Objects = world.Objects.Append(*e)
+				if c != 'o' {
+					actor = *e
+				}
+			}
+		}
+	}
+
+	return world, actor
+}
+
+func TestMoveRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = logging.SetDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		m         string
+		direction requests.Direction
+		want      string
+		moved     bool
+		cost      int
+	}{
+		{"moving onto open ground", `
+...
+.@.
+...`, requests.North, "MoveResponse", true, requests.MoveCost},
+		{"moving diagonally onto open ground", `
+...
+.@.
+...`, requests.NorthEast, "MoveResponse", true, requests.MoveCost},
+		{"cutting the corner of a wall", `
+.#.
+.@.
+...`, requests.NorthEast, string(responses.BlockedError), false, 0},
+		{"cutting the corner of a closed door", `
+...
+.@+
+...`, requests.NorthEast, string(responses.BlockedError), false, 0},
+		{"passing the corner of an open door", `
+...
+.@'
+...`, requests.NorthEast, "MoveResponse", true, requests.MoveCost},
+		{"stepping around another actor", `
+.o.
+.@.
+...`, requests.NorthEast, "MoveResponse", true, requests.MoveCost},
+		{"entering a doorway orthogonally", `
+.'.
+.@.
+...`, requests.North, "MoveResponse", true, requests.MoveCost},
+		{"entering a doorway diagonally", `
+..'
+.@.
+...`, requests.NorthEast, string(responses.BlockedError), false, 0},
+		{"leaving a doorway diagonally", `
+...
+.&.
+...`, requests.NorthEast, string(responses.BlockedError), false, 0},
+		{"bumping into another actor attacks it", `
+.o.
+.@.
+...`, requests.North, "MeleeAttackResponse", false, requests.AttackCost},
+		{"bumping diagonally into another actor attacks it", `
+..o
+.@.
+...`, requests.NorthEast, "MeleeAttackResponse", false, requests.AttackCost},
+		{"attacking around the corner of a wall", `
+.#o
+.@.
+...`, requests.NorthEast, string(responses.BlockedError), false, 0},
+		{"bumping into a closed door opens it", `
+.+.
+.@.
+...`, requests.North, "ToggleResponse", false, requests.OpenCost},
+		{"bumping diagonally into a closed door", `
+..+
+.@.
+...`, requests.NorthEast, string(responses.BlockedError), false, 0},
+	}
+
+	for _, test := range tests {
+		world, actor := build(test.m)
+		start, _ := actor.Position()
+
+		req := requests.MoveRequest{ActorID: actor.ID, Direction: test.direction}
+		world, resp, err := req.Execute(requests.NewContext(world), world)
+
+		got := ""
+		if err != nil {
+			got = string(requests.NewErrorResponse(req, err).Code)
+		} else {
+			got, _ = responses.NameOf(resp)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+		if err == nil {
+			if cost := requests.SpentEnergy(req, resp); cost != test.cost {
+				t.Errorf("%s: spent %d energy, want %d", test.name, cost, test.cost)
+			}
+		}
+
+		actor, _ = world.Objects.FromID(actor.ID)
+		pos, _ := actor.Position()
+		if moved := pos != start; moved != test.moved {
+			t.Errorf("%s: actor moved from %+v to %+v, want moved %t", test.name, start, pos, test.moved)
+		}
+	}
+}
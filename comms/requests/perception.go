@@ -1,12 +1,12 @@
 package requests
 
 import (
-	"github.com/go-errors/errors"
 	uuid "github.com/satori/go.uuid"
 
 	"github.com/clagraff/pitch/comms/responses"
 	"github.com/clagraff/pitch/entities"
 	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/fov"
 	"github.com/clagraff/pitch/logging"
 )
 
@@ -15,44 +15,97 @@ type ViewRequest struct {
 	ActorID uuid.UUID `json:"actor_id"`
 }
 
+// Actor returns the ID of the entity performing the request.
+func (req ViewRequest) Actor() uuid.UUID {
+	return req.ActorID
+}
+
 // Execute will find and return a response containing perceivable objects.
-func (req ViewRequest) Execute(world entities.World) (entities.World, responses.Response, error) {
+func (req ViewRequest) Execute(ctx Context, world entities.World) (entities.World, responses.Response, error) {
 	logger, closeLog := logging.Logger("requests.ViewRequest.Execute")
 	defer closeLog()
 
 	actor, ok := world.Objects.FromID(req.ActorID)
 	if !ok {
-		return world, nil, errors.Errorf("actor could not be found")
-	}
-
-	if !actor.Timer.Ready() {
-		return world, nil, errors.Errorf("timer not ready")
+		return world, nil, newError(responses.NotFoundError, "actor could not be found")
 	}
 
 	logger.Println("Requestor ID:", req.ActorID.String())
 
 	resp := responses.ViewResponse{}
 	resp.ActorID = req.ActorID
-	resp.Objects = make([]objects.Entity, 0)
+	resp.Objects = Perceive(world, actor)
+	for _, e := range resp.Objects {
+		if e.Static() {
+			resp.Static = append(resp.Static, e.ID)
+		}
+	}
+
+	logger.Println("Number of perceivable objects:", len(resp.Objects))
 
-	viewDist := actor.Attributes.Wisdom.Modifier() + 10
-	logger.Println("View distance:", viewDist)
+	return world, resp, nil
+}
 
-	startX := actor.Position.X - viewDist
-	endX := actor.Position.X + viewDist
+// ViewDistance returns how far the actor can see.
+func ViewDistance(actor objects.Entity) int {
+	attributes, _ := actor.Attributes()
+	return attributes.Wisdom.Modifier() + 10
+}
 
-	startY := actor.Position.Y - viewDist
-	endY := actor.Position.Y + viewDist
+// FieldOfView returns the positions the actor is able to see, out to its view
+// distance. Sight is blocked by impassible entities and closed doors, though
+// they can themselves be seen.
+func FieldOfView(world entities.World, actor objects.Entity) fov.Visible {
+	pos, ok := actor.Position()
+	if !ok {
+		return fov.Visible{}
+	}
 
-	for x := startX; x < endX; x++ {
-		for y := startY; y < endY; y++ {
-			nearbyEntities := world.Objects.FromXY(x, y)
-			for _, obj := range nearbyEntities {
-				logger.Println("Object within view distance:", obj.ID.String())
-				resp.Objects = append(resp.Objects, obj)
+	return fov.Compute(pos, ViewDistance(actor), func(p objects.Position) bool {
+		for _, e := range world.Objects.FromXY(p.X, p.Y) {
+			if passability, ok := e.Passability(); ok && passability.BlocksSight() {
+				return true
 			}
 		}
+		return false
+	})
+}
+
+// Perceive returns the objects in the world which the actor is able to see.
+func Perceive(world entities.World, actor objects.Entity) []objects.Entity {
+	pos, ok := actor.Position()
+	if !ok {
+		return make([]objects.Entity, 0)
+	}
+
+	visible := FieldOfView(world, actor)
+	viewDist := ViewDistance(actor)
+
+	min := objects.Position{
+		X: pos.X - viewDist,
+		Y: pos.Y - viewDist,
+	}
+	max := objects.Position{
+		X: pos.X + viewDist,
+		Y: pos.Y + viewDist,
+	}
+
+	perceived := make([]objects.Entity, 0)
+	for _, e := range world.Objects.FromArea(min, max) {
+		if p, _ := e.Position(); visible.Contains(p) {
+			perceived = append(perceived, e)
+		}
 	}
 
-	return world, resp, nil
+	return perceived
+}
+
+// InSight returns true when the actor can see the target.
+func InSight(world entities.World, actor, target objects.Entity) bool {
+	pos, ok := target.Position()
+	if !ok {
+		return false
+	}
+
+	return FieldOfView(world, actor).Contains(pos)
 }
@@ -0,0 +1,37 @@
+package requests_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/generation"
+)
+
+func BenchmarkPerceive(b *testing.B) {
+	// Open doors do not block sight, so the actor's whole view is populated.
+	source := objects.Entity{}
+	source.Set(objects.Passability{Type: objects.Toggleable, IsOpen: true})
+	source.Set(objects.Health{Current: 10, Max: 10})
+
+	// 316 by 316 holds roughly 100,000 entities.
+	for _, size := range []int{10, 100, 316} {
+		world := entities.MakeWorld()
+		for _, e := range generation.Fill(source, size, size) {
+			world.Objects = world.Objects.Append(e)
+		}
+
+		actor := objects.New()
+		actor.Set(objects.Position{X: size / 2, Y: size / 2})
+		actor.Set(objects.Attributes{Wisdom: 10})
+		world.Objects = world.Objects.Append(*actor)
+
+		b.Run(fmt.Sprintf("%d", world.Objects.Len()), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				requests.Perceive(world, *actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package requests
+
+import (
+	"reflect"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/utils"
+)
+
+// Factory returns a pointer to a new, zero-valued request, into which a
+// request of its type can be unmarshalled.
+type Factory func() Request
+
+var registry = utils.NewRegistry("request type")
+
+var requestType = reflect.TypeOf((*Request)(nil)).Elem()
+
+func init() {
+	MustRegister("MeleeAttackRequest", func() Request { return &MeleeAttackRequest{} })
+	MustRegister("RangeAttackRequest", func() Request { return &RangeAttackRequest{} })
+	MustRegister("CloseRequest", func() Request { return &CloseRequest{} })
+	MustRegister("MoveRequest", func() Request { return &MoveRequest{} })
+	MustRegister("OpenRequest", func() Request { return &OpenRequest{} })
+	MustRegister("ViewRequest", func() Request { return &ViewRequest{} })
+	MustRegister("PickUpRequest", func() Request { return &PickUpRequest{} })
+	MustRegister("DropRequest", func() Request { return &DropRequest{} })
+	MustRegister("GiveRequest", func() Request { return &GiveRequest{} })
+	MustRegister("EquipRequest", func() Request { return &EquipRequest{} })
+	MustRegister("UnequipRequest", func() Request { return &UnequipRequest{} })
+}
+
+// Register makes a request type available to Marshal and Unmarshal under the
+// provided name. The factory must return a pointer to the request type, which
+// must implement Request on its value rather than only its pointer, as requests
+// are passed around as values. An error is returned if either the name or the
+// request type has already been registered.
+func Register(name string, factory Factory) error {
+	t := reflect.TypeOf(factory())
+	if t != nil && t.Kind() == reflect.Ptr && !t.Elem().Implements(requestType) {
+		return errors.Errorf("request type %s must implement Request on its value, not only its pointer", t.Elem())
+	}
+
+	return registry.Register(name, func() interface{} { return factory() })
+}
+
+// MustRegister is like Register, but panics if the request type cannot be
+// registered. It is intended to be called from package init functions.
+func MustRegister(name string, factory Factory) {
+	err := Register(name, factory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Names returns the sorted names of every registered request type.
+func Names() []string {
+	return registry.Names()
+}
+
+// NameOf returns the name the request's type was registered under, and
+// whether it has been registered at all.
+func NameOf(req Request) (string, bool) {
+	return registry.NameOf(req)
+}
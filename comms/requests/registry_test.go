@@ -0,0 +1,34 @@
+package requests_test
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+)
+
+// pointerRequest only implements Request on its pointer, so it could not be
+// passed around as a value once decoded.
+type pointerRequest struct{}
+
+func (req *pointerRequest) Actor() uuid.UUID {
+	return uuid.Nil
+}
+
+func (req *pointerRequest) Execute(ctx requests.Context, world entities.World) (entities.World, responses.Response, error) {
+	return world, nil, nil
+}
+
+func TestRegisterPointerReceiver(t *testing.T) {
+	err := requests.Register("PointerRequest", func() requests.Request { return &pointerRequest{} })
+	if err == nil {
+		t.Error("registered a request type which only implements Request on its pointer")
+	}
+
+	if _, ok := requests.NameOf(&pointerRequest{}); ok {
+		t.Error("rejected request type was registered anyway")
+	}
+}
@@ -1,135 +1,315 @@
 package requests
 
 import (
-	"encoding/json"
+	"fmt"
 	"reflect"
 
 	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
 
+	"github.com/clagraff/pitch/comms/codec"
 	"github.com/clagraff/pitch/comms/responses"
 	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
 	"github.com/clagraff/pitch/logging"
 )
 
 // Direction represents a 2D dimensional axis.
 type Direction int
 
-// The 4 valid directions.
-// TODO: support diagnol directions.
+// The 8 valid directions. The diagonal directions follow the original four,
+// so that existing requests keep their meaning.
 const (
 	North Direction = iota
 	East
 	South
 	West
+	NorthEast
+	SouthEast
+	SouthWest
+	NorthWest
 )
 
+// Valid returns true when the direction is one of the 8 valid directions.
+func (d Direction) Valid() bool {
+	return d >= North && d <= NorthWest
+}
+
+// Diagonal returns true for the directions between the four compass points.
+func (d Direction) Diagonal() bool {
+	return d >= NorthEast && d <= NorthWest
+}
+
+// Offset returns how much moving one step in the direction changes the x and y
+// coordinates. North is towards smaller y coordinates.
+func (d Direction) Offset() (int, int) {
+	switch d {
+	case North:
+		return 0, -1
+	case East:
+		return 1, 0
+	case South:
+		return 0, 1
+	case West:
+		return -1, 0
+	case NorthEast:
+		return 1, -1
+	case SouthEast:
+		return 1, 1
+	case SouthWest:
+		return -1, 1
+	case NorthWest:
+		return -1, -1
+	}
+
+	return 0, 0
+}
+
+// DirectionOf returns the direction which moves one step by the offset, if
+// there is one.
+func DirectionOf(dx, dy int) (Direction, bool) {
+	for d := North; d <= NorthWest; d++ {
+		if x, y := d.Offset(); x == dx && y == dy {
+			return d, true
+		}
+	}
+
+	return North, false
+}
+
 // Request is an interface used to specify desired actions from clients to the
 // main server.
 type Request interface {
-	Execute(entities.World) (entities.World, responses.Response, error)
+	Actor() uuid.UUID
+	Execute(Context, entities.World) (entities.World, responses.Response, error)
 }
 
-type payload struct {
-	Type    string          `json:"type"`
-	Request json.RawMessage `json:"request"`
+// Energy costs of actions in turn-based worlds. An actor with the usual speed
+// gains enough energy for a move every ten ticks.
+const (
+	MoveCost    = 100
+	AttackCost  = 100
+	OpenCost    = 50
+	CloseCost   = 50
+	PickUpCost  = 50
+	DropCost    = 50
+	GiveCost    = 50
+	EquipCost   = 100
+	UnequipCost = 50
+)
+
+// Action is implemented by requests which use up the actor's energy when
+// performed in turn-based worlds. Requests which are not actions are free.
+type Action interface {
+	Request
+	EnergyCost() int
 }
 
-// Unmarshal a bites into a payload which can be used to return the
-// embedded request.
-func Unmarshal(bites []byte) (Request, error) {
-	logger, closeLog := logging.Logger("comms.requests.Unmarshal")
-	defer closeLog()
+// EnergyCost returns how much energy performing the request costs.
+func EnergyCost(req Request) int {
+	if action, ok := req.(Action); ok {
+		return action.EnergyCost()
+	}
 
-	logger.Println("received request json to unmarshal")
+	return 0
+}
 
-	p := payload{}
-	err := json.Unmarshal(bites, &p)
-	if err != nil {
-		return nil, errors.New(err)
+// Outcome is implemented by actions whose cost depends on what performing them
+// led to, such as a move which only opens a door.
+type Outcome interface {
+	Action
+	OutcomeCost(responses.Response) int
+}
+
+// SpentEnergy returns how much energy performing the request used, given the
+// response it gave. This is its EnergyCost, unless it is an Outcome.
+func SpentEnergy(req Request, resp responses.Response) int {
+	if outcome, ok := req.(Outcome); ok {
+		return outcome.OutcomeCost(resp)
 	}
 
-	logger.Println("going to unmarshal request:", p.Type)
+	return EnergyCost(req)
+}
 
-	var req Request
+// RealTimeSpeed is how much energy an actor's actions may cost each tick in
+// real-time worlds, so that a move takes two ticks.
+const RealTimeSpeed = 50
 
-	switch p.Type {
-	case reflect.TypeOf(MeleeAttackRequest{}).Name():
-		r := MeleeAttackRequest{}
-		err = json.Unmarshal(p.Request, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+// Delay returns how many ticks an actor must wait after performing an action
+// of the cost before it may act again, in real-time worlds.
+func Delay(cost int) uint64 {
+	return uint64(cost / RealTimeSpeed)
+}
 
-		req = r
-	case reflect.TypeOf(RangeAttackRequest{}).Name():
-		r := RangeAttackRequest{}
-		err = json.Unmarshal(p.Request, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+// Scheduled returns true when the entity takes turns in turn-based worlds: it
+// has energy, and the speed with which to gain it. Entities which are not
+// scheduled cannot act in turn-based worlds.
+func Scheduled(e objects.Entity) bool {
+	energy, ok := e.Energy()
+	return ok && energy.Scheduled()
+}
 
-		req = r
+// CanAct returns true when the entity is able to act: once it has enough
+// energy in turn-based worlds, or once its timer is ready in real-time worlds.
+func CanAct(world entities.World, e objects.Entity) bool {
+	if world.TurnBased() {
+		energy, _ := e.Energy()
+		return Scheduled(e) && energy.Ready()
+	}
 
-	case reflect.TypeOf(CloseRequest{}).Name():
-		r := CloseRequest{}
-		err = json.Unmarshal(p.Request, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+	timer, _ := e.Timer()
+	return timer.Ready(world.Tick)
+}
 
-		req = r
+// Spend uses up the actor's energy after it performs an action of the cost in
+// turn-based worlds, or delays its timer in real-time worlds, so that it cannot
+// act again straight away.
+func Spend(world entities.World, id uuid.UUID, cost int) entities.World {
+	actor, ok := world.Objects.FromID(id)
+	if !ok || cost == 0 {
+		return world
+	}
 
-	case reflect.TypeOf(MoveRequest{}).Name():
-		r := MoveRequest{}
-		err = json.Unmarshal(p.Request, &r)
-		if err != nil {
-			return nil, errors.New(err)
+	if world.TurnBased() {
+		energy, ok := actor.Energy()
+		if !ok {
+			return world
+		}
+		energy.Spend(cost)
+		actor.Set(energy)
+	} else {
+		timer, ok := actor.Timer()
+		if !ok {
+			return world
 		}
+		timer.Delay(world.Tick, Delay(cost))
+		actor.Set(timer)
+	}
 
-		req = r
+	world.Objects = world.Objects.MustUpdate(actor)
+	return world
+}
 
-	case reflect.TypeOf(OpenRequest{}).Name():
-		r := OpenRequest{}
-		err = json.Unmarshal(p.Request, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+// Error is returned when a request cannot be performed. Its code is relayed
+// back to the requesting client through an ErrorResponse.
+type Error struct {
+	Code    responses.ErrorCode
+	Message string
+}
 
-		req = r
+func (e Error) Error() string {
+	return e.Message
+}
 
-	case reflect.TypeOf(ViewRequest{}).Name():
-		r := ViewRequest{}
-		err = json.Unmarshal(p.Request, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+func newError(code responses.ErrorCode, format string, args ...interface{}) error {
+	return errors.New(Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// NewErrorResponse creates an ErrorResponse for the actor of the specified
+// request, describing the error which occurred while performing it.
+func NewErrorResponse(req Request, err error) responses.ErrorResponse {
+	resp := responses.ErrorResponse{
+		ActorID: req.Actor(),
+		Code:    responses.UnknownError,
+		Message: err.Error(),
+	}
+	resp.RequestType, _ = NameOf(req)
+
+	if e, ok := err.(*errors.Error); ok {
+		err = e.Err
+	}
+	if e, ok := err.(Error); ok {
+		resp.Code = e.Code
+	}
+
+	return resp
+}
+
+type payload struct {
+	Type    string           `json:"type"`
+	Request codec.RawMessage `json:"request"`
+}
+
+// Unmarshal a bites into a payload which can be used to return the
+// embedded request.
+func Unmarshal(bites []byte) (Request, error) {
+	return Decode(codec.JSON, bites)
+}
+
+// Decode bites encoded with the specified codec into a payload which can be
+// used to return the embedded request.
+func Decode(c codec.Codec, bites []byte) (Request, error) {
+	logger, closeLog := logging.Logger("comms.requests.Decode")
+	defer closeLog()
+
+	logger.Println("received request to decode using:", c.Name())
+
+	p := payload{}
+	err := c.Unmarshal(bites, &p)
+	if err != nil {
+		return nil, err
+	}
 
-		req = r
+	logger.Println("going to unmarshal request:", p.Type)
 
-	default:
+	r, ok := registry.New(p.Type)
+	if !ok {
 		logger.Println("invalid request type:", p.Type)
 		return nil, errors.Errorf("invalid request type: %s", p.Type)
 	}
 
+	err = c.Unmarshal(p.Request, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Factories return pointers, but requests are passed around as values.
+	req, ok := reflect.Indirect(reflect.ValueOf(r)).Interface().(Request)
+	if !ok {
+		return nil, errors.Errorf("request type %s does not implement Request", p.Type)
+	}
+
 	logger.Println("successfully unmashalled request:", p.Type)
 	return req, nil
 }
 
+// TypeName returns the name of the request type embedded within the bites,
+// without unmarshalling the request itself.
+func TypeName(c codec.Codec, bites []byte) (string, error) {
+	p := payload{}
+	err := c.Unmarshal(bites, &p)
+	if err != nil {
+		return "", err
+	}
+
+	return p.Type, nil
+}
+
 // Marshal a request into bites representing a payload instance.
 func Marshal(req Request) ([]byte, error) {
-	bites, err := json.Marshal(req)
+	return Encode(codec.JSON, req)
+}
+
+// Encode a request into bites representing a payload instance, using the
+// specified codec.
+func Encode(c codec.Codec, req Request) ([]byte, error) {
+	name, ok := NameOf(req)
+	if !ok {
+		return nil, errors.Errorf("unregistered request type: %s", reflect.TypeOf(req))
+	}
+
+	bites, err := c.Marshal(req)
 	if err != nil {
-		return nil, errors.New(err)
+		return nil, err
 	}
 
 	p := payload{
-		Type:    reflect.TypeOf(req).Name(),
-		Request: json.RawMessage(bites),
+		Type:    name,
+		Request: codec.RawMessage(bites),
 	}
 
-	bites, err = json.Marshal(p)
-	if err != nil {
-		return nil, errors.New(err)
-	}
-	return bites, nil
+	return c.Marshal(p)
 }
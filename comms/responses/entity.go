@@ -0,0 +1,43 @@
+package responses
+
+import (
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/clagraff/pitch/comms/codec"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// entityExtID identifies entities encoded by the binary codec.
+const entityExtID = 1
+
+// Entities are independent of the codecs, so the binary codec is taught to
+// encode them here, as a MessagePack extension holding their fields.
+func init() {
+	msgpack.RegisterExtEncoder(entityExtID, objects.Entity{}, encodeEntity)
+	msgpack.RegisterExtDecoder(entityExtID, (*objects.Entity)(nil), decodeEntity)
+}
+
+func encodeEntity(enc *msgpack.Encoder, v reflect.Value) ([]byte, error) {
+	return codec.Binary.Marshal(v.Interface().(objects.Entity).Fields())
+}
+
+func decodeEntity(dec *msgpack.Decoder, v reflect.Value, extLen int) error {
+	raw := make(map[string]codec.RawMessage)
+	err := dec.Decode(&raw)
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string][]byte, len(raw))
+	for name, data := range raw {
+		fields[name] = data
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+
+	return v.Interface().(*objects.Entity).Decode(fields, codec.Binary.Unmarshal)
+}
@@ -0,0 +1,68 @@
+package responses
+
+import (
+	"reflect"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/utils"
+)
+
+// Factory returns a pointer to a new, zero-valued response, into which a
+// response of its type can be unmarshalled.
+type Factory func() Response
+
+var registry = utils.NewRegistry("response type")
+
+var responseType = reflect.TypeOf((*Response)(nil)).Elem()
+
+func init() {
+	MustRegister("Wrapper", func() Response { return &Wrapper{} })
+	MustRegister("MeleeAttackResponse", func() Response { return &MeleeAttackResponse{} })
+	MustRegister("RangeAttackResponse", func() Response { return &RangeAttackResponse{} })
+	MustRegister("MoveResponse", func() Response { return &MoveResponse{} })
+	MustRegister("ToggleResponse", func() Response { return &ToggleResponse{} })
+	MustRegister("PickUpResponse", func() Response { return &PickUpResponse{} })
+	MustRegister("DropResponse", func() Response { return &DropResponse{} })
+	MustRegister("GiveResponse", func() Response { return &GiveResponse{} })
+	MustRegister("EquipmentResponse", func() Response { return &EquipmentResponse{} })
+	MustRegister("ViewResponse", func() Response { return &ViewResponse{} })
+	MustRegister("EntityEnteredView", func() Response { return &EntityEnteredView{} })
+	MustRegister("EntityChanged", func() Response { return &EntityChanged{} })
+	MustRegister("EntityLeftView", func() Response { return &EntityLeftView{} })
+	MustRegister("ErrorResponse", func() Response { return &ErrorResponse{} })
+}
+
+// Register makes a response type available to Marshal and Unmarshal under the
+// provided name. The factory must return a pointer to the response type, which
+// must implement Response on its value rather than only its pointer, as responses
+// are passed around as values. An error is returned if either the name or the
+// response type has already been registered.
+func Register(name string, factory Factory) error {
+	t := reflect.TypeOf(factory())
+	if t != nil && t.Kind() == reflect.Ptr && !t.Elem().Implements(responseType) {
+		return errors.Errorf("response type %s must implement Response on its value, not only its pointer", t.Elem())
+	}
+
+	return registry.Register(name, func() interface{} { return factory() })
+}
+
+// MustRegister is like Register, but panics if the response type cannot be
+// registered. It is intended to be called from package init functions.
+func MustRegister(name string, factory Factory) {
+	err := Register(name, factory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Names returns the sorted names of every registered response type.
+func Names() []string {
+	return registry.Names()
+}
+
+// NameOf returns the name the response's type was registered under, and
+// whether it has been registered at all.
+func NameOf(resp Response) (string, bool) {
+	return registry.NameOf(resp)
+}
@@ -1,83 +1,60 @@
 package responses
 
 import (
-	"encoding/json"
 	"fmt"
 	"reflect"
 
+	"github.com/clagraff/pitch/comms/codec"
 	"github.com/clagraff/pitch/entities"
 	"github.com/clagraff/pitch/entities/objects"
 	"github.com/clagraff/pitch/logging"
+	"github.com/clagraff/pitch/utils"
 	"github.com/go-errors/errors"
 	uuid "github.com/satori/go.uuid"
 )
 
 type payload struct {
-	Type     string          `json:"type"`
-	Response json.RawMessage `json:"response"`
+	Type     string           `json:"type"`
+	Response codec.RawMessage `json:"response"`
 }
 
 // Unmarshal bites representing a payload and return the embedded Response
 // instance.
 func Unmarshal(bites []byte) (Response, error) {
-	logger, closeLog := logging.Logger("comms.responses.Unmarshal")
+	return Decode(codec.JSON, bites)
+}
+
+// Decode bites representing a payload, encoded with the specified codec, and
+// return the embedded Response instance.
+func Decode(c codec.Codec, bites []byte) (Response, error) {
+	logger, closeLog := logging.Logger("comms.responses.Decode")
 	defer closeLog()
 
-	logger.Println("received response json to unmarshal")
+	logger.Println("received response to decode using:", c.Name())
 
 	p := payload{}
-	err := json.Unmarshal(bites, &p)
+	err := c.Unmarshal(bites, &p)
 	if err != nil {
-		return nil, errors.New(err)
+		return nil, err
 	}
 
 	logger.Println("going to unmarshal response:", p.Type)
 
-	var resp Response
-
-	switch p.Type {
-	case reflect.TypeOf(MeleeAttackResponse{}).Name():
-		r := MeleeAttackResponse{}
-		err = json.Unmarshal(p.Response, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
-
-		resp = r
-	case reflect.TypeOf(RangeAttackResponse{}).Name():
-		r := RangeAttackResponse{}
-		err = json.Unmarshal(p.Response, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
-
-		resp = r
-	case reflect.TypeOf(MoveResponse{}).Name():
-		r := MoveResponse{}
-		err = json.Unmarshal(p.Response, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
-
-		resp = r
-	case reflect.TypeOf(ToggleResponse{}).Name():
-		r := ToggleResponse{}
-		err = json.Unmarshal(p.Response, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+	r, ok := registry.New(p.Type)
+	if !ok {
+		logger.Println("invalid response type:", p.Type)
+		return nil, errors.Errorf("invalid response type: %s", p.Type)
+	}
 
-		resp = r
-	case reflect.TypeOf(ViewResponse{}).Name():
-		r := ViewResponse{}
-		err = json.Unmarshal(p.Response, &r)
-		if err != nil {
-			return nil, errors.New(err)
-		}
+	err = c.Unmarshal(p.Response, r)
+	if err != nil {
+		return nil, err
+	}
 
-		resp = r
-	default:
-		return nil, errors.New("invalid response type")
+	// Factories return pointers, but responses are passed around as values.
+	resp, ok := reflect.Indirect(reflect.ValueOf(r)).Interface().(Response)
+	if !ok {
+		return nil, errors.Errorf("response type %s does not implement Response", p.Type)
 	}
 
 	logger.Println("successfully unmashalled response:", p.Type)
@@ -86,21 +63,28 @@ func Unmarshal(bites []byte) (Response, error) {
 
 // Marshal a response into bites representing a payload.
 func Marshal(resp Response) ([]byte, error) {
-	bites, err := json.Marshal(resp)
+	return Encode(codec.JSON, resp)
+}
+
+// Encode a response into bites representing a payload, using the specified
+// codec.
+func Encode(c codec.Codec, resp Response) ([]byte, error) {
+	name, ok := NameOf(resp)
+	if !ok {
+		return nil, errors.Errorf("unregistered response type: %s", reflect.TypeOf(resp))
+	}
+
+	bites, err := c.Marshal(resp)
 	if err != nil {
-		return nil, errors.New(err)
+		return nil, err
 	}
 
 	p := payload{
-		Type:     reflect.TypeOf(resp).Name(),
-		Response: json.RawMessage(bites),
+		Type:     name,
+		Response: codec.RawMessage(bites),
 	}
 
-	bites, err = json.Marshal(p)
-	if err != nil {
-		return nil, errors.New(err)
-	}
-	return bites, nil
+	return c.Marshal(p)
 }
 
 // Response provides an interfaces for exchanging and operating on responses
@@ -110,10 +94,74 @@ type Response interface {
 	IDs() []uuid.UUID
 }
 
+// Wrapper is a response used to group multiple responses together, so they
+// can be sent and applied as one.
 type Wrapper struct {
 	Responses []Response `json:"responses"`
 }
 
+type wrapperPayloads struct {
+	Responses []codec.RawMessage `json:"responses"`
+}
+
+// encode encodes the wrapped responses as payloads, so that their types are
+// preserved.
+func (resp Wrapper) encode(c codec.Codec) ([]byte, error) {
+	w := wrapperPayloads{
+		Responses: make([]codec.RawMessage, len(resp.Responses)),
+	}
+
+	for i, r := range resp.Responses {
+		bites, err := Encode(c, r)
+		if err != nil {
+			return nil, err
+		}
+		w.Responses[i] = codec.RawMessage(bites)
+	}
+
+	return c.Marshal(w)
+}
+
+// decode decodes the wrapped responses from their payloads.
+func (resp *Wrapper) decode(c codec.Codec, data []byte) error {
+	w := wrapperPayloads{}
+	err := c.Unmarshal(data, &w)
+	if err != nil {
+		return err
+	}
+
+	resp.Responses = make([]Response, len(w.Responses))
+	for i, bites := range w.Responses {
+		resp.Responses[i], err = Decode(c, bites)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON marshals the wrapped responses as JSON payloads.
+func (resp Wrapper) MarshalJSON() ([]byte, error) {
+	return resp.encode(codec.JSON)
+}
+
+// UnmarshalJSON unmarshals wrapped responses from their JSON payloads.
+func (resp *Wrapper) UnmarshalJSON(data []byte) error {
+	return resp.decode(codec.JSON, data)
+}
+
+// MarshalMsgpack marshals the wrapped responses as binary payloads.
+func (resp Wrapper) MarshalMsgpack() ([]byte, error) {
+	return resp.encode(codec.Binary)
+}
+
+// UnmarshalMsgpack unmarshals wrapped responses from their binary payloads.
+func (resp *Wrapper) UnmarshalMsgpack(data []byte) error {
+	return resp.decode(codec.Binary, data)
+}
+
+// Apply will apply each of the wrapped responses in order.
 func (resp Wrapper) Apply(world entities.World) (entities.World, error) {
 	var err error
 	for _, r := range resp.Responses {
@@ -135,6 +183,7 @@ func (resp Wrapper) IDs() []uuid.UUID {
 	return ids
 }
 
+// MakeWrapper returns a Wrapper around the provided responses.
 func MakeWrapper(resps ...Response) Wrapper {
 	return Wrapper{
 		Responses: resps,
@@ -144,11 +193,12 @@ func MakeWrapper(resps ...Response) Wrapper {
 // MeleeAttackResponse is a response for providing details about the result
 // of a melee request.
 type MeleeAttackResponse struct {
-	AttackerID      uuid.UUID `json:"attacker_id"`
-	TargetID        uuid.UUID `json:"target_id"`
-	DidHit          bool      `json:"did_hit"`
-	Damage          int       `json:"damage"`
-	HealthRemaining int       `json:"health_remaining"`
+	AttackerID      uuid.UUID         `json:"attacker_id"`
+	TargetID        uuid.UUID         `json:"target_id"`
+	DidHit          bool              `json:"did_hit"`
+	Damage          int               `json:"damage"`
+	DamageRoll      *utils.DiceResult `json:"damage_roll,omitempty"`
+	HealthRemaining int               `json:"health_remaining"`
 }
 
 // Apply will apply the results of the melee attack against the current game
@@ -159,11 +209,12 @@ func (resp MeleeAttackResponse) Apply(world entities.World) (entities.World, err
 		return world, fmt.Errorf("target could not be found on world")
 	}
 
-	target.Health = resp.HealthRemaining
-	if target.Health <= 0 {
-		target.Health = 0
+	if resp.HealthRemaining <= 0 {
 		world.Objects = world.Objects.MustRemove(target)
 	} else {
+		health, _ := target.Health()
+		health.Current = resp.HealthRemaining
+		target.Set(health)
 		world.Objects = world.Objects.MustUpdate(target)
 	}
 
@@ -177,11 +228,12 @@ func (resp MeleeAttackResponse) IDs() []uuid.UUID {
 // RangeAttackResponse is a response for providing details about the result
 // of a melee request.
 type RangeAttackResponse struct {
-	AttackerID      uuid.UUID `json:"attacker_id"`
-	TargetID        uuid.UUID `json:"target_id"`
-	DidHit          bool      `json:"did_hit"`
-	Damage          int       `json:"damage"`
-	HealthRemaining int       `json:"health_remaining"`
+	AttackerID      uuid.UUID         `json:"attacker_id"`
+	TargetID        uuid.UUID         `json:"target_id"`
+	DidHit          bool              `json:"did_hit"`
+	Damage          int               `json:"damage"`
+	DamageRoll      *utils.DiceResult `json:"damage_roll,omitempty"`
+	HealthRemaining int               `json:"health_remaining"`
 }
 
 // Apply will apply the results of the range attack against the current game
@@ -192,12 +244,12 @@ func (resp RangeAttackResponse) Apply(world entities.World) (entities.World, err
 		return world, fmt.Errorf("target could not be found on world")
 	}
 
-	target.Health = resp.HealthRemaining
-	if target.Health <= 0 {
-		target.Health = 0
-
+	if resp.HealthRemaining <= 0 {
 		world.Objects = world.Objects.MustRemove(target)
 	} else {
+		health, _ := target.Health()
+		health.Current = resp.HealthRemaining
+		target.Set(health)
 		world.Objects = world.Objects.MustUpdate(target)
 	}
 
@@ -228,7 +280,12 @@ func (resp ToggleResponse) Apply(world entities.World) (entities.World, error) {
 	}
 
 	logger.Println("Toggling entity:", target, "to open?", resp.IsOpen)
-	target.Passability.IsOpen = resp.IsOpen
+	passability, ok := target.Passability()
+	if !ok {
+		return world, fmt.Errorf("target cannot be toggled")
+	}
+	passability.IsOpen = resp.IsOpen
+	target.Set(passability)
 
 	world.Objects = world.Objects.MustUpdate(target)
 
@@ -255,8 +312,7 @@ func (resp MoveResponse) Apply(world entities.World) (entities.World, error) {
 		return world, fmt.Errorf("actor could not be found on world")
 	}
 
-	actor.Position.X = resp.X
-	actor.Position.Y = resp.Y
+	actor.Set(objects.Position{X: resp.X, Y: resp.Y})
 
 	world.Objects = world.Objects.MustUpdate(actor)
 	return world, nil
@@ -266,20 +322,145 @@ func (resp MoveResponse) IDs() []uuid.UUID {
 	return []uuid.UUID{resp.ActorID}
 }
 
+// PickUpResponse is a response for providing details about the result of a
+// pick up request.
+type PickUpResponse struct {
+	ActorID uuid.UUID `json:"actor_id"`
+	ItemID  uuid.UUID `json:"item_id"`
+}
+
+// Apply will move the item from the ground into the actor's inventory.
+func (resp PickUpResponse) Apply(world entities.World) (entities.World, error) {
+	actor, ok := world.Objects.FromID(resp.ActorID)
+	if !ok {
+		return world, fmt.Errorf("actor could not be found on world")
+	}
+
+	inventory, _ := actor.Inventory()
+	inventory.Add(resp.ItemID)
+	actor.Set(inventory)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	if item, ok := world.Items.FromID(resp.ItemID); ok {
+		item.Position = nil
+		world.Items.Insert(item)
+	}
+
+	return world, nil
+}
+
+func (resp PickUpResponse) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
+
+// DropResponse is a response for providing details about the result of a
+// drop request.
+type DropResponse struct {
+	ActorID uuid.UUID `json:"actor_id"`
+	ItemID  uuid.UUID `json:"item_id"`
+	X       int       `json:"x"`
+	Y       int       `json:"y"`
+}
+
+// Apply will move the item from the actor's inventory onto the ground.
+func (resp DropResponse) Apply(world entities.World) (entities.World, error) {
+	actor, ok := world.Objects.FromID(resp.ActorID)
+	if !ok {
+		return world, fmt.Errorf("actor could not be found on world")
+	}
+
+	if inventory, ok := actor.Inventory(); ok {
+		inventory.Take(resp.ItemID)
+		actor.Set(inventory)
+		world.Objects = world.Objects.MustUpdate(actor)
+	}
+
+	if item, ok := world.Items.FromID(resp.ItemID); ok {
+		item.Position = &objects.Position{X: resp.X, Y: resp.Y}
+		world.Items.Insert(item)
+	}
+
+	return world, nil
+}
+
+func (resp DropResponse) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
+
+// GiveResponse is a response for providing details about the result of a
+// give request.
+type GiveResponse struct {
+	ActorID  uuid.UUID `json:"actor_id"`
+	TargetID uuid.UUID `json:"target_id"`
+	ItemID   uuid.UUID `json:"item_id"`
+}
+
+// Apply will move the item from the actor's inventory into the target's.
+func (resp GiveResponse) Apply(world entities.World) (entities.World, error) {
+	if actor, ok := world.Objects.FromID(resp.ActorID); ok {
+		if inventory, ok := actor.Inventory(); ok {
+			inventory.Take(resp.ItemID)
+			actor.Set(inventory)
+			world.Objects = world.Objects.MustUpdate(actor)
+		}
+	}
+
+	if target, ok := world.Objects.FromID(resp.TargetID); ok {
+		inventory, _ := target.Inventory()
+		inventory.Add(resp.ItemID)
+		target.Set(inventory)
+		world.Objects = world.Objects.MustUpdate(target)
+	}
+
+	return world, nil
+}
+
+func (resp GiveResponse) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID, resp.TargetID}
+}
+
+// EquipmentResponse is a response for providing the actor's equipment and
+// inventory after an equip or unequip request.
+type EquipmentResponse struct {
+	ActorID   uuid.UUID         `json:"actor_id"`
+	Equipment objects.Equipment `json:"equipment"`
+	Inventory objects.Inventory `json:"inventory"`
+}
+
+// Apply will update the actor's equipment and inventory.
+func (resp EquipmentResponse) Apply(world entities.World) (entities.World, error) {
+	actor, ok := world.Objects.FromID(resp.ActorID)
+	if !ok {
+		return world, fmt.Errorf("actor could not be found on world")
+	}
+
+	actor.Set(resp.Equipment)
+	actor.Set(resp.Inventory)
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	return world, nil
+}
+
+func (resp EquipmentResponse) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
+
 // ViewResponse is a response for providing details visible entities near the
-// actor.
+// actor. Static lists the IDs of the objects which are static, and so should
+// be remembered once out of view.
 type ViewResponse struct {
 	ActorID uuid.UUID        `json:"actor_id"`
 	Objects []objects.Entity `json:"objects"`
+	Static  []uuid.UUID      `json:"static,omitempty"`
 }
 
 // Apply will apply the results of the view request by clearing current objects
-// and adding the response objects.
+// and adding the response objects. Static objects are also remembered.
 func (resp ViewResponse) Apply(world entities.World) (entities.World, error) {
 	logger, closeLog := logging.Logger("responses.ViewResponse.Apply")
 	defer closeLog()
 
-	logger.Println("Number of cleared objects:", len(world.Objects))
+	logger.Println("Number of cleared objects:", world.Objects.Len())
 
 	c := objects.MakeCollection()
 	for _, obj := range resp.Objects {
@@ -290,9 +471,149 @@ func (resp ViewResponse) Apply(world entities.World) (entities.World, error) {
 
 	world.Objects = c
 
+	for _, id := range resp.Static {
+		if e, ok := world.Objects.FromID(id); ok {
+			world = remember(world, e)
+		}
+	}
+
 	return world, nil
 }
 
 func (resp ViewResponse) IDs() []uuid.UUID {
 	return []uuid.UUID{resp.ActorID}
 }
+
+// EntityEnteredView is a response informing the actor that an entity has
+// come into view, containing the entity in full. Static is true when the
+// entity is static, and so should be remembered once out of view.
+type EntityEnteredView struct {
+	ActorID uuid.UUID      `json:"actor_id"`
+	Entity  objects.Entity `json:"entity"`
+	Static  bool           `json:"static,omitempty"`
+}
+
+// Apply will add the entity to the world, replacing it if already present.
+// Static entities are also remembered.
+func (resp EntityEnteredView) Apply(world entities.World) (entities.World, error) {
+	if c, ok := world.Objects.Update(resp.Entity); ok {
+		world.Objects = c
+	} else {
+		world.Objects = world.Objects.Append(resp.Entity)
+	}
+
+	if resp.Static {
+		world = remember(world, resp.Entity)
+	}
+
+	return world, nil
+}
+
+func (resp EntityEnteredView) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
+
+// EntityChanged is a response informing the actor that an entity in view has
+// changed, containing only the fields which changed.
+type EntityChanged struct {
+	ActorID  uuid.UUID    `json:"actor_id"`
+	EntityID uuid.UUID    `json:"entity_id"`
+	Diff     objects.Diff `json:"diff"`
+}
+
+// Apply will patch the entity in the world with the changes.
+func (resp EntityChanged) Apply(world entities.World) (entities.World, error) {
+	e, ok := world.Objects.FromID(resp.EntityID)
+	if !ok {
+		return world, fmt.Errorf("changed entity could not be found on world")
+	}
+
+	e, err := e.Patch(resp.Diff)
+	if err != nil {
+		return world, err
+	}
+
+	world.Objects = world.Objects.MustUpdate(e)
+	if c, ok := world.Remembered.Update(e); ok {
+		world.Remembered = c
+	}
+
+	return world, nil
+}
+
+func (resp EntityChanged) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
+
+// EntityLeftView is a response informing the actor that an entity is no longer
+// in view. Static is true when the entity is static and still exists, so
+// should still be remembered; otherwise it is forgotten.
+type EntityLeftView struct {
+	ActorID  uuid.UUID `json:"actor_id"`
+	EntityID uuid.UUID `json:"entity_id"`
+	Static   bool      `json:"static,omitempty"`
+}
+
+// Apply will remove the entity from the world, if present. Unless static, it is
+// also forgotten.
+func (resp EntityLeftView) Apply(world entities.World) (entities.World, error) {
+	world.Objects, _ = world.Objects.Remove(objects.Entity{ID: resp.EntityID})
+	if !resp.Static {
+		world.Remembered, _ = world.Remembered.Remove(objects.Entity{ID: resp.EntityID})
+	}
+
+	return world, nil
+}
+
+func (resp EntityLeftView) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
+
+// remember keeps the entity as the last seen version of a static entity.
+func remember(world entities.World, e objects.Entity) entities.World {
+	if c, ok := world.Remembered.Update(e); ok {
+		world.Remembered = c
+	} else {
+		world.Remembered = world.Remembered.Append(e)
+	}
+
+	return world
+}
+
+// ErrorCode categorizes why a request could not be performed.
+type ErrorCode string
+
+// Available error codes:
+const (
+	UnknownError            ErrorCode = "unknown"
+	InternalError           ErrorCode = "internal"
+	MalformedRequestError   ErrorCode = "malformed_request"
+	UnauthorizedError       ErrorCode = "unauthorized"
+	UnsupportedRequestError ErrorCode = "unsupported_request"
+	NotFoundError           ErrorCode = "not_found"
+	NotReadyError           ErrorCode = "not_ready"
+	InvalidTargetError      ErrorCode = "invalid_target"
+	InvalidWeaponError      ErrorCode = "invalid_weapon"
+	InventoryFullError      ErrorCode = "inventory_full"
+	NotOwnedError           ErrorCode = "not_owned"
+	InvalidSlotError        ErrorCode = "invalid_slot"
+	BlockedError            ErrorCode = "blocked"
+)
+
+// ErrorResponse is a response for informing the actor that their request
+// could not be performed.
+type ErrorResponse struct {
+	ActorID     uuid.UUID `json:"actor_id"`
+	Code        ErrorCode `json:"code"`
+	Message     string    `json:"message"`
+	RequestType string    `json:"request_type"`
+}
+
+// Apply leaves the world untouched, as a failed request has no effect on it.
+func (resp ErrorResponse) Apply(world entities.World) (entities.World, error) {
+	return world, nil
+}
+
+func (resp ErrorResponse) IDs() []uuid.UUID {
+	return []uuid.UUID{resp.ActorID}
+}
@@ -0,0 +1,159 @@
+// Package config holds the settings for running pitch, which may be read from
+// a JSON config file and then overridden by command-line flags.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// DefaultPath is the config file read when it exists, unless another is
+// specified.
+const DefaultPath = "pitch.json"
+
+// Config holds the settings for the server and client. Durations are written
+// as strings such as "100ms" or "1m".
+type Config struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+
+	// Save is the file the world is loaded from and saved to. Saves ending
+	// in .db are kept in an embedded database.
+	Save string `json:"save"`
+	// Snapshots is how many snapshots of the world are kept.
+	Snapshots int `json:"snapshots"`
+	// AutosaveInterval is how often the world is saved; never when zero.
+	AutosaveInterval Duration `json:"autosave_interval"`
+
+	Accounts string `json:"accounts"`
+	// Journal is the file requests are journalled to; none when empty.
+	Journal string `json:"journal"`
+	LogDir  string `json:"log_dir"`
+
+	// TickInterval is how much real time passes between each game tick.
+	TickInterval Duration `json:"tick_interval"`
+	// ViewInterval is how often players are sent changes to their view;
+	// every tick when zero.
+	ViewInterval Duration `json:"view_interval"`
+	// MaxPlayers is how many players may be connected at once; unlimited
+	// when zero.
+	MaxPlayers int `json:"max_players"`
+
+	// Memory is the directory clients keep what each player remembers of
+	// the map in; nothing is kept when empty.
+	Memory string `json:"memory"`
+}
+
+// Default returns the config used when no config file or flags say
+// otherwise.
+func Default() Config {
+	return Config{
+		Host: "localhost",
+		Port: 8080,
+
+		Save:             "game.save",
+		Snapshots:        10,
+		AutosaveInterval: Duration(time.Minute),
+
+		Accounts: "accounts.json",
+		Journal:  "journal.jsonl",
+		LogDir:   "logs",
+
+		TickInterval: Duration(100 * time.Millisecond),
+
+		Memory: "memory",
+	}
+}
+
+// Load reads the config file at the path on top of the default config. Any
+// setting not in the file keeps its default. Unknown settings are an error.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, errors.New(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&cfg)
+	if err != nil {
+		return cfg, errors.Errorf("invalid config file %s: %s", path, err)
+	}
+
+	return cfg, nil
+}
+
+// LoadDefault reads the default config file if it exists, otherwise returning
+// the default config.
+func LoadDefault() (Config, error) {
+	if _, err := os.Stat(DefaultPath); os.IsNotExist(err) {
+		return Default(), nil
+	}
+
+	return Load(DefaultPath)
+}
+
+// Validate returns an error describing every invalid setting.
+func (cfg Config) Validate() error {
+	problems := make([]string, 0)
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(cfg.Host != "", "host must not be empty")
+	check(cfg.Port > 0 && cfg.Port <= 65535, "port must be between 1 and 65535, not %d", cfg.Port)
+	check(cfg.Save != "", "save must not be empty")
+	check(cfg.Snapshots >= 0, "snapshots must not be negative")
+	check(cfg.AutosaveInterval >= 0, "autosave_interval must not be negative")
+	check(cfg.Accounts != "", "accounts must not be empty")
+	check(cfg.LogDir != "", "log_dir must not be empty")
+	check(cfg.TickInterval > 0, "tick_interval must be positive")
+	check(cfg.ViewInterval >= 0, "view_interval must not be negative")
+	check(cfg.MaxPlayers >= 0, "max_players must not be negative")
+
+	if len(problems) > 0 {
+		return errors.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// Duration is a time.Duration which is written as a string, such as "100ms".
+type Duration time.Duration
+
+// String returns the duration as a string, such as "100ms".
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses the duration from a string, so durations can be used as flags.
+func (d *Duration) Set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText marshals the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText unmarshals the duration from a string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
@@ -0,0 +1,89 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// Differences returns a description of every way in which the world differs
+// from the expected world, or nothing if they are the same.
+func Differences(world, expected World) ([]string, error) {
+	diffs := make([]string, 0)
+
+	if world.Mode != expected.Mode {
+		diffs = append(diffs, fmt.Sprintf("mode: %q, expected %q", world.Mode, expected.Mode))
+	}
+	if world.Tick != expected.Tick {
+		diffs = append(diffs, fmt.Sprintf("tick: %d, expected %d", world.Tick, expected.Tick))
+	}
+	if !reflect.DeepEqual(world.RNG, expected.RNG) {
+		diffs = append(diffs, fmt.Sprintf("rng: %+v, expected %+v", world.RNG, expected.RNG))
+	}
+
+	for _, e := range world.Objects.Entities() {
+		want, ok := expected.Objects.FromID(e.ID)
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("object %s: unexpected", e.ID))
+			continue
+		}
+
+		d, err := objects.MakeDiff(want, e)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range sortedKeys(d.Changed) {
+			diffs = append(diffs, fmt.Sprintf("object %s: %s is %v", e.ID, path, d.Changed[path]))
+		}
+		for _, path := range d.Removed {
+			diffs = append(diffs, fmt.Sprintf("object %s: %s is missing", e.ID, path))
+		}
+	}
+	for _, e := range expected.Objects.Entities() {
+		if _, ok := world.Objects.FromID(e.ID); !ok {
+			diffs = append(diffs, fmt.Sprintf("object %s: missing", e.ID))
+		}
+	}
+
+	for _, i := range world.Items.Items() {
+		want, ok := expected.Items.FromID(i.ID)
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("item %s: unexpected", i.ID))
+			continue
+		}
+
+		got, err := json.Marshal(i)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		wanted, err := json.Marshal(want)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		if string(got) != string(wanted) {
+			diffs = append(diffs, fmt.Sprintf("item %s: %s, expected %s", i.ID, got, wanted))
+		}
+	}
+	for _, i := range expected.Items.Items() {
+		if _, ok := world.Items.FromID(i.ID); !ok {
+			diffs = append(diffs, fmt.Sprintf("item %s: missing", i.ID))
+		}
+	}
+
+	return diffs, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -3,20 +3,111 @@ package entities
 import (
 	"github.com/clagraff/pitch/entities/items"
 	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/utils"
 )
 
-// World represents a container for the Object and Item collections.
+// Mode determines how time passes in a world.
+type Mode string
+
+// Available modes:
+const (
+	// RealTimeMode advances the world at a fixed rate, regardless of whether
+	// players have acted. It is used when a world does not specify a mode.
+	RealTimeMode Mode = "real_time"
+	// TurnBasedMode only advances the world once every connected player able
+	// to act has done so, with entities acting according to their energy.
+	TurnBasedMode Mode = "turn_based"
+)
+
+// World represents a container for the Object and Item collections, as of
+// the specified game tick.
+//
+// All randomness in the world is drawn from its RNG, which is saved along with
+// it, so that replaying the same requests against a save gives the same
+// results. Worlds saved without an RNG are seeded with zero.
+//
+// Version is the version of the save format the world was saved in, as
+// maintained by the saves package.
+//
+// Remembered is only used by clients, and is never saved with the world. It
+// holds the static entities, such as walls and doors, which the player has
+// seen, as they were when last seen; whether or not they are still in view.
 type World struct {
-	Objects objects.Collection `json:"objects"`
-	Items   items.Collection   `json:"items"`
+	Version    int                `json:"version"`
+	Mode       Mode               `json:"mode,omitempty"`
+	Tick       uint64             `json:"tick"`
+	RNG        *utils.Source      `json:"rng"`
+	Objects    objects.Collection `json:"objects"`
+	Items      items.Collection   `json:"items"`
+	Remembered objects.Collection `json:"-"`
 }
 
 // MakeWorld will instantiate and return a new World struct.
 func MakeWorld() World {
 	w := World{
-		Objects: objects.MakeCollection(),
-		Items:   items.MakeCollection(),
+		RNG:        utils.NewSource(0),
+		Objects:    objects.MakeCollection(),
+		Items:      items.MakeCollection(),
+		Remembered: objects.MakeCollection(),
 	}
 
 	return w
 }
+
+// Clone returns an independent copy of the world.
+func (w World) Clone() World {
+	w.RNG = w.RNG.Clone()
+	w.Objects = w.Objects.Clone()
+	w.Items = w.Items.Clone()
+	w.Remembered = w.Remembered.Clone()
+
+	return w
+}
+
+// Transaction records the changes made to a world, so that they can be undone
+// should a request or system fail part way through making them. Transactions
+// cannot be nested.
+type Transaction struct {
+	world World
+	rng   utils.Source
+}
+
+// Begin starts recording the changes made to the world, and to every copy of
+// it, until the transaction is committed or rolled back.
+func (w World) Begin() Transaction {
+	w.Objects.Begin()
+	w.Items.Begin()
+	w.Remembered.Begin()
+
+	t := Transaction{world: w}
+	if w.RNG != nil {
+		t.rng = *w.RNG
+	}
+
+	return t
+}
+
+// Commit keeps the changes made since the transaction began.
+func (t Transaction) Commit() {
+	t.world.Objects.Commit()
+	t.world.Items.Commit()
+	t.world.Remembered.Commit()
+}
+
+// Rollback undoes the changes made since the transaction began, returning the
+// world as it was when it began.
+func (t Transaction) Rollback() World {
+	t.world.Objects.Rollback()
+	t.world.Items.Rollback()
+	t.world.Remembered.Rollback()
+	if t.world.RNG != nil {
+		*t.world.RNG = t.rng
+	}
+
+	return t.world
+}
+
+// TurnBased returns true when the world uses TurnBasedMode.
+func (w World) TurnBased() bool {
+	return w.Mode == TurnBasedMode
+}
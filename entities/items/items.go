@@ -2,28 +2,28 @@ package items
 
 import (
 	"encoding/json"
+	"sort"
 
 	uuid "github.com/satori/go.uuid"
 
+	"github.com/clagraff/pitch/entities/objects"
 	"github.com/clagraff/pitch/utils"
 )
 
-// Collection represents a collection of Items.
+// Collection represents a collection of Items. Copies of a Collection share the
+// same items.
+//
+// While changes are being recorded, undo holds a function undoing each of
+// them, oldest first; it is nil otherwise.
 type Collection struct {
 	mapping map[uuid.UUID]Item
+	undo    *[]func()
 }
 
-// MarshalJSON marshals the current Collection as a list (as opposed to a map).
+// MarshalJSON marshals the current Collection as a list (as opposed to a map),
+// ordered by ID.
 func (c Collection) MarshalJSON() ([]byte, error) {
-	l := make([]Item, len(c.mapping))
-	i := 0
-
-	for _, item := range c.mapping {
-		l[i] = item
-		i++
-	}
-
-	return json.Marshal(l)
+	return json.Marshal(c.Items())
 }
 
 // UnmarshalJSON unmarshals JSON bytes into the current Collection, assuming the
@@ -46,19 +46,86 @@ func (c *Collection) UnmarshalJSON(data []byte) error {
 func MakeCollection() Collection {
 	c := Collection{}
 	c.mapping = make(map[uuid.UUID]Item)
+	c.undo = new([]func())
 
 	return c
 }
 
+// Clone returns an independent copy of the collection.
+func (c Collection) Clone() Collection {
+	clone := MakeCollection()
+	for id, i := range c.mapping {
+		clone.mapping[id] = i
+	}
+
+	return clone
+}
+
+// record adds a function undoing a change to the collection, if changes are
+// being recorded.
+func (c Collection) record(undo func()) {
+	if c.undo != nil && *c.undo != nil {
+		*c.undo = append(*c.undo, undo)
+	}
+}
+
+// Begin starts recording the changes made to the collection, and to every copy
+// of it, so that they can be undone by Rollback. Commit stops recording,
+// keeping the changes. Changes made to a collection which has not been
+// initialized are not recorded, as they are made to a new collection instead.
+func (c Collection) Begin() {
+	if c.undo == nil {
+		return
+	}
+
+	*c.undo = make([]func(), 0)
+}
+
+// Commit stops recording the changes made to the collection, keeping them.
+func (c Collection) Commit() {
+	if c.undo == nil {
+		return
+	}
+
+	*c.undo = nil
+}
+
+// Rollback undoes every change made to the collection since Begin was called,
+// most recent first, and stops recording.
+func (c Collection) Rollback() {
+	if c.undo == nil {
+		return
+	}
+
+	undo := *c.undo
+	*c.undo = nil
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+}
+
+// set puts the item into the collection, or removes the item with the ID if
+// there was none, recording how to undo the change.
+func (c Collection) set(id uuid.UUID, i Item, ok bool) {
+	previous, existed := c.mapping[id]
+	c.record(func() { c.set(id, previous, existed) })
+
+	if ok {
+		c.mapping[id] = i
+	} else {
+		delete(c.mapping, id)
+	}
+}
+
 // Insert will insert the provided item into the collection.
 func (c *Collection) Insert(i Item) {
 	id := i.ID
 
 	if c.mapping == nil {
-		c.mapping = make(map[uuid.UUID]Item)
+		*c = MakeCollection()
 	}
 
-	c.mapping[id] = i
+	c.set(id, i, true)
 }
 
 // FromID will attempt to return an Item from the current collection, as
@@ -79,6 +146,37 @@ func (c Collection) FromIDString(id string) (Item, bool) {
 	return i, ok
 }
 
+// Items returns every item in the collection, ordered by ID.
+func (c Collection) Items() []Item {
+	l := make([]Item, 0, len(c.mapping))
+	for _, i := range c.mapping {
+		l = append(l, i)
+	}
+
+	sort.Slice(l, func(a, b int) bool {
+		return l[a].ID.String() < l[b].ID.String()
+	})
+
+	return l
+}
+
+// FromXY will return all items which lie on the ground at the given XY
+// position, ordered by ID.
+func (c Collection) FromXY(x, y int) []Item {
+	found := make([]Item, 0)
+	for _, i := range c.mapping {
+		if i.Position != nil && i.Position.X == x && i.Position.Y == y {
+			found = append(found, i)
+		}
+	}
+
+	sort.Slice(found, func(a, b int) bool {
+		return found[a].ID.String() < found[b].ID.String()
+	})
+
+	return found
+}
+
 // Remove will the first item in the current collection which matches the
 // specified UUID of the provided item.
 func (c *Collection) Remove(i Item) bool {
@@ -86,7 +184,7 @@ func (c *Collection) Remove(i Item) bool {
 		return false
 	}
 
-	delete(c.mapping, i.ID)
+	c.set(i.ID, Item{}, false)
 	return true
 }
 
@@ -99,21 +197,55 @@ const (
 	RangeDamage
 )
 
-// Damage is used to represent a possible damage amount and type.
-// This includes a damage modifier (if applicable), and the die range
-// and roll amount. These are used to calculate a one-time damage amount.
+// Damage is used to represent a possible damage amount and type, as the dice
+// rolled to calculate a one-time damage amount.
+//
+// In JSON, damage may be a dice expression such as "2d6+3", or an object with
+// the dice and the damage type. The older form, with the die range, roll
+// amount and modifier, is also accepted.
 type Damage struct {
-	DieRange   int        `json:"die_range"`
-	Modifier   int        `json:"modifier"`
-	RollAmount int        `json:"roll_amount"`
-	Type       DamageType `json:"damage_type"`
+	Dice utils.Dice `json:"dice"`
+	Type DamageType `json:"damage_type"`
 }
 
-// Calculate returns a randomized damage amount, based on the Damage's current
-// modifier, die range, and roll amount.
-func (d Damage) Calculate() int {
-	roll := utils.Roll(d.RollAmount, d.DieRange)
-	return roll + d.Modifier
+// UnmarshalJSON unmarshals damage from either a dice expression or an object.
+func (d *Damage) UnmarshalJSON(data []byte) error {
+	var expr string
+	if json.Unmarshal(data, &expr) == nil {
+		*d = Damage{}
+		return d.Dice.UnmarshalText([]byte(expr))
+	}
+
+	fields := struct {
+		Dice       *utils.Dice `json:"dice"`
+		Type       DamageType  `json:"damage_type"`
+		DieRange   int         `json:"die_range"`
+		Modifier   int         `json:"modifier"`
+		RollAmount int         `json:"roll_amount"`
+	}{}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	*d = Damage{Type: fields.Type}
+	if fields.Dice != nil {
+		d.Dice = *fields.Dice
+	} else {
+		d.Dice = utils.Dice{
+			Count:    fields.RollAmount,
+			Sides:    fields.DieRange,
+			Modifier: fields.Modifier,
+		}
+	}
+
+	return d.Dice.Validate()
+}
+
+// Roll returns a randomized damage amount from the damage's dice, rolled
+// against the random number source.
+func (d Damage) Roll(rng utils.RNG) utils.DiceResult {
+	return d.Dice.Roll(rng)
 }
 
 // Armor is used to represent the possible damage reductions, categorized by
@@ -124,8 +256,32 @@ type Armor struct {
 }
 
 // Item is used to represent items with a UUID and Damage & Armor objects.
+// Items lying on the ground have a position; items held by an entity do not.
+// Items with a slot may be equipped in it, and two-handed items are held in
+// both hands at once.
 type Item struct {
-	ID     uuid.UUID `json:"id"`
-	Damage Damage    `json:"damage"`
-	Armor  Armor     `json:"armor"`
+	ID        uuid.UUID         `json:"id"`
+	Damage    Damage            `json:"damage"`
+	Armor     Armor             `json:"armor"`
+	Position  *objects.Position `json:"position,omitempty"`
+	Slot      objects.Slot      `json:"slot,omitempty"`
+	TwoHanded bool              `json:"two_handed,omitempty"`
+}
+
+// Fits returns true when the item can be equipped in the slot. Items held in
+// a hand may be held in either hand.
+func (i Item) Fits(slot objects.Slot) bool {
+	if i.Slot == "" {
+		return false
+	}
+	if i.Slot.Held() {
+		return slot.Held()
+	}
+
+	return i.Slot == slot
+}
+
+// OnGround returns true when the item is lying on the ground.
+func (i Item) OnGround() bool {
+	return i.Position != nil
 }
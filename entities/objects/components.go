@@ -0,0 +1,427 @@
+package objects
+
+import (
+	"math"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/utils"
+)
+
+// Component is a piece of data which can be attached to an entity. Any type
+// may be used as a component once registered; components are stored and
+// passed around as values.
+type Component interface{}
+
+// ComponentFactory returns a pointer to a new, zero-valued component, into
+// which a component of its type can be unmarshalled.
+type ComponentFactory func() Component
+
+var components = utils.NewRegistry("component")
+
+// Names of the built-in components, as used as keys when marshalling
+// entities.
+const (
+	TimerComponent       = "timer"
+	EnergyComponent      = "energy"
+	PositionComponent    = "position"
+	PassabilityComponent = "passability"
+	HealthComponent      = "health"
+	AttributesComponent  = "attributes"
+	InventoryComponent   = "inventory"
+	EquipmentComponent   = "equipment"
+	RenderableComponent  = "renderable"
+	AIComponent          = "ai"
+	EffectsComponent     = "effects"
+	StaticComponent      = "static"
+)
+
+func init() {
+	MustRegisterComponent(TimerComponent, func() Component { return &Timer{} })
+	MustRegisterComponent(EnergyComponent, func() Component { return &Energy{} })
+	MustRegisterComponent(PositionComponent, func() Component { return &Position{} })
+	MustRegisterComponent(PassabilityComponent, func() Component { return &Passability{} })
+	MustRegisterComponent(HealthComponent, func() Component { return &Health{} })
+	MustRegisterComponent(AttributesComponent, func() Component { return &Attributes{} })
+	MustRegisterComponent(InventoryComponent, func() Component { return &Inventory{} })
+	MustRegisterComponent(EquipmentComponent, func() Component { return &Equipment{} })
+	MustRegisterComponent(RenderableComponent, func() Component { return &Renderable{} })
+	MustRegisterComponent(AIComponent, func() Component { return &AI{} })
+	MustRegisterComponent(EffectsComponent, func() Component { return &Effects{} })
+	MustRegisterComponent(StaticComponent, func() Component { return &Static{} })
+}
+
+// RegisterComponent makes a component type available to entities under the
+// provided name, which is also used as its key when marshalling entities. The
+// factory must return a pointer to the component type. An error is returned
+// if either the name or the component type has already been registered.
+func RegisterComponent(name string, factory ComponentFactory) error {
+	if name == "id" {
+		return errors.New("component name must not be id")
+	}
+
+	return components.Register(name, func() interface{} { return factory() })
+}
+
+// MustRegisterComponent is like RegisterComponent, but panics if the
+// component type cannot be registered. It is intended to be called from
+// package init functions.
+func MustRegisterComponent(name string, factory ComponentFactory) {
+	err := RegisterComponent(name, factory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ComponentNames returns the sorted names of every registered component.
+func ComponentNames() []string {
+	return components.Names()
+}
+
+// ComponentNameOf returns the name the component's type was registered under,
+// and whether it has been registered at all.
+func ComponentNameOf(c Component) (string, bool) {
+	return components.NameOf(c)
+}
+
+// NewComponent returns a pointer to a new, zero-valued component of the named
+// type, and whether the name has been registered at all.
+func NewComponent(name string) (Component, bool) {
+	c, ok := components.New(name)
+	if !ok {
+		return nil, false
+	}
+
+	return c.(Component), true
+}
+
+// Timer is used to keep track of the tick on which an action is next allowed.
+type Timer struct {
+	NextTick uint64 `json:"next_tick"`
+}
+
+// Delay is used to delay the current timer until the specified number of
+// ticks after the current tick.
+func (timer *Timer) Delay(now uint64, ticks uint64) {
+	timer.NextTick = now + ticks
+}
+
+// Ready returns true once the current tick reaches the timer's next tick.
+func (timer Timer) Ready(now uint64) bool {
+	return now >= timer.NextTick
+}
+
+// NewTimer returns a new timer. I dont know why. TODO: delete this?
+func NewTimer() *Timer {
+	return new(Timer)
+}
+
+// ReadyEnergy is the energy an entity needs before it may act in turn-based
+// worlds.
+const ReadyEnergy = 100
+
+// Energy is a component used to schedule entities in turn-based worlds. Every
+// tick, entities gain energy according to their speed, and acting spends it.
+// Entities without any speed are not scheduled, and cannot act in turn-based
+// worlds.
+type Energy struct {
+	Speed  int `json:"speed"`
+	Points int `json:"points"`
+}
+
+// Scheduled returns true when the entity takes turns in turn-based worlds.
+func (energy Energy) Scheduled() bool {
+	return energy.Speed > 0
+}
+
+// Ready returns true when the entity is able to act.
+func (energy Energy) Ready() bool {
+	return !energy.Scheduled() || energy.Points >= ReadyEnergy
+}
+
+// Gain adds a tick's worth of energy, according to the speed.
+func (energy *Energy) Gain() {
+	energy.Points += energy.Speed
+}
+
+// Spend uses up the specified amount of energy.
+func (energy *Energy) Spend(cost int) {
+	if energy.Scheduled() {
+		energy.Points -= cost
+	}
+}
+
+// Position is used to represent XY coordinates.
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// PassabilityType represents the different types of passability for an entity.
+type PassabilityType int
+
+// Available passability types:
+const (
+	AlwaysImpassible PassabilityType = iota
+	AlwaysPassible
+	//Keyed
+	Toggleable
+)
+
+// Passability is a component used on entities to describe their state of
+// passibility.
+type Passability struct {
+	Type   PassabilityType `json:"type"`
+	IsOpen bool            `json:"is_open"`
+}
+
+// BlocksSight returns true when nothing can be seen through the entity: it is
+// always impassible, or is toggleable and closed.
+func (p Passability) BlocksSight() bool {
+	switch p.Type {
+	case AlwaysImpassible:
+		return true
+	case Toggleable:
+		return !p.IsOpen
+	}
+
+	return false
+}
+
+// Health is a component for entities which can be damaged, and are removed
+// from the world once their health reaches zero.
+type Health struct {
+	Current int `json:"current"`
+	Max     int `json:"max"`
+}
+
+// Attribute represents a DnD-style attribute.
+type Attribute int
+
+// Modifier calculates and returns a modifier based on the state of the current
+// attribute.
+// Minimum value is -5. At level 30, it would be 10.
+func (attr Attribute) Modifier() int {
+	if int(attr) < 0 {
+		return -5
+	}
+
+	// formula f(x) = floor(0.5 * x) - 5
+	modifier := math.Floor((0.5 * float64(attr))) - 5
+	return int(modifier)
+}
+
+// Attributes is a component used to group all available attributes together
+// which can exist for an entity.
+type Attributes struct {
+	Dexterity Attribute `json:"dexterity"`
+	Luck      Attribute `json:"luck"`
+	Strength  Attribute `json:"strength"`
+	Wisdom    Attribute `json:"wisdom"`
+}
+
+// Inventory is a component for entities which is used to store UUIDs
+// correlating to items, which implies ownership by the entity over these items.
+// An inventory holds at most Capacity items.
+type Inventory struct {
+	ItemIDs  []uuid.UUID `json:"item_ids"`
+	Capacity int         `json:"capacity"`
+}
+
+// Contains returns true when the item is held in the inventory.
+func (inv Inventory) Contains(id uuid.UUID) bool {
+	for _, itemID := range inv.ItemIDs {
+		if uuid.Equal(itemID, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Full returns true when the inventory cannot hold any more items.
+func (inv Inventory) Full() bool {
+	return len(inv.ItemIDs) >= inv.Capacity
+}
+
+// Add puts the item into the inventory, returning false if the inventory is
+// full or already holds the item.
+func (inv *Inventory) Add(id uuid.UUID) bool {
+	if inv.Full() || inv.Contains(id) {
+		return false
+	}
+
+	// The list is copied, as copies of the entity share it.
+	ids := make([]uuid.UUID, len(inv.ItemIDs), len(inv.ItemIDs)+1)
+	copy(ids, inv.ItemIDs)
+	inv.ItemIDs = append(ids, id)
+
+	return true
+}
+
+// Take removes the item from the inventory, returning false if the inventory
+// did not hold it.
+func (inv *Inventory) Take(id uuid.UUID) bool {
+	if !inv.Contains(id) {
+		return false
+	}
+
+	ids := make([]uuid.UUID, 0, len(inv.ItemIDs)-1)
+	for _, itemID := range inv.ItemIDs {
+		if !uuid.Equal(itemID, id) {
+			ids = append(ids, itemID)
+		}
+	}
+	inv.ItemIDs = ids
+
+	return true
+}
+
+// Equipment is a component used for specifying which items are currently
+// "equiped" for a given entity.
+type Equipment struct {
+	HeadID          uuid.UUID `json:"head_id"`
+	HandsID         uuid.UUID `json:"hands_id"`
+	PrimaryItemID   uuid.UUID `json:"primary_item_id"`
+	SecondaryItemID uuid.UUID `json:"secondary_item_id"`
+	LegsID          uuid.UUID `json:"legs_id"`
+	ChestID         uuid.UUID `json:"chest_id"`
+}
+
+// Slot names a place on an entity where an item can be equipped.
+type Slot string
+
+// Available equipment slots. The primary and secondary slots are the hands
+// items are held in, while the hands slot is worn, such as gloves.
+const (
+	HeadSlot      Slot = "head"
+	HandsSlot     Slot = "hands"
+	PrimarySlot   Slot = "primary"
+	SecondarySlot Slot = "secondary"
+	LegsSlot      Slot = "legs"
+	ChestSlot     Slot = "chest"
+)
+
+// Slots returns every equipment slot.
+func Slots() []Slot {
+	return []Slot{HeadSlot, HandsSlot, PrimarySlot, SecondarySlot, LegsSlot, ChestSlot}
+}
+
+// Held returns true for the slots items are held in.
+func (slot Slot) Held() bool {
+	return slot == PrimarySlot || slot == SecondarySlot
+}
+
+// slot returns a pointer to the item ID in the named slot, or nil if there is
+// no such slot.
+func (eq *Equipment) slot(slot Slot) *uuid.UUID {
+	switch slot {
+	case HeadSlot:
+		return &eq.HeadID
+	case HandsSlot:
+		return &eq.HandsID
+	case PrimarySlot:
+		return &eq.PrimaryItemID
+	case SecondarySlot:
+		return &eq.SecondaryItemID
+	case LegsSlot:
+		return &eq.LegsID
+	case ChestSlot:
+		return &eq.ChestID
+	}
+
+	return nil
+}
+
+// Item returns the ID of the item in the slot, and whether the slot holds
+// anything.
+func (eq Equipment) Item(slot Slot) (uuid.UUID, bool) {
+	id := eq.slot(slot)
+	if id == nil || uuid.Equal(*id, uuid.Nil) {
+		return uuid.Nil, false
+	}
+
+	return *id, true
+}
+
+// Put places the item into the slot, returning false if there is no such
+// slot. Putting uuid.Nil empties the slot.
+func (eq *Equipment) Put(slot Slot, id uuid.UUID) bool {
+	existing := eq.slot(slot)
+	if existing == nil {
+		return false
+	}
+
+	*existing = id
+	return true
+}
+
+// Equipped returns the slots the item is equipped in; two-handed items are
+// equipped in both hands.
+func (eq Equipment) Equipped(id uuid.UUID) []Slot {
+	slots := make([]Slot, 0)
+	for _, slot := range Slots() {
+		if itemID, ok := eq.Item(slot); ok && uuid.Equal(itemID, id) {
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots
+}
+
+// Glyph is a character drawn with foreground and background colors. Colors
+// are numbered as in termbox: 0 is the terminal default, followed by black,
+// red, green, yellow, blue, magenta, cyan and white.
+type Glyph struct {
+	Character  rune `json:"character"`
+	Foreground int  `json:"foreground"`
+	Background int  `json:"background"`
+}
+
+// Renderable is a component describing how an entity is drawn by clients.
+// Toggleable entities may override the glyph drawn while open or closed.
+type Renderable struct {
+	Glyph
+	Open   *Glyph `json:"open,omitempty"`
+	Closed *Glyph `json:"closed,omitempty"`
+}
+
+// For returns the glyph to draw for an entity with the given passability.
+func (r Renderable) For(passability Passability) Glyph {
+	if passability.Type != Toggleable {
+		return r.Glyph
+	}
+	if passability.IsOpen && r.Open != nil {
+		return *r.Open
+	}
+	if !passability.IsOpen && r.Closed != nil {
+		return *r.Closed
+	}
+
+	return r.Glyph
+}
+
+// AI is a component for entities which act on their own, rather than being
+// controlled by a player. Hostile entities hunt down anything in sight which
+// is not controlled by AI.
+type AI struct {
+	Hostile bool `json:"hostile"`
+}
+
+// Effect is a lasting effect on an entity, such as poison, which changes its
+// health every tick until no ticks remain.
+type Effect struct {
+	Name      string `json:"name"`
+	Health    int    `json:"health"`
+	Remaining int    `json:"remaining"`
+}
+
+// Effects is a component holding the effects currently active on an entity.
+type Effects struct {
+	Active []Effect `json:"active"`
+}
+
+// Static is a component marking entities which stay where they are, such as
+// walls and doors. Players remember static entities once they are out of view.
+type Static struct{}
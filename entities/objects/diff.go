@@ -0,0 +1,187 @@
+package objects
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+// pathSeparator joins the JSON keys making up the path to a field.
+const pathSeparator = "."
+
+// Diff describes how an entity has changed, as the fields which were changed
+// and the fields which were removed. Fields are identified by the dotted path
+// of their JSON keys, such as "position.x".
+type Diff struct {
+	Changed map[string]interface{} `json:"changed,omitempty"`
+	Removed []string               `json:"removed,omitempty"`
+}
+
+// Empty returns true when the diff contains no changes.
+func (d Diff) Empty() bool {
+	return len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// MakeDiff returns the difference between the before and after versions of an
+// entity.
+func MakeDiff(before, after Entity) (Diff, error) {
+	d := Diff{Changed: make(map[string]interface{})}
+
+	old, err := flatten(before)
+	if err != nil {
+		return d, err
+	}
+	updated, err := flatten(after)
+	if err != nil {
+		return d, err
+	}
+
+	for path, value := range updated {
+		if oldValue, ok := old[path]; !ok || !reflect.DeepEqual(oldValue, value) {
+			d.Changed[path] = value
+		}
+	}
+	for path := range old {
+		if _, ok := updated[path]; !ok {
+			d.Removed = append(d.Removed, path)
+		}
+	}
+	sort.Strings(d.Removed)
+
+	return d, nil
+}
+
+// Patch returns a copy of the entity with the diff applied to it.
+func (e Entity) Patch(d Diff) (Entity, error) {
+	bites, err := json.Marshal(e)
+	if err != nil {
+		return e, errors.New(err)
+	}
+
+	fields := make(map[string]interface{})
+	err = json.Unmarshal(bites, &fields)
+	if err != nil {
+		return e, errors.New(err)
+	}
+
+	for _, path := range d.Removed {
+		keys := strings.Split(path, pathSeparator)
+		parent := lookup(fields, keys[:len(keys)-1], false)
+		if parent != nil {
+			delete(parent, keys[len(keys)-1])
+		}
+		prune(fields, keys[:len(keys)-1], d.Changed)
+	}
+	for path, value := range d.Changed {
+		keys := strings.Split(path, pathSeparator)
+		parent := lookup(fields, keys[:len(keys)-1], true)
+		parent[keys[len(keys)-1]] = value
+	}
+
+	bites, err = json.Marshal(fields)
+	if err != nil {
+		return e, errors.New(err)
+	}
+
+	patched := Entity{}
+	err = json.Unmarshal(bites, &patched)
+	if err != nil {
+		return e, errors.New(err)
+	}
+
+	return patched, nil
+}
+
+// flatten returns the entity's JSON fields keyed by their dotted path. Nested
+// objects are flattened, while lists are kept as single values.
+func flatten(e Entity) (map[string]interface{}, error) {
+	bites, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	fields := make(map[string]interface{})
+	err = json.Unmarshal(bites, &fields)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	flat := make(map[string]interface{})
+	flattenInto(flat, "", fields)
+
+	return flat, nil
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, fields map[string]interface{}) {
+	for key, value := range fields {
+		path := key
+		if prefix != "" {
+			path = prefix + pathSeparator + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			// Empty objects are kept as values, so that a component with no
+			// fields is still seen to be added or removed.
+			if len(v) == 0 {
+				flat[path] = v
+				continue
+			}
+			flattenInto(flat, path, v)
+		case float64:
+			// Whole numbers are kept as integers, so they stay compact when
+			// encoded by the binary codec.
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				flat[path] = int64(v)
+			} else {
+				flat[path] = v
+			}
+		default:
+			flat[path] = v
+		}
+	}
+}
+
+// lookup returns the nested object found by following the keys from fields.
+// Missing objects are created when create is true; otherwise nil is returned.
+func lookup(fields map[string]interface{}, keys []string, create bool) map[string]interface{} {
+	for _, key := range keys {
+		next, ok := fields[key].(map[string]interface{})
+		if !ok {
+			if !create {
+				return nil
+			}
+			next = make(map[string]interface{})
+			fields[key] = next
+		}
+		fields = next
+	}
+
+	return fields
+}
+
+// prune removes the objects along the keys which were left empty by removing
+// their fields, such as a component which was detached from the entity.
+// Objects whose path was changed are kept.
+func prune(fields map[string]interface{}, keys []string, changed map[string]interface{}) {
+	for i := len(keys); i > 0; i-- {
+		path := strings.Join(keys[:i], pathSeparator)
+		if _, ok := changed[path]; ok {
+			return
+		}
+
+		parent := lookup(fields, keys[:i-1], false)
+		if parent == nil {
+			return
+		}
+		child, ok := parent[keys[i-1]].(map[string]interface{})
+		if !ok || len(child) > 0 {
+			return
+		}
+		delete(parent, keys[i-1])
+	}
+}
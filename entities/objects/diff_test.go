@@ -0,0 +1,140 @@
+package objects_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+var orcID = uuid.FromStringOrNil("dc71d346-ac20-4f1b-be03-f51bd4eddff3")
+
+// orc returns an entity with the ID of the orc, made of the components.
+func orc(components ...objects.Component) objects.Entity {
+	e := objects.Entity{ID: orcID}
+	for _, c := range components {
+		e.Set(c)
+	}
+
+	return e
+}
+
+var (
+	glyph = objects.Glyph{Character: 'o', Foreground: 2}
+	door  = objects.Renderable{
+		Glyph: objects.Glyph{Character: '+'},
+		Open:  &objects.Glyph{Character: '\''},
+	}
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  objects.Entity
+		after   objects.Entity
+		changed map[string]interface{}
+		removed []string
+	}{
+		{
+			"nothing changed",
+			orc(objects.Position{X: 1, Y: 2}),
+			orc(objects.Position{X: 1, Y: 2}),
+			map[string]interface{}{},
+			nil,
+		},
+		{
+			"a field of a component changed",
+			orc(objects.Position{X: 1, Y: 2}, objects.Health{Current: 5, Max: 5}),
+			orc(objects.Position{X: 2, Y: 2}, objects.Health{Current: 5, Max: 5}),
+			map[string]interface{}{"position.x": int64(2)},
+			nil,
+		},
+		{
+			"a field nested within a component changed",
+			orc(door),
+			orc(objects.Renderable{Glyph: door.Glyph, Open: &objects.Glyph{Character: '/'}}),
+			map[string]interface{}{"renderable.open.character": int64('/')},
+			nil,
+		},
+		{
+			"an object nested within a component was removed",
+			orc(door),
+			orc(objects.Renderable{Glyph: door.Glyph}),
+			map[string]interface{}{},
+			[]string{"renderable.open.background", "renderable.open.character", "renderable.open.foreground"},
+		},
+		{
+			"a component was added",
+			orc(objects.Position{X: 1, Y: 2}),
+			orc(objects.Position{X: 1, Y: 2}, objects.Renderable{Glyph: glyph}),
+			map[string]interface{}{
+				"renderable.character":  int64('o'),
+				"renderable.foreground": int64(2),
+				"renderable.background": int64(0),
+			},
+			nil,
+		},
+		{
+			"a component was removed",
+			orc(objects.Position{X: 1, Y: 2}, objects.Health{Current: 5, Max: 5}),
+			orc(objects.Position{X: 1, Y: 2}),
+			map[string]interface{}{},
+			[]string{"health.current", "health.max"},
+		},
+		{
+			"a component without fields was added",
+			orc(objects.Position{X: 1, Y: 2}),
+			orc(objects.Position{X: 1, Y: 2}, objects.Static{}),
+			map[string]interface{}{"static": map[string]interface{}{}},
+			nil,
+		},
+		{
+			"a component without fields was removed",
+			orc(objects.Position{X: 1, Y: 2}, objects.Static{}),
+			orc(objects.Position{X: 1, Y: 2}),
+			map[string]interface{}{},
+			[]string{"static"},
+		},
+	}
+
+	for _, test := range tests {
+		diff, err := objects.MakeDiff(test.before, test.after)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(diff.Changed, test.changed) || !reflect.DeepEqual(diff.Removed, test.removed) {
+			t.Errorf("%s: diff = %+v, want changed %v and removed %v", test.name, diff, test.changed, test.removed)
+		}
+		if diff.Empty() != (len(test.changed) == 0 && len(test.removed) == 0) {
+			t.Errorf("%s: diff.Empty() = %t", test.name, diff.Empty())
+		}
+
+		// Diffs are patched by clients once they have been sent over the wire,
+		// so they are round tripped through JSON first.
+		bites, err := json.Marshal(diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent := objects.Diff{}
+		err = json.Unmarshal(bites, &sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, d := range []objects.Diff{diff, sent} {
+			patched, err := test.before.Patch(d)
+			if err != nil {
+				t.Errorf("%s: patching: %s", test.name, err)
+				continue
+			}
+			if !reflect.DeepEqual(patched, test.after) {
+				t.Errorf("%s: patched entity = %v, want %v", test.name, patched.Fields(), test.after.Fields())
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package objects
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Entity is used to represent a single in-world object, identified by its ID
+// and made up of whichever components are attached to it. A wall may only
+// have a position and passability, while a player also has health,
+// attributes, equipment and so on.
+//
+// Entities are marshalled as a single object containing the ID and each
+// component, keyed by the name it was registered under. Fields and Decode
+// allow codecs other than JSON to do the same.
+type Entity struct {
+	ID         uuid.UUID
+	components map[string]Component
+}
+
+func (e Entity) String() string {
+	return fmt.Sprintf("Entity(%s)", e.ID.String())
+}
+
+// New instantiates a new Entity instance and returns it's pointer.
+func New() *Entity {
+	var err error
+
+	e := new(Entity)
+	e.ID, err = uuid.NewV4()
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+// Get returns the named component, if attached to the entity.
+func (e Entity) Get(name string) (Component, bool) {
+	c, ok := e.components[name]
+	return c, ok
+}
+
+// Has returns true when all of the named components are attached to the
+// entity.
+func (e Entity) Has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := e.components[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Static returns true when the entity has been marked as staying where it is,
+// such as a wall or a door.
+func (e Entity) Static() bool {
+	return e.Has(StaticComponent)
+}
+
+// Components returns the sorted names of every component attached to the
+// entity.
+func (e Entity) Components() []string {
+	names := make([]string, 0, len(e.components))
+	for name := range e.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Set attaches the component to the entity, replacing any component of the
+// same type. The component's type must have been registered, otherwise this
+// call panics.
+func (e *Entity) Set(c Component) {
+	c = reflect.Indirect(reflect.ValueOf(c)).Interface()
+
+	name, ok := ComponentNameOf(c)
+	if !ok {
+		panic(fmt.Sprintf("unregistered component type: %s", reflect.TypeOf(c)))
+	}
+
+	e.set(name, c)
+}
+
+// Remove detaches the named component from the entity.
+func (e *Entity) Remove(name string) {
+	if _, ok := e.components[name]; !ok {
+		return
+	}
+
+	// Entities are passed around as values, so the components are copied
+	// rather than modified, to leave any other copies of the entity as they
+	// were.
+	components := make(map[string]Component, len(e.components))
+	for n, c := range e.components {
+		if n != name {
+			components[n] = c
+		}
+	}
+	e.components = components
+}
+
+func (e *Entity) set(name string, c Component) {
+	components := make(map[string]Component, len(e.components)+1)
+	for n, existing := range e.components {
+		components[n] = existing
+	}
+	components[name] = c
+	e.components = components
+}
+
+// Timer returns the entity's Timer component, if it has one.
+func (e Entity) Timer() (Timer, bool) {
+	c, ok := e.components[TimerComponent].(Timer)
+	return c, ok
+}
+
+// Energy returns the entity's Energy component, if it has one.
+func (e Entity) Energy() (Energy, bool) {
+	c, ok := e.components[EnergyComponent].(Energy)
+	return c, ok
+}
+
+// Position returns the entity's Position component, if it has one.
+func (e Entity) Position() (Position, bool) {
+	c, ok := e.components[PositionComponent].(Position)
+	return c, ok
+}
+
+// Passability returns the entity's Passability component, if it has one.
+func (e Entity) Passability() (Passability, bool) {
+	c, ok := e.components[PassabilityComponent].(Passability)
+	return c, ok
+}
+
+// Health returns the entity's Health component, if it has one.
+func (e Entity) Health() (Health, bool) {
+	c, ok := e.components[HealthComponent].(Health)
+	return c, ok
+}
+
+// Attributes returns the entity's Attributes component, if it has one.
+func (e Entity) Attributes() (Attributes, bool) {
+	c, ok := e.components[AttributesComponent].(Attributes)
+	return c, ok
+}
+
+// Inventory returns the entity's Inventory component, if it has one.
+func (e Entity) Inventory() (Inventory, bool) {
+	c, ok := e.components[InventoryComponent].(Inventory)
+	return c, ok
+}
+
+// Equipment returns the entity's Equipment component, if it has one.
+func (e Entity) Equipment() (Equipment, bool) {
+	c, ok := e.components[EquipmentComponent].(Equipment)
+	return c, ok
+}
+
+// Renderable returns the entity's Renderable component, if it has one.
+func (e Entity) Renderable() (Renderable, bool) {
+	c, ok := e.components[RenderableComponent].(Renderable)
+	return c, ok
+}
+
+// Fields returns the ID and components of the entity, keyed as they are
+// marshalled.
+func (e Entity) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, len(e.components)+1)
+	fields["id"] = e.ID
+	for name, c := range e.components {
+		fields[name] = c
+	}
+
+	return fields
+}
+
+// Decode sets the ID and components of the entity from their encoded fields,
+// using the unmarshal function of the codec they were encoded with.
+func (e *Entity) Decode(fields map[string][]byte, unmarshal func([]byte, interface{}) error) error {
+	*e = Entity{}
+
+	for name, data := range fields {
+		if name == "id" {
+			err := unmarshal(data, &e.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		c, ok := NewComponent(name)
+		if !ok {
+			return errors.Errorf("unknown component: %s", name)
+		}
+
+		err := unmarshal(data, c)
+		if err != nil {
+			return err
+		}
+		e.set(name, reflect.Indirect(reflect.ValueOf(c)).Interface())
+	}
+
+	return nil
+}
+
+// MarshalJSON marshals the entity as its ID and components.
+func (e Entity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Fields())
+}
+
+// UnmarshalJSON unmarshals the entity from its ID and components.
+func (e *Entity) UnmarshalJSON(data []byte) error {
+	raw := make(map[string]json.RawMessage)
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string][]byte, len(raw))
+	for name, data := range raw {
+		fields[name] = data
+	}
+
+	return e.Decode(fields, json.Unmarshal)
+}
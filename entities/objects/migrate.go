@@ -0,0 +1,173 @@
+package objects
+
+import (
+	"encoding/json"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// strippedLegacyComponents are the components which every legacy entity
+// carried, whether or not they had any use for them. When migrating, they are
+// only kept if they hold something other than their zero value.
+//
+// Position and Passability are always kept, as their zero values are
+// meaningful: the origin, and impassible respectively.
+var strippedLegacyComponents = []string{
+	TimerComponent,
+	EnergyComponent,
+	AttributesComponent,
+	InventoryComponent,
+	EquipmentComponent,
+}
+
+// legacyActorComponents are the components which only entities that act
+// would have filled in. Legacy entities with any of them left after stripping,
+// or which had acts, are given a ready timer so that they are delayed after
+// acting again.
+var legacyActorComponents = []string{
+	AttributesComponent,
+	InventoryComponent,
+	EquipmentComponent,
+}
+
+// isLegacyEntity returns true when the fields are those of an entity saved
+// before entities were made of components. Such entities always stored their
+// health as a bare number, and may have an unused ui block.
+func isLegacyEntity(fields map[string]json.RawMessage) bool {
+	if _, ok := fields["ui"]; ok {
+		return true
+	}
+	if _, ok := fields["max_health"]; ok {
+		return true
+	}
+
+	var health int
+	if data, ok := fields[HealthComponent]; ok && json.Unmarshal(data, &health) == nil {
+		return true
+	}
+
+	return false
+}
+
+// MigrateLegacyEntity converts the fields of an entity saved before entities
+// were made of components into components. The ui block becomes a Renderable
+// component, the bare health and max health become a Health component, and
+// unused components are dropped. Legacy timers held a wall-clock timestamp,
+// which means nothing as a tick, so entities which act are given a timer which
+// is ready straight away. The fields of any other entity are returned as they are.
+func MigrateLegacyEntity(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	if !isLegacyEntity(fields) {
+		return fields, nil
+	}
+
+	migrated := make(map[string]json.RawMessage, len(fields))
+	for name, data := range fields {
+		migrated[name] = data
+	}
+
+	if data, ok := migrated["ui"]; ok {
+		migrated[RenderableComponent] = data
+		delete(migrated, "ui")
+	}
+
+	health := Health{}
+	if data, ok := migrated[HealthComponent]; ok {
+		err := json.Unmarshal(data, &health.Current)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+	}
+	if data, ok := migrated["max_health"]; ok {
+		err := json.Unmarshal(data, &health.Max)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		delete(migrated, "max_health")
+	}
+	delete(migrated, HealthComponent)
+	if health != (Health{}) {
+		data, err := json.Marshal(health)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		migrated[HealthComponent] = data
+	}
+
+	acts := false
+	if data, ok := migrated[TimerComponent]; ok {
+		legacy := struct {
+			NextTimestamp *int64 `json:"next_timestamp"`
+		}{}
+		err := json.Unmarshal(data, &legacy)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+
+		if legacy.NextTimestamp != nil {
+			acts = *legacy.NextTimestamp != 0
+			delete(migrated, TimerComponent)
+		}
+	}
+
+	for _, name := range strippedLegacyComponents {
+		data, ok := migrated[name]
+		if !ok {
+			continue
+		}
+
+		var value interface{}
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+
+		if isZero(value) {
+			delete(migrated, name)
+		}
+	}
+
+	for _, name := range legacyActorComponents {
+		if _, ok := migrated[name]; ok {
+			acts = true
+		}
+	}
+	if _, ok := migrated[TimerComponent]; acts && !ok {
+		migrated[TimerComponent] = json.RawMessage(`{"next_tick":0}`)
+	}
+
+	return migrated, nil
+}
+
+// isZero returns true when the decoded JSON value holds nothing but zero
+// values: zero, false, empty strings or nil UUIDs. The component's current
+// type is not used, so that fields renamed in later versions of the save
+// format are not mistaken for being empty.
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == "" || v == uuid.Nil.String()
+	case []interface{}:
+		for _, e := range v {
+			if !isZero(e) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, e := range v {
+			if !isZero(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
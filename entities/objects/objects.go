@@ -1,20 +1,49 @@
 package objects
 
 import (
+	"encoding/json"
 	"fmt"
-	"math"
-	"time"
+	"sort"
 
 	"github.com/clagraff/pitch/logging"
 	uuid "github.com/satori/go.uuid"
 )
 
-// Collection is used to represent a grouping of Entity objects.
-type Collection []Entity
+// Collection is used to represent a grouping of Entity objects. Each type of
+// component is stored in its own table, keyed by entity ID, and entities with
+// a position are also indexed by it, so that looking entities up does not
+// require scanning the whole collection.
+//
+// Collections are modified in place: the methods which return a Collection
+// return the same collection, for convenience. Copies of a Collection share
+// the same entities; use Clone to get an independent copy.
+type Collection struct {
+	index *index
+}
+
+// index holds the entities of a collection, along with the order in which
+// they were added so that the collection can be listed deterministically.
+//
+// While changes are being recorded, undo holds a function undoing each of
+// them, oldest first; it is nil otherwise.
+type index struct {
+	ids    map[uuid.UUID]int64
+	tables map[string]map[uuid.UUID]Component
+	cells  map[Position][]uuid.UUID
+	first  int64
+	last   int64
+	undo   []func()
+}
 
 // MakeCollection instantiates a new collection and returns the instance.
 func MakeCollection() Collection {
-	return make([]Entity, 0)
+	return Collection{
+		index: &index{
+			ids:    make(map[uuid.UUID]int64),
+			tables: make(map[string]map[uuid.UUID]Component),
+			cells:  make(map[Position][]uuid.UUID),
+		},
+	}
 }
 
 // NewCollection instantiates a new collection and returns its pointer.
@@ -23,26 +52,301 @@ func NewCollection() *Collection {
 	return &c
 }
 
-// Prepend will insert the provided entity into the first position.
+// MarshalJSON marshals the current Collection as a list of its entities.
+func (c Collection) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Entities())
+}
+
+// UnmarshalJSON unmarshals a list of entities into the current Collection.
+func (c *Collection) UnmarshalJSON(data []byte) error {
+	l := make([]Entity, 0)
+	err := json.Unmarshal(data, &l)
+	if err != nil {
+		return err
+	}
+
+	*c = MakeCollection()
+	for _, e := range l {
+		c.Append(e)
+	}
+
+	return nil
+}
+
+// ensure returns the collection, initializing it first if it is the zero
+// value.
+func (c Collection) ensure() Collection {
+	if c.index == nil {
+		return MakeCollection()
+	}
+
+	return c
+}
+
+// insert adds the entity to the collection with the specified sequence
+// number, replacing any entity with the same ID.
+func (c Collection) insert(e Entity, seq int64) {
+	c.delete(e.ID)
+
+	c.index.ids[e.ID] = seq
+	for name, component := range e.components {
+		table, ok := c.index.tables[name]
+		if !ok {
+			table = make(map[uuid.UUID]Component)
+			c.index.tables[name] = table
+		}
+		table[e.ID] = component
+	}
+
+	if pos, ok := e.Position(); ok {
+		c.index.cells[pos] = append(c.index.cells[pos], e.ID)
+	}
+
+	if c.index.undo != nil {
+		id := e.ID
+		c.index.undo = append(c.index.undo, func() { c.delete(id) })
+	}
+}
+
+// delete removes the entity with the specified ID, returning its sequence
+// number.
+func (c Collection) delete(id uuid.UUID) (int64, bool) {
+	seq, ok := c.index.ids[id]
+	if !ok {
+		return 0, false
+	}
+
+	cell := -1
+	if component, ok := c.index.tables[PositionComponent][id]; ok {
+		pos := component.(Position)
+
+		ids := c.index.cells[pos]
+		for i, cellID := range ids {
+			if uuid.Equal(cellID, id) {
+				cell = i
+				ids = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(c.index.cells, pos)
+		} else {
+			c.index.cells[pos] = ids
+		}
+	}
+
+	if c.index.undo != nil {
+		e := c.entity(id)
+		c.index.undo = append(c.index.undo, func() { c.restore(e, seq, cell) })
+	}
+
+	delete(c.index.ids, id)
+	for _, table := range c.index.tables {
+		delete(table, id)
+	}
+
+	return seq, true
+}
+
+// restore puts back an entity which was deleted while changes were being
+// recorded, with its original sequence number and place within its cell.
+func (c Collection) restore(e Entity, seq int64, cell int) {
+	c.insert(e, seq)
+
+	pos, ok := e.Position()
+	if !ok || cell < 0 {
+		return
+	}
+
+	ids := c.index.cells[pos]
+	copy(ids[cell+1:], ids[cell:len(ids)-1])
+	ids[cell] = e.ID
+}
+
+// entity assembles the entity with the specified ID from its components.
+func (c Collection) entity(id uuid.UUID) Entity {
+	e := Entity{
+		ID:         id,
+		components: make(map[string]Component),
+	}
+	for name, table := range c.index.tables {
+		if component, ok := table[id]; ok {
+			e.components[name] = component
+		}
+	}
+
+	return e
+}
+
+// sorted returns the entities with the specified IDs, in the order they were
+// added to the collection.
+func (c Collection) sorted(ids []uuid.UUID) []Entity {
+	sort.Slice(ids, func(i, j int) bool {
+		return c.index.ids[ids[i]] < c.index.ids[ids[j]]
+	})
+
+	entities := make([]Entity, len(ids))
+	for i, id := range ids {
+		entities[i] = c.entity(id)
+	}
+
+	return entities
+}
+
+// Len returns the number of entities in the collection.
+func (c Collection) Len() int {
+	if c.index == nil {
+		return 0
+	}
+
+	return len(c.index.ids)
+}
+
+// Entities returns every entity in the collection, in the order they were
+// added.
+func (c Collection) Entities() []Entity {
+	if c.index == nil {
+		return make([]Entity, 0)
+	}
+
+	ids := make([]uuid.UUID, 0, len(c.index.ids))
+	for id := range c.index.ids {
+		ids = append(ids, id)
+	}
+
+	return c.sorted(ids)
+}
+
+// With returns every entity in the collection which has all of the named
+// components, in the order they were added.
+func (c Collection) With(names ...string) []Entity {
+	if c.index == nil || len(names) == 0 {
+		return c.Entities()
+	}
+
+	// Start from the smallest table, as every match must be within it.
+	var smallest map[uuid.UUID]Component
+	for _, name := range names {
+		table := c.index.tables[name]
+		if smallest == nil || len(table) < len(smallest) {
+			smallest = table
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(smallest))
+	for id := range smallest {
+		matches := true
+		for _, name := range names {
+			if _, ok := c.index.tables[name][id]; !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			ids = append(ids, id)
+		}
+	}
+
+	return c.sorted(ids)
+}
+
+// Clone returns an independent copy of the collection.
+func (c Collection) Clone() Collection {
+	clone := MakeCollection()
+	if c.index == nil {
+		return clone
+	}
+
+	for id, seq := range c.index.ids {
+		clone.index.ids[id] = seq
+	}
+	for name, table := range c.index.tables {
+		cloned := make(map[uuid.UUID]Component, len(table))
+		for id, component := range table {
+			cloned[id] = component
+		}
+		clone.index.tables[name] = cloned
+	}
+	for pos, ids := range c.index.cells {
+		clone.index.cells[pos] = append([]uuid.UUID(nil), ids...)
+	}
+	clone.index.first = c.index.first
+	clone.index.last = c.index.last
+
+	return clone
+}
+
+// Begin starts recording the changes made to the collection, and to every copy
+// of it, so that they can be undone by Rollback. Commit stops recording,
+// keeping the changes. Changes made to a collection which has not been
+// initialized are not recorded, as they are made to a new collection instead.
+func (c Collection) Begin() {
+	if c.index == nil {
+		return
+	}
+
+	first, last := c.index.first, c.index.last
+	c.index.undo = []func(){func() {
+		c.index.first, c.index.last = first, last
+	}}
+}
+
+// Commit stops recording the changes made to the collection, keeping them.
+func (c Collection) Commit() {
+	if c.index == nil {
+		return
+	}
+
+	c.index.undo = nil
+}
+
+// Rollback undoes every change made to the collection since Begin was called,
+// most recent first, and stops recording.
+func (c Collection) Rollback() {
+	if c.index == nil {
+		return
+	}
+
+	undo := c.index.undo
+	c.index.undo = nil
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+}
+
+// Prepend will insert the provided entity into the first position, replacing
+// any entity with the same ID.
 func (c Collection) Prepend(e Entity) Collection {
-	return append([]Entity{e}, c...)
+	c = c.ensure()
+
+	c.index.first--
+	c.insert(e, c.index.first)
+
+	return c
 }
 
-// Insert will insert the provided entity into the collection.
+// Append will insert the provided entity into the last position, replacing
+// any entity with the same ID.
 func (c Collection) Append(e Entity) Collection {
-	return append(c, e)
+	c = c.ensure()
+
+	c.index.last++
+	c.insert(e, c.index.last)
+
+	return c
 }
 
 // FromID will attempt to return an Entity from the current collection,
 // as specified by the provided UUID.
 func (c Collection) FromID(id uuid.UUID) (Entity, bool) {
-	for _, e := range c {
-		if uuid.Equal(e.ID, id) {
-			return e, true
-		}
+	if c.index == nil {
+		return Entity{}, false
+	}
+	if _, ok := c.index.ids[id]; !ok {
+		return Entity{}, false
 	}
 
-	return Entity{}, false
+	return c.entity(id), true
 }
 
 // FromIDString will attempt to return an Entity from the current collection,
@@ -69,39 +373,51 @@ func (c Collection) FromIDString(id string) (Entity, bool) {
 	return foundEntity, wasFound
 }
 
-// FromXY will return a map of all entities which exist in the current
-// collection at the given XY position.
+// FromXY will return all entities which exist in the current collection at
+// the given XY position, in the order they arrived there.
 func (c Collection) FromXY(x, y int) []Entity {
 	foundEntities := make([]Entity, 0)
+	if c.index == nil {
+		return foundEntities
+	}
 
-	for _, e := range c {
-		if e.Position.X == x && e.Position.Y == y {
-			foundEntities = append(foundEntities, e)
-		}
+	for _, id := range c.index.cells[Position{X: x, Y: y}] {
+		foundEntities = append(foundEntities, c.entity(id))
 	}
 
 	return foundEntities
 }
 
-// Remove will remove the first entity in the current collection which
-// matches the specified UUID of the provided entity.
-func (c Collection) Remove(e Entity) (Collection, bool) {
-	newCollection := make(Collection, 0)
-	found := false
+// FromArea will return all entities which exist in the current collection
+// within the rectangle between the minimum and maximum positions, inclusive.
+func (c Collection) FromArea(min, max Position) []Entity {
+	foundEntities := make([]Entity, 0)
+	if c.index == nil {
+		return foundEntities
+	}
 
-	for _, currentEntity := range c {
-		if !uuid.Equal(currentEntity.ID, e.ID) {
-			newCollection = append(newCollection, currentEntity)
-		} else {
-			found = true
+	for x := min.X; x <= max.X; x++ {
+		for y := min.Y; y <= max.Y; y++ {
+			for _, id := range c.index.cells[Position{X: x, Y: y}] {
+				foundEntities = append(foundEntities, c.entity(id))
+			}
 		}
 	}
 
-	return newCollection, found
+	return foundEntities
+}
+
+// Remove will remove the entity in the current collection which matches the
+// specified UUID of the provided entity.
+func (c Collection) Remove(e Entity) (Collection, bool) {
+	c = c.ensure()
+
+	_, found := c.delete(e.ID)
+	return c, found
 }
 
-// MustRemove will remove return a copy of the current collection minus any
-// entities matching the target entity's ID.
+// MustRemove will remove any entities matching the target entity's ID from
+// the current collection.
 // If no entities were removed, this call panics.
 func (c Collection) MustRemove(target Entity) Collection {
 	col, ok := c.Remove(target)
@@ -112,26 +428,21 @@ func (c Collection) MustRemove(target Entity) Collection {
 	return col
 }
 
-// Replace returns a new collection where all instances of the original entity
-// (matched by ID) are replaced with the replacement entity.
+// Replace replaces the original entity (matched by ID) with the replacement
+// entity, in the same position within the collection.
 func (c Collection) Replace(original Entity, replacement Entity) (Collection, bool) {
-	newCollection := make(Collection, 0)
-	found := false
+	c = c.ensure()
 
-	for _, currentEntity := range c {
-		if !uuid.Equal(currentEntity.ID, original.ID) {
-			newCollection = append(newCollection, currentEntity)
-		} else {
-			found = true
-			newCollection = append(newCollection, replacement)
-		}
+	seq, found := c.delete(original.ID)
+	if found {
+		c.insert(replacement, seq)
 	}
 
-	return newCollection, found
+	return c, found
 }
 
-// Replace returns a new collection where all instances of the original entity
-// (matched by ID) are replaced with the replacement entity.
+// MustReplace replaces the original entity (matched by ID) with the
+// replacement entity.
 // If no entities were replaced, this call panics.
 func (c Collection) MustReplace(original, target Entity) Collection {
 	col, ok := c.Replace(original, target)
@@ -142,26 +453,14 @@ func (c Collection) MustReplace(original, target Entity) Collection {
 	return col
 }
 
-// Update returns a new collection where all instances of the original entity
-// (matched by ID) are updated/replaced with the updated version of the entity.
+// Update replaces the entity matching the ID of the target with the updated
+// version of the entity.
 func (c Collection) Update(target Entity) (Collection, bool) {
-	newCollection := make(Collection, 0)
-	found := false
-
-	for _, currentEntity := range c {
-		if !uuid.Equal(currentEntity.ID, target.ID) {
-			newCollection = append(newCollection, currentEntity)
-		} else {
-			found = true
-			newCollection = append(newCollection, target)
-		}
-	}
-
-	return newCollection, found
+	return c.Replace(target, target)
 }
 
-// Update returns a new collection where all instances of the original entity
-// (matched by ID) are updated/replaced with the updated version of the entity.
+// MustUpdate replaces the entity matching the ID of the target with the
+// updated version of the entity.
 // If no entities were replaced, this call panics.
 func (c Collection) MustUpdate(target Entity) Collection {
 	col, ok := c.Update(target)
@@ -177,119 +476,3 @@ func (c Collection) MustUpdate(target Entity) Collection {
 func (c Collection) Clear() Collection {
 	return MakeCollection()
 }
-
-// Timer is used to keep track of when an action is next allowed.
-type Timer struct {
-	NextTimestamp int64 `json:"next_timestamp"`
-}
-
-// Delay is used to increase the delay on the current timer.
-func (timer *Timer) Delay(seconds int) {
-	timer.NextTimestamp = time.Now().Unix() + int64(seconds)
-}
-
-// Ready returns true when the current time elapses the timer's internal
-// timestamp.
-func (timer Timer) Ready() bool {
-	return time.Now().Unix() >= timer.NextTimestamp
-}
-
-// NewTimer returns a new timer. I dont know why. TODO: delete this?
-func NewTimer() *Timer {
-	return new(Timer)
-}
-
-// Position is used to represent XY coordinates.
-type Position struct {
-	X int `json:"x"`
-	Y int `json:"y"`
-}
-
-// PassabilityType represents the different types of passability for an entity.
-type PassabilityType int
-
-// Available passability types:
-const (
-	AlwaysImpassible PassabilityType = iota
-	AlwaysPassible
-	//Keyed
-	Toggleable
-)
-
-// Passability is a component used on entities to describe their state of
-// passibility.
-type Passability struct {
-	Type   PassabilityType `json:"type"`
-	IsOpen bool            `json:"is_open"`
-}
-
-// Attribute represents a DnD-style attribute.
-type Attribute int
-
-// Modifier calculates and returns a modifier based on the state of the current
-// attribute.
-// Minimum value is -5. At level 30, it would be 10.
-func (attr Attribute) Modifier() int {
-	if int(attr) < 0 {
-		return -5
-	}
-
-	// formula f(x) = floor(0.5 * x) - 5
-	modifier := math.Floor((0.5 * float64(attr))) - 5
-	return int(modifier)
-}
-
-// Attributes is a component used to group all available attributes together
-// which can exist for an entity.
-type Attributes struct {
-	Dexterity Attribute `json:"dexterity"`
-	Luck      Attribute `json:"attribute"`
-	Strength  Attribute `json:"strength"`
-	Wisdom    Attribute `json:"wisdom"`
-}
-
-// Inventory is a component for entities which is used to store UUIDs
-// correlating to items, which implies ownership by the entity over these items.
-type Inventory struct {
-	ItemIDs []uuid.UUID `json:"item_ids"`
-}
-
-// Equipment is a component used for specifying which items are currently
-// "equiped" for a given entity.
-type Equipment struct {
-	HeadID          uuid.UUID `json:"head_id"`
-	HandsID         uuid.UUID `json:"hands_id"`
-	PrimaryItemID   uuid.UUID `json:"primary_item_id"`
-	SecondaryItemID uuid.UUID `json:"secondary_item_id"`
-	LegsID          uuid.UUID `json:"legs_id"`
-	ChestID         uuid.UUID `json:"chest_id"`
-}
-
-// Entity is used to represent the amalgamation of various components and
-// attributes for a single in-world object.
-type Entity struct {
-	ID          uuid.UUID   `json:"id"`
-	Timer       Timer       `json:"timer"`
-	Position    Position    `json:"position"`
-	Passability Passability `json:"passability"`
-	Attributes  Attributes  `json:"attributes"`
-	Equipment   Equipment   `json:"equipment"`
-	Health      int         `json:"health"`
-}
-
-func (e Entity) String() string {
-	return fmt.Sprintf("Entity(%s)", e.ID.String())
-}
-
-// New instantiates a new Entity instance and returns it's pointer.
-func New() *Entity {
-	var err error
-
-	e := new(Entity)
-	e.ID, err = uuid.NewV4()
-	if err != nil {
-		panic(err)
-	}
-
-	return e
-}
@@ -0,0 +1,227 @@
+package objects_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/generation"
+)
+
+// sizes are the widths of the square collections benchmarked; 316 by 316
+// holds roughly 100,000 entities.
+var sizes = []int{10, 100, 316}
+
+// populated returns a square collection of entities of the size, and the
+// entity in its centre.
+func populated(size int) (objects.Collection, objects.Entity) {
+	source := objects.Entity{}
+	source.Set(objects.Passability{Type: objects.Toggleable, IsOpen: true})
+	source.Set(objects.Health{Current: 10, Max: 10})
+
+	c := objects.MakeCollection()
+	for _, e := range generation.Fill(source, size, size) {
+		c = c.Append(e)
+	}
+
+	centre := c.FromXY(size/2, size/2)[0]
+	return c, centre
+}
+
+func BenchmarkCollectionFromID(b *testing.B) {
+	for _, size := range sizes {
+		c, e := populated(size)
+
+		b.Run(fmt.Sprintf("%d", c.Len()), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c.FromID(e.ID)
+			}
+		})
+	}
+}
+
+func BenchmarkCollectionUpdate(b *testing.B) {
+	for _, size := range sizes {
+		c, e := populated(size)
+
+		b.Run(fmt.Sprintf("%d", c.Len()), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c = c.MustUpdate(e)
+			}
+		})
+	}
+}
+
+// labels names the entities placed by the tests.
+var labels = make(map[uuid.UUID]string)
+
+// placed returns an entity with the label, at the position.
+func placed(label string, x, y int) objects.Entity {
+	e := objects.New()
+	e.Set(objects.Position{X: x, Y: y})
+	labels[e.ID] = label
+	return *e
+}
+
+// describe lists the labels of the entities in the collection in order,
+// followed by the labels of the entities in each cell along the top row.
+func describe(c objects.Collection) string {
+	names := func(l []objects.Entity) string {
+		s := make([]string, len(l))
+		for i, e := range l {
+			s[i] = labels[e.ID]
+		}
+		return strings.Join(s, ",")
+	}
+
+	cells := make([]string, 4)
+	for x := range cells {
+		cells[x] = names(c.FromXY(x, 0))
+	}
+
+	return names(c.Entities()) + " | " + strings.Join(cells, " ")
+}
+
+// consistent returns an error if an entity is not listed in the cell of its
+// position, or a cell lists an entity which is not there.
+func consistent(c objects.Collection) error {
+	for _, e := range c.Entities() {
+		pos, ok := e.Position()
+		if !ok {
+			continue
+		}
+
+		found := false
+		for _, other := range c.FromXY(pos.X, pos.Y) {
+			if other.ID == e.ID {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not listed at %+v", e.ID, pos)
+		}
+	}
+
+	for x := 0; x < 4; x++ {
+		for _, e := range c.FromXY(x, 0) {
+			current, ok := c.FromID(e.ID)
+			if !ok {
+				return fmt.Errorf("%s is listed at x %d, but was removed", e.ID, x)
+			}
+			if pos, _ := current.Position(); pos != (objects.Position{X: x, Y: 0}) {
+				return fmt.Errorf("%s is listed at x %d, but is at %+v", e.ID, x, pos)
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestCollectionRollback(t *testing.T) {
+	move := func(e objects.Entity, x int) func(objects.Collection) objects.Collection {
+		return func(c objects.Collection) objects.Collection {
+			e, _ = c.FromID(e.ID)
+			e.Set(objects.Position{X: x, Y: 0})
+			return c.MustUpdate(e)
+		}
+	}
+	remove := func(e objects.Entity) func(objects.Collection) objects.Collection {
+		return func(c objects.Collection) objects.Collection {
+			return c.MustRemove(e)
+		}
+	}
+
+	a, b, c, d := placed("a", 0, 0), placed("b", 0, 0), placed("c", 0, 0), placed("d", 1, 0)
+
+	tests := []struct {
+		name      string
+		changes   []func(objects.Collection) objects.Collection
+		committed string
+	}{
+		{
+			"moving an entity",
+			[]func(objects.Collection) objects.Collection{move(b, 1)},
+			"a,b,c,d | a,c d,b  ",
+		},
+		{
+			"moving an entity back and forth",
+			[]func(objects.Collection) objects.Collection{move(a, 2), move(a, 3), move(a, 0)},
+			"a,b,c,d | b,c,a d  ",
+		},
+		{
+			"moving several entities",
+			[]func(objects.Collection) objects.Collection{move(c, 2), move(d, 2), move(a, 1)},
+			"a,b,c,d | b a c,d ",
+		},
+		{
+			"removing entities",
+			[]func(objects.Collection) objects.Collection{remove(b), remove(d)},
+			"a,c | a,c   ",
+		},
+		{
+			"moving an entity then removing it",
+			[]func(objects.Collection) objects.Collection{move(a, 3), remove(a)},
+			"b,c,d | b,c d  ",
+		},
+		{
+			"adding entities",
+			[]func(objects.Collection) objects.Collection{
+				func(col objects.Collection) objects.Collection { return col.Append(placed("e", 0, 0)) },
+				func(col objects.Collection) objects.Collection { return col.Prepend(placed("f", 3, 0)) },
+			},
+			"f,a,b,c,d,e | a,b,c,e d  f",
+		},
+		{
+			"removing an entity then adding it back",
+			[]func(objects.Collection) objects.Collection{
+				remove(b),
+				func(col objects.Collection) objects.Collection { return col.Append(b) },
+			},
+			"a,c,d,b | a,c,b d  ",
+		},
+	}
+
+	for _, test := range tests {
+		col := objects.MakeCollection()
+		for _, e := range []objects.Entity{a, b, c, d} {
+			col = col.Append(e)
+		}
+		before := describe(col)
+
+		for _, rollback := range []bool{true, false} {
+			col.Begin()
+			for _, change := range test.changes {
+				col = change(col)
+			}
+
+			if err := consistent(col); err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			if got := describe(col); got != test.committed {
+				t.Errorf("%s: got %q, want %q", test.name, got, test.committed)
+			}
+
+			if !rollback {
+				col.Commit()
+				break
+			}
+
+			col.Rollback()
+			if err := consistent(col); err != nil {
+				t.Errorf("%s: after rolling back: %v", test.name, err)
+			}
+			if got := describe(col); got != before {
+				t.Errorf("%s: after rolling back, got %q, want %q", test.name, got, before)
+			}
+		}
+
+		// Once committed, the changes are no longer recorded.
+		col.Rollback()
+		if got := describe(col); got != test.committed {
+			t.Errorf("%s: after committing, got %q, want %q", test.name, got, test.committed)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package fov calculates what can be seen from a position, using symmetric
+// shadowcasting: each of the four quadrants around the origin is scanned row by
+// row, moving outwards, and anything opaque casts a shadow over the rows
+// behind it. Sight is symmetric, so a position which can be seen from another
+// can also see it, unless either is opaque.
+package fov
+
+import (
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// Visible is the set of positions which can be seen.
+type Visible map[objects.Position]struct{}
+
+// Contains returns true when the position can be seen.
+func (v Visible) Contains(pos objects.Position) bool {
+	_, ok := v[pos]
+	return ok
+}
+
+// quadrants transform a column and row within a quadrant, with rows moving
+// away from the origin, into x and y offsets from the origin, as the
+// multipliers of the column and row for x and then y.
+var quadrants = [4][4]int{
+	{1, 0, 0, -1},
+	{0, 1, 1, 0},
+	{1, 0, 0, 1},
+	{0, -1, 1, 0},
+}
+
+// Compute returns every position which can be seen from the origin, within a
+// circle of the radius. Opaque positions can be seen, but block sight of
+// anything behind them. The origin can always be seen.
+func Compute(origin objects.Position, radius int, opaque func(objects.Position) bool) Visible {
+	visible := Visible{origin: {}}
+	if radius <= 0 {
+		return visible
+	}
+
+	for _, q := range quadrants {
+		c := caster{
+			origin:  origin,
+			radius:  radius,
+			opaque:  opaque,
+			visible: visible,
+			cx:      q[0],
+			rx:      q[1],
+			cy:      q[2],
+			ry:      q[3],
+		}
+		c.scan(1, slope{-1, 1}, slope{1, 1})
+	}
+
+	return visible
+}
+
+// slope is the fraction n/d, with d always positive, of columns per row.
+type slope struct {
+	n, d int
+}
+
+// tileSlope returns the slope of the near left corner of the tile at the
+// column and row.
+func tileSlope(col, row int) slope {
+	return slope{2*col - 1, 2 * row}
+}
+
+// caster scans a single quadrant.
+type caster struct {
+	origin  objects.Position
+	radius  int
+	opaque  func(objects.Position) bool
+	visible Visible
+
+	cx, rx, cy, ry int
+}
+
+// position returns the position of the tile at the column and row.
+func (c caster) position(col, row int) objects.Position {
+	return objects.Position{
+		X: c.origin.X + col*c.cx + row*c.rx,
+		Y: c.origin.Y + col*c.cy + row*c.ry,
+	}
+}
+
+// scan lights the tiles of the row between the start and end slopes, and then
+// the rows behind it. Whenever an opaque tile is found, the light beside it is
+// cast separately on the next row, so the shadow it casts is skipped.
+func (c caster) scan(row int, start, end slope) {
+	if row > c.radius {
+		return
+	}
+
+	// The row's tiles are those whose centres are between the slopes, with
+	// ties broken outwards for the first tile and inwards for the last.
+	first := floorDiv(2*row*start.n+start.d, 2*start.d)
+	last := -floorDiv(-2*row*end.n+end.d, 2*end.d)
+
+	radiusSquared := c.radius * c.radius
+	wasOpaque, started := false, false
+	for col := first; col <= last; col++ {
+		pos := c.position(col, row)
+		isOpaque := c.opaque(pos)
+
+		// Opaque tiles are seen whenever lit, but other tiles only once their
+		// centre is lit, which keeps sight symmetric.
+		symmetric := col*start.d >= row*start.n && col*end.d <= row*end.n
+		if (isOpaque || symmetric) && col*col+row*row <= radiusSquared {
+			c.visible[pos] = struct{}{}
+		}
+
+		if started && wasOpaque && !isOpaque {
+			start = tileSlope(col, row)
+		}
+		if started && !wasOpaque && isOpaque {
+			c.scan(row+1, start, tileSlope(col, row))
+		}
+
+		wasOpaque, started = isOpaque, true
+	}
+
+	if started && !wasOpaque {
+		c.scan(row+1, start, end)
+	}
+}
+
+// floorDiv divides a by the positive b, rounding towards negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+
+	return q
+}
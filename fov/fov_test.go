@@ -0,0 +1,195 @@
+package fov_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/fov"
+)
+
+// grid is a small fixed map, in which # is opaque and @ is the origin.
+type grid []string
+
+func parse(m string) grid {
+	return grid(strings.Split(strings.TrimPrefix(m, "\n"), "\n"))
+}
+
+func (g grid) opaque(pos objects.Position) bool {
+	if pos.Y < 0 || pos.Y >= len(g) || pos.X < 0 || pos.X >= len(g[pos.Y]) {
+		return false
+	}
+
+	return g[pos.Y][pos.X] == '#'
+}
+
+func (g grid) origin() objects.Position {
+	for y, row := range g {
+		if x := strings.IndexByte(row, '@'); x >= 0 {
+			return objects.Position{X: x, Y: y}
+		}
+	}
+
+	return objects.Position{}
+}
+
+// render draws the map with every position which cannot be seen blanked out,
+// trimming the blanks from the end of each row.
+func (g grid) render(visible fov.Visible) string {
+	rows := make([]string, len(g))
+	for y, row := range g {
+		line := []byte(row)
+		for x := range line {
+			if !visible.Contains(objects.Position{X: x, Y: y}) {
+				line[x] = ' '
+			}
+		}
+		rows[y] = strings.TrimRight(string(line), " ")
+	}
+
+	return "\n" + strings.Join(rows, "\n")
+}
+
+func TestComputeBlocking(t *testing.T) {
+	tests := []struct {
+		name string
+		m    string
+		want string
+	}{
+		{
+			"open ground is seen out to the radius",
+			`
+.......
+.......
+...@...
+.......
+.......`, `
+ .....
+ .....
+...@...
+ .....
+ .....`,
+		},
+		{
+			"a pillar hides what is directly behind it",
+			`
+.......
+...#...
+...@...
+.......
+.......`, `
+ .. ..
+ ..#..
+...@...
+ .....
+ .....`,
+		},
+		{
+			"walls are seen, but not through",
+			`
+.........
+.........
+..#.@.#..
+.........
+.........`, `
+  .....
+  .....
+  #.@.#
+  .....
+  .....`,
+		},
+		{
+			"a room is seen up to its walls",
+			`
+#######
+#.....#
+#.#@#.#
+#.....#
+#######`, `
+ #####
+ .....
+  #@#
+ .....
+ #####`,
+		},
+		{
+			"the radius is a circle",
+			`
+.........
+.........
+.........
+.........
+....@....`, `
+
+    .
+  .....
+  .....
+ ...@...`,
+		},
+	}
+
+	for _, test := range tests {
+		g := parse(test.m)
+		got := g.render(fov.Compute(g.origin(), 3, g.opaque))
+		if got != test.want {
+			t.Errorf("%s: got%s\nwant%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestComputeSymmetric(t *testing.T) {
+	tests := []struct {
+		m      string
+		radius int
+	}{
+		{`
+.........
+..#......
+.....#...
+.........
+...#.....
+.........`, 8},
+		{`
+#########
+#.......#
+#..#.#..#
+#.......#
+#...#...#
+#########`, 8},
+		{`
+..........
+....##....
+..........
+.#......#.
+..........
+...#..#...
+..........`, 4},
+	}
+
+	for _, test := range tests {
+		g := parse(test.m)
+
+		floors := make([]objects.Position, 0)
+		for y, row := range g {
+			for x := range row {
+				if pos := (objects.Position{X: x, Y: y}); !g.opaque(pos) {
+					floors = append(floors, pos)
+				}
+			}
+		}
+
+		seen := make(map[objects.Position]fov.Visible, len(floors))
+		for _, pos := range floors {
+			seen[pos] = fov.Compute(pos, test.radius, g.opaque)
+		}
+
+		for _, a := range floors {
+			for _, b := range floors {
+				if seen[a].Contains(b) != seen[b].Contains(a) {
+					t.Errorf("map%s\n%+v sees %+v is %t, but %+v sees %+v is %t",
+						test.m, a, b, seen[a].Contains(b), b, a, seen[b].Contains(a))
+				}
+			}
+		}
+	}
+}
@@ -21,8 +21,7 @@ func Square(source objects.Entity, size int) []objects.Entity {
 			if err != nil {
 				panic(err)
 			}
-			e.Position.X = x
-			e.Position.Y = y
+			e.Set(objects.Position{X: x, Y: y})
 
 			entityList[i] = e
 			i++
@@ -38,8 +37,7 @@ func Square(source objects.Entity, size int) []objects.Entity {
 			if err != nil {
 				panic(err)
 			}
-			e.Position.X = x
-			e.Position.Y = y
+			e.Set(objects.Position{X: x, Y: y})
 
 			entityList[i] = e
 			i++
@@ -64,8 +62,7 @@ func Fill(source objects.Entity, height, width int) []objects.Entity {
 			if err != nil {
 				panic(err)
 			}
-			e.Position.X = x
-			e.Position.Y = y
+			e.Set(objects.Position{X: x, Y: y})
 
 			entityList[i] = e
 			i++
@@ -81,8 +78,9 @@ func Offset(entityList []objects.Entity, xOffset, yOffset int) []objects.Entity
 	newEntities := make([]objects.Entity, len(entityList))
 
 	for i, e := range entityList {
-		e.Position.X = e.Position.X + xOffset
-		e.Position.Y = e.Position.Y + yOffset
+		if pos, ok := e.Position(); ok {
+			e.Set(objects.Position{X: pos.X + xOffset, Y: pos.Y + yOffset})
+		}
 		newEntities[i] = e
 	}
 
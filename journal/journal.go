@@ -0,0 +1,304 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/utils"
+)
+
+// DefaultPath is the file the server journals requests to, unless configured
+// otherwise.
+const DefaultPath = "journal.jsonl"
+
+// Entry is a single request performed against the world, along with what is
+// needed to perform it again: the tick it was performed on, and the state of
+// the world's RNG just before it was performed. The session is the name of
+// the account which sent the request.
+//
+// Journals are stored as JSON lines, one entry per line, such as:
+//
+//	{"tick":12,"time":"2018-10-14T12:00:00Z","session":"player","rng":{"seed":1,"state":2},"request":{"type":"MoveRequest","request":{...}}}
+//
+// where the request is encoded as by requests.Marshal.
+//
+// Entries without a request mark where the world was saved, and hold the tick
+// and RNG state of the saved world. Each segment of the journal starts with
+// one, so that it can be matched to the save it was recorded from.
+type Entry struct {
+	Tick    uint64
+	Time    time.Time
+	Session string
+	RNG     utils.Source
+	Request requests.Request
+}
+
+type entryFields struct {
+	Tick    uint64          `json:"tick"`
+	Time    time.Time       `json:"time"`
+	Session string          `json:"session"`
+	RNG     utils.Source    `json:"rng"`
+	Request json.RawMessage `json:"request,omitempty"`
+}
+
+// Saved returns true when the entry marks where the world was saved, rather
+// than holding a request.
+func (e Entry) Saved() bool {
+	return e.Request == nil
+}
+
+// MarshalJSON marshals the entry, encoding the request with its type.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	var req json.RawMessage
+	if !e.Saved() {
+		var err error
+		req, err = requests.Marshal(e.Request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(entryFields{
+		Tick:    e.Tick,
+		Time:    e.Time,
+		Session: e.Session,
+		RNG:     e.RNG,
+		Request: req,
+	})
+}
+
+// UnmarshalJSON unmarshals the entry, decoding the request by its type.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	fields := entryFields{}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	var req requests.Request
+	if len(fields.Request) > 0 {
+		req, err = requests.Unmarshal(fields.Request)
+		if err != nil {
+			return err
+		}
+	}
+
+	*e = Entry{
+		Tick:    fields.Tick,
+		Time:    fields.Time,
+		Session: fields.Session,
+		RNG:     fields.RNG,
+		Request: req,
+	}
+	return nil
+}
+
+// Writer appends entries to a journal file. It is safe for concurrent use.
+//
+// The journal is split into segments, one per save of the world, so that the
+// journal file only ever holds the requests performed since the world was
+// last saved. Earlier segments are archived alongside it, named after the tick
+// of the save they were started from.
+type Writer struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	since uint64
+}
+
+// Open starts a new segment of the journal file at the path, from the world
+// as saved at the tick with the RNG state. Any existing journal is archived
+// first, as its requests were performed against some other save, unless it
+// is an empty segment started from the same save.
+func Open(path string, tick uint64, rng utils.Source) (*Writer, error) {
+	w := &Writer{path: path}
+
+	// A journal which cannot be read, such as one cut short by a crash, is
+	// archived as it is.
+	entries, _ := ReadFile(path)
+	if len(entries) > 0 && entries[0].Saved() {
+		w.since = entries[0].Tick
+	}
+
+	if len(entries) == 1 && entries[0].Saved() && entries[0].Tick == tick && entries[0].RNG == rng {
+		var err error
+		w.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		return w, nil
+	}
+
+	err := w.start(tick, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Rotate archives the current segment of the journal, and starts a new one
+// from the world as saved at the tick with the RNG state.
+func (w *Writer) Rotate(tick uint64, rng utils.Source) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.close()
+	if err != nil {
+		return err
+	}
+
+	return w.start(tick, rng)
+}
+
+// start archives the journal file, if there is one, then creates a new one
+// beginning with an entry marking the save it was started from.
+func (w *Writer) start(tick uint64, rng utils.Source) error {
+	err := archive(w.path, w.since)
+	if err != nil {
+		return err
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.New(err)
+	}
+	w.since = tick
+
+	return w.append(Entry{Tick: tick, Time: time.Now().UTC(), RNG: rng})
+}
+
+// Append writes the entry to the end of the journal.
+func (w *Writer) Append(e Entry) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.append(e)
+}
+
+func (w *Writer) append(e Entry) error {
+	bites, err := json.Marshal(e)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	_, err = w.file.Write(append(bites, '\n'))
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// Close flushes the journal to disk and closes it.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.close()
+}
+
+func (w *Writer) close() error {
+	err := w.file.Sync()
+	if err != nil {
+		w.file.Close()
+		return errors.New(err)
+	}
+
+	err = w.file.Close()
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// segmentPath returns the path a segment of the journal at the path is
+// archived to, once it is replaced by a newer one. The segment is named after
+// the tick of the save it was started from, such as journal.120.jsonl, with a
+// further number should that already be taken.
+func segmentPath(path string, since uint64, n int) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if n <= 1 {
+		return fmt.Sprintf("%s.%d%s", base, since, ext)
+	}
+
+	return fmt.Sprintf("%s.%d-%d%s", base, since, n, ext)
+}
+
+// archive moves a non-empty journal file at the path aside, to the first free
+// segment path for the tick it was started from.
+func archive(path string, since uint64) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.New(err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	for n := 1; ; n++ {
+		to := segmentPath(path, since, n)
+		_, err = os.Stat(to)
+		if os.IsNotExist(err) {
+			err = os.Rename(path, to)
+			if err != nil {
+				return errors.New(err)
+			}
+			return nil
+		}
+		if err != nil {
+			return errors.New(err)
+		}
+	}
+}
+
+// Read reads every entry from the journal. Blank lines are skipped.
+func Read(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		e := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, errors.Errorf("journal line %d: %s", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New(err)
+	}
+
+	return entries, nil
+}
+
+// ReadFile reads every entry from the journal file at the path.
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
@@ -0,0 +1,86 @@
+package journal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/journal"
+	"github.com/clagraff/pitch/logging"
+	"github.com/clagraff/pitch/utils"
+)
+
+// Each save starts a new segment of the journal, archiving the last one under
+// the tick of the save it was started from.
+func TestWriterRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = logging.SetDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "journal.jsonl")
+	move := requests.MoveRequest{ActorID: uuid.Must(uuid.NewV4()), Direction: requests.North}
+
+	w, err := journal.Open(path, 0, *utils.NewSource(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Append(journal.Entry{Tick: 3, RNG: *utils.NewSource(1), Request: move})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Rotate(3, *utils.NewSource(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archived, err := journal.ReadFile(filepath.Join(dir, "journal.0.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 2 || !archived[0].Saved() || archived[0].Tick != 0 || archived[1].Saved() {
+		t.Errorf("archived segment = %+v, want the save at tick 0 and a move", archived)
+	}
+
+	current, err := journal.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current) != 1 || !current[0].Saved() || current[0].Tick != 3 || current[0].RNG != *utils.NewSource(2) {
+		t.Errorf("current segment = %+v, want only the save at tick 3", current)
+	}
+
+	// Reopening from the same save carries on with the empty segment, but
+	// opening from any other save starts a new one.
+	w, err = journal.Open(path, 3, *utils.NewSource(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := os.Stat(filepath.Join(dir, "journal.3.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("reopening from the same save archived the segment: %v", err)
+	}
+
+	w, err = journal.Open(path, 0, *utils.NewSource(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := os.Stat(filepath.Join(dir, "journal.3.jsonl")); err != nil {
+		t.Errorf("opening from another save did not archive the segment: %v", err)
+	}
+}
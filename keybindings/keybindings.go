@@ -0,0 +1,53 @@
+// Package keybindings maps the keys pressed in the terminal clients to the
+// actions they perform, so that every client is played the same way.
+package keybindings
+
+import (
+	termbox "github.com/nsf/termbox-go"
+
+	"github.com/clagraff/pitch/comms/requests"
+)
+
+// directionKeys are the keys which move the player: the arrow keys, and the
+// numpad's Home, End, PgUp and PgDn keys for the diagonals.
+var directionKeys = map[termbox.Key]requests.Direction{
+	termbox.KeyArrowUp:    requests.North,
+	termbox.KeyArrowDown:  requests.South,
+	termbox.KeyArrowLeft:  requests.West,
+	termbox.KeyArrowRight: requests.East,
+	termbox.KeyHome:       requests.NorthWest,
+	termbox.KeyPgup:       requests.NorthEast,
+	termbox.KeyEnd:        requests.SouthWest,
+	termbox.KeyPgdn:       requests.SouthEast,
+}
+
+// directionChars are the vi-keys and numpad digits which move the player.
+var directionChars = map[rune]requests.Direction{
+	'k': requests.North,
+	'j': requests.South,
+	'h': requests.West,
+	'l': requests.East,
+	'y': requests.NorthWest,
+	'u': requests.NorthEast,
+	'b': requests.SouthWest,
+	'n': requests.SouthEast,
+	'8': requests.North,
+	'2': requests.South,
+	'4': requests.West,
+	'6': requests.East,
+	'7': requests.NorthWest,
+	'9': requests.NorthEast,
+	'1': requests.SouthWest,
+	'3': requests.SouthEast,
+}
+
+// Direction returns the direction the key event moves the player, if any.
+func Direction(ev termbox.Event) (requests.Direction, bool) {
+	if ev.Ch != 0 {
+		dir, ok := directionChars[ev.Ch]
+		return dir, ok
+	}
+
+	dir, ok := directionKeys[ev.Key]
+	return dir, ok
+}
@@ -4,12 +4,36 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
+var dir = struct {
+	sync.RWMutex
+	path string
+}{path: "logs"}
+
+// SetDir changes the directory logs are written to, creating it if it does not
+// exist.
+func SetDir(path string) error {
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+
+	dir.Lock()
+	defer dir.Unlock()
+
+	dir.path = path
+	return nil
+}
+
 // Logger will setup and return a logger. It will automatically log to a file
 // based on the name provided to the function.
 func Logger(name string) (*log.Logger, func()) {
-	fileName := fmt.Sprintf("logs/%s.log", name)
+	dir.RLock()
+	fileName := filepath.Join(dir.path, fmt.Sprintf("%s.log", name))
+	dir.RUnlock()
 
 	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
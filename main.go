@@ -1,9 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/clagraff/pitch/asciiclient"
+	"github.com/clagraff/pitch/auth"
+	"github.com/clagraff/pitch/config"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/journal"
+	"github.com/clagraff/pitch/saves"
 	"github.com/clagraff/pitch/server"
 	"github.com/go-errors/errors"
 	uuid "github.com/satori/go.uuid"
@@ -35,30 +48,318 @@ func process(requests chan requests.Request, resps chan responses.Response, worl
 }
 */
 
-func main() {
-	args := os.Args[1:]
-	if len(args) == 1 && args[0] == "server" {
-		s := server.NewServer("localhost", 8080)
-		err := s.Serve()
+// addAccount creates or replaces the named account, owning the entities with
+// the specified UUIDs, in the accounts file at the provided path.
+func addAccount(path, name, password string, ids []string) error {
+	accounts, err := auth.LoadAccounts(path)
+	if err != nil {
+		return err
+	}
+
+	entityIDs := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		entityIDs[i], err = uuid.FromString(id)
 		if err != nil {
-			panic(err)
+			return errors.New(err)
 		}
-	} else if len(args) == 2 && args[0] == "client" {
-		id, err := uuid.FromString(args[1])
+	}
+
+	acct, err := auth.NewAccount(name, password, entityIDs...)
+	if err != nil {
+		return err
+	}
+	accounts.Insert(acct)
+
+	return auth.SaveAccounts(path, accounts)
+}
+
+// readPassword reads a password from the first line of stdin, prompting for it
+// on stderr when stdin is a terminal. Passwords are never taken as arguments,
+// where other users could see them in the process list or the shell history.
+func readPassword(prompt string) (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, prompt)
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.New(err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given")
+	}
+
+	return password, nil
+}
+
+// replay performs the journalled requests against the saved world. The
+// resulting world is written to stdout, unless an expected save is provided,
+// in which case any differences from it are written instead. Returns false if
+// there were differences.
+func replay(cfg config.Config, savePath, journalPath, expectedPath string) (bool, error) {
+	world, err := saves.Load(savePath)
+	if err != nil {
+		return false, err
+	}
+
+	entries, err := journal.ReadFile(journalPath)
+	if err != nil {
+		return false, err
+	}
+
+	world, err = server.NewServer(cfg.Host, cfg.Port).Replay(world, entries)
+	if err != nil {
+		return false, err
+	}
+
+	if expectedPath == "" {
+		bites, err := json.MarshalIndent(world, "", "    ")
 		if err != nil {
-			panic("invalid client uuid")
+			return false, errors.New(err)
 		}
+		_, err = fmt.Fprintf(os.Stdout, "%s\n", bites)
+		return true, err
+	}
+
+	expected, err := saves.Load(expectedPath)
+	if err != nil {
+		return false, err
+	}
+
+	diffs, err := entities.Differences(world, expected)
+	if err != nil {
+		return false, err
+	}
+	for _, diff := range diffs {
+		fmt.Fprintln(os.Stdout, diff)
+	}
+
+	return len(diffs) == 0, nil
+}
 
-		err = asciiclient.Run("localhost", 8080, id)
+// rollback replaces the saved world with the numbered snapshot. Without a
+// number, the available snapshots are listed instead.
+func rollback(store saves.WorldStore, args []string) error {
+	if len(args) == 0 {
+		list, err := store.List()
 		if err != nil {
-			if e, ok := err.(*errors.Error); ok {
-				panic(e.ErrorStack())
-			}
-			panic(err)
+			return err
+		}
+
+		for _, snapshot := range list {
+			fmt.Fprintf(os.Stdout, "%d\t%s\t%s\n", snapshot.Number, snapshot.ModTime.Format(time.RFC3339), snapshot.Path)
 		}
+		return nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errors.Errorf("invalid snapshot number: %s", args[0])
+	}
+
+	snapshot, err := store.Rollback(n)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "rolled back to snapshot %d\n", snapshot.Number)
+	return nil
+}
+
+// copySave copies the world from one save to another, such as from a JSON
+// save into a database.
+func copySave(from, to string, keep int) error {
+	source, err := saves.Open(from, keep)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	world, err := source.Load()
+	if err != nil {
+		return err
+	}
+
+	destination, err := saves.Open(to, keep)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	return destination.Save(world)
+}
+
+// migrateSave upgrades the save at the path to the current version of the
+// save format, writing it to the output path, or back in place if empty.
+func migrateSave(path, out string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	_, from, err := saves.Migrate(data)
+	if err != nil {
+		return err
+	}
+
+	world, err := saves.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		out = path
+	}
+	err = saves.Save(out, world)
+	if err != nil {
+		return err
+	}
+
+	if from == saves.Version {
+		fmt.Fprintf(os.Stdout, "%s is already at version %d\n", path, from)
 	} else {
-		panic("invalid program arguments")
+		fmt.Fprintf(os.Stdout, "migrated %s from version %d to %d\n", path, from, saves.Version)
 	}
+	return nil
+}
+
+// commands are pitch's subcommands, in the order they are listed in its
+// usage.
+var commands = []command{
+	{
+		Name:    "server",
+		Summary: "run the game server",
+		Flags:   serverFlags,
+		Run: func(cfg config.Config, args []string) error {
+			s, err := newServer(cfg)
+			if err != nil {
+				return err
+			}
+			return s.Serve()
+		},
+	},
+	{
+		Name:    "client",
+		Args:    "<entity-uuid> <account>",
+		Summary: "connect to the server and play as the entity; the password is read from stdin",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags:   clientFlags,
+		Run: func(cfg config.Config, args []string) error {
+			id, err := uuid.FromString(args[0])
+			if err != nil {
+				return errors.Errorf("invalid entity uuid: %s", args[0])
+			}
+			password, err := readPassword("password: ")
+			if err != nil {
+				return err
+			}
+			return asciiclient.Run(cfg.Host, cfg.Port, id, args[1], password, cfg.Memory)
+		},
+	},
+	{
+		Name:    "account",
+		Args:    "<name> [entity-uuid...]",
+		Summary: "create or replace an account, owning the entities; the password is read from stdin",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Flags:   accountFlags,
+		Run: func(cfg config.Config, args []string) error {
+			password, err := readPassword("password: ")
+			if err != nil {
+				return err
+			}
+			return addAccount(cfg.Accounts, args[0], password, args[1:])
+		},
+	},
+	{
+		Name:    "replay",
+		Args:    "<save> <journal> [expected-save]",
+		Summary: "replay a journal against a save, printing the resulting world",
+		MinArgs: 2,
+		MaxArgs: 3,
+		Run: func(cfg config.Config, args []string) error {
+			expected := ""
+			if len(args) == 3 {
+				expected = args[2]
+			}
+
+			same, err := replay(cfg, args[0], args[1], expected)
+			if err != nil {
+				return err
+			}
+			if !same {
+				return errors.New("the replayed world differs from the expected save")
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "rollback",
+		Args:    "[snapshot]",
+		Summary: "roll the saved world back to a snapshot, or list the snapshots",
+		MaxArgs: 1,
+		Flags:   saveFlags,
+		Run: func(cfg config.Config, args []string) error {
+			store, err := saves.Open(cfg.Save, cfg.Snapshots)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			return rollback(store, args)
+		},
+	},
+	{
+		Name:    "save migrate",
+		Args:    "<save> [output]",
+		Summary: "upgrade a save to the current version of the save format",
+		MinArgs: 1,
+		MaxArgs: 2,
+		Run: func(cfg config.Config, args []string) error {
+			out := ""
+			if len(args) == 2 {
+				out = args[1]
+			}
+			return migrateSave(args[0], out)
+		},
+	},
+	{
+		Name:    "save copy",
+		Args:    "<from> <to>",
+		Summary: "copy the world from one save to another, such as into a database",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags:   saveFlags,
+		Run: func(cfg config.Config, args []string) error {
+			return copySave(args[0], args[1], cfg.Snapshots)
+		},
+	},
+}
+
+// newServer returns a server configured by the config.
+func newServer(cfg config.Config) (*server.Server, error) {
+	store, err := saves.Open(cfg.Save, cfg.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	s := server.NewServer(cfg.Host, cfg.Port)
+	s.Store = store
+	s.AutosaveInterval = time.Duration(cfg.AutosaveInterval)
+	s.AccountsPath = cfg.Accounts
+	s.JournalPath = cfg.Journal
+	s.TickInterval = time.Duration(cfg.TickInterval)
+	s.ViewInterval = time.Duration(cfg.ViewInterval)
+	s.MaxPlayers = cfg.MaxPlayers
+
+	return s, nil
+}
+
+func main() {
+	exit(run(commands, os.Args[1:], os.Stdout))
 
 	/*
 		gameData, err := ioutil.ReadFile("game.save")
@@ -0,0 +1,468 @@
+package saves
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/clagraff/pitch/entities"
+)
+
+// BoltExt is the extension of saves kept in an embedded database.
+const BoltExt = ".db"
+
+// Buckets and keys within the database.
+var (
+	worldBucket     = []byte("world")
+	objectsBucket   = []byte("objects")
+	itemsBucket     = []byte("items")
+	snapshotsBucket = []byte("snapshots")
+
+	// orderKey holds the IDs of the world's objects, in order.
+	orderKey = []byte("order")
+)
+
+// worldFields are the fields of a world saved alongside its objects and
+// items. Each is stored under its own key in the world bucket.
+var worldFields = []string{"version", "mode", "tick", "rng"}
+
+// BoltStore saves the world in an embedded key-value database. Each object and
+// item is stored under its own key, and only those which have changed since
+// the world was last loaded or saved are written, so saving a large world
+// which has barely changed is cheap.
+//
+// Snapshots are stored within the same database, each as a single copy of the
+// whole world.
+type BoltStore struct {
+	Path string
+	Keep int
+
+	db *bolt.DB
+
+	// saved holds the encoding of every value as it is in the database, by
+	// bucket and then key, so that unchanged values can be skipped.
+	mutex sync.Mutex
+	saved map[string]map[string][]byte
+}
+
+// OpenBoltStore opens the database at the path, creating it if it does not
+// exist, keeping up to the specified number of snapshots.
+func OpenBoltStore(path string, keep int) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Errorf("could not open %s: %s", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{worldBucket, objectsBucket, itemsBucket, snapshotsBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.New(err)
+	}
+
+	return &BoltStore{Path: path, Keep: keep, db: db}, nil
+}
+
+// Load reads the world from the database. An error is returned if no world
+// has been saved.
+func (s *BoltStore) Load() (entities.World, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		data, err = assemble(tx)
+		return err
+	})
+	if err != nil {
+		return entities.World{}, err
+	}
+
+	world, err := Decode(data)
+	if err != nil {
+		return entities.World{}, err
+	}
+
+	// Whatever the database holds, it is out of date once migrated, so the
+	// next save compares against the migrated world.
+	s.saved, err = encode(world)
+	if err != nil {
+		return entities.World{}, err
+	}
+
+	return world, nil
+}
+
+// Save writes every object and item which has changed since the world was
+// last loaded or saved, and removes those which no longer exist, in a single
+// transaction.
+func (s *BoltStore) Save(world entities.World) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	world.Version = Version
+	current, err := encode(world)
+	if err != nil {
+		return err
+	}
+
+	// Without knowing what the database holds, everything is written.
+	saved := s.saved
+	if saved == nil {
+		saved = make(map[string]map[string][]byte)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for bucket, values := range current {
+			b := tx.Bucket([]byte(bucket))
+
+			if s.saved == nil {
+				err := empty(b)
+				if err != nil {
+					return err
+				}
+			}
+
+			for key, value := range values {
+				if bytes.Equal(saved[bucket][key], value) {
+					continue
+				}
+				err := b.Put([]byte(key), value)
+				if err != nil {
+					return err
+				}
+			}
+			for key := range saved[bucket] {
+				if _, ok := values[key]; ok {
+					continue
+				}
+				err := b.Delete([]byte(key))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// The database is unchanged, but what it holds may now be unknown.
+		s.saved = nil
+		return errors.New(err)
+	}
+
+	s.saved = current
+	return nil
+}
+
+// Snapshot saves the world as the next snapshot.
+func (s *BoltStore) Snapshot(world entities.World) (Snapshot, error) {
+	world.Version = Version
+	data, err := json.Marshal(world)
+	if err != nil {
+		return Snapshot{}, errors.New(err)
+	}
+
+	var snapshot Snapshot
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		snapshot, err = s.snapshot(tx, data)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, errors.New(err)
+	}
+
+	return snapshot, nil
+}
+
+// List returns every snapshot, oldest first.
+func (s *BoltStore) List() ([]Snapshot, error) {
+	snapshots := make([]Snapshot, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).ForEach(func(k, v []byte) error {
+			snapshot, _, err := s.decodeSnapshot(k, v)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, snapshot)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	return snapshots, nil
+}
+
+// Rollback replaces the world with the numbered snapshot, first taking a
+// snapshot of the world being replaced.
+func (s *BoltStore) Rollback(n int) (Snapshot, error) {
+	var snapshot Snapshot
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		k := snapshotKey(n)
+		v := tx.Bucket(snapshotsBucket).Get(k)
+		if v == nil {
+			return errors.Errorf("snapshot %d does not exist in %s", n, s.Path)
+		}
+
+		var err error
+		snapshot, data, err = s.decodeSnapshot(k, v)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, errors.Wrap(err, 0)
+	}
+
+	// Make sure the snapshot is a valid save before replacing anything.
+	world, err := Decode(data)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		current, err := assemble(tx)
+		if err != nil {
+			// With no world saved, there is nothing to keep.
+			return nil
+		}
+
+		_, err = s.snapshot(tx, current)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, errors.New(err)
+	}
+
+	s.mutex.Lock()
+	s.saved = nil
+	s.mutex.Unlock()
+
+	err = s.Save(world)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	err := s.db.Close()
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// snapshotRecord is a snapshot as it is stored in the database.
+type snapshotRecord struct {
+	Time  time.Time       `json:"time"`
+	World json.RawMessage `json:"world"`
+}
+
+// snapshot stores the encoded world as the next snapshot, then removes the
+// oldest snapshots beyond those kept.
+func (s *BoltStore) snapshot(tx *bolt.Tx, data []byte) (Snapshot, error) {
+	b := tx.Bucket(snapshotsBucket)
+
+	n := 1
+	if k, _ := b.Cursor().Last(); k != nil {
+		n = int(binary.BigEndian.Uint64(k)) + 1
+	}
+
+	now := time.Now()
+	record, err := json.Marshal(snapshotRecord{Time: now, World: data})
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	err = b.Put(snapshotKey(n), record)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if s.Keep > 0 {
+		old := make([][]byte, 0)
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && int(binary.BigEndian.Uint64(k)) <= n-s.Keep; k, _ = c.Next() {
+			old = append(old, append([]byte(nil), k...))
+		}
+		for _, k := range old {
+			err = b.Delete(k)
+			if err != nil {
+				return Snapshot{}, err
+			}
+		}
+	}
+
+	return Snapshot{Number: n, Path: s.Path, ModTime: now}, nil
+}
+
+// decodeSnapshot returns the snapshot stored under the key, along with the
+// world it holds.
+func (s *BoltStore) decodeSnapshot(k, v []byte) (Snapshot, []byte, error) {
+	record := snapshotRecord{}
+	err := json.Unmarshal(v, &record)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+
+	return Snapshot{
+		Number:  int(binary.BigEndian.Uint64(k)),
+		Path:    s.Path,
+		ModTime: record.Time,
+	}, record.World, nil
+}
+
+func snapshotKey(n int) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(n))
+	return k
+}
+
+// encode returns the encoding of every value to be stored for the world, by
+// bucket and then key.
+func encode(world entities.World) (map[string]map[string][]byte, error) {
+	encoded := map[string]map[string][]byte{
+		string(worldBucket):   make(map[string][]byte),
+		string(objectsBucket): make(map[string][]byte),
+		string(itemsBucket):   make(map[string][]byte),
+	}
+
+	data, err := json.Marshal(world)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+	for _, name := range worldFields {
+		if value, ok := fields[name]; ok {
+			encoded[string(worldBucket)][name] = value
+		}
+	}
+
+	order := make([]uuid.UUID, 0, world.Objects.Len())
+	for _, e := range world.Objects.Entities() {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		encoded[string(objectsBucket)][string(e.ID.Bytes())] = data
+		order = append(order, e.ID)
+	}
+
+	encoded[string(worldBucket)][string(orderKey)], err = json.Marshal(order)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	for _, i := range world.Items.Items() {
+		data, err := json.Marshal(i)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		encoded[string(itemsBucket)][string(i.ID.Bytes())] = data
+	}
+
+	return encoded, nil
+}
+
+// assemble puts the saved world back together as a single JSON document, in
+// the same form as a JSON save.
+func assemble(tx *bolt.Tx) ([]byte, error) {
+	w := tx.Bucket(worldBucket)
+	if k, _ := w.Cursor().First(); k == nil {
+		return nil, errors.New("no world has been saved")
+	}
+
+	doc := make(map[string]json.RawMessage)
+	for _, name := range worldFields {
+		if value := w.Get([]byte(name)); value != nil {
+			doc[name] = append(json.RawMessage(nil), value...)
+		}
+	}
+
+	order := make([]uuid.UUID, 0)
+	if value := w.Get(orderKey); value != nil {
+		err := json.Unmarshal(value, &order)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+	}
+
+	objects := tx.Bucket(objectsBucket)
+	list := make([]json.RawMessage, 0, len(order))
+	for _, id := range order {
+		if value := objects.Get(id.Bytes()); value != nil {
+			list = append(list, append(json.RawMessage(nil), value...))
+		}
+	}
+
+	var err error
+	doc["objects"], err = json.Marshal(list)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	list = make([]json.RawMessage, 0)
+	err = tx.Bucket(itemsBucket).ForEach(func(k, v []byte) error {
+		list = append(list, append(json.RawMessage(nil), v...))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	doc["items"], err = json.Marshal(list)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	return data, nil
+}
+
+// empty removes every key from the bucket.
+func empty(b *bolt.Bucket) error {
+	keys := make([][]byte, 0)
+	err := b.ForEach(func(k, v []byte) error {
+		keys = append(keys, append([]byte(nil), k...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		err = b.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
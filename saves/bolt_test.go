@@ -0,0 +1,239 @@
+package saves
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/items"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// sample returns a world at the tick, holding n entities along the top row and
+// an item lying beneath the first.
+func sample(tick uint64, n int) entities.World {
+	world := entities.MakeWorld()
+	world.Tick = tick
+
+	for x := 0; x < n; x++ {
+		e := objects.New()
+		e.Set(objects.Position{X: x, Y: 0})
+		e.Set(objects.Health{Current: 10, Max: 10})
+		world.Objects = world.Objects.Append(*e)
+	}
+
+	world.Items.Insert(items.Item{ID: uuid.Must(uuid.NewV4()), Position: &objects.Position{}})
+
+	return world
+}
+
+// openTemp opens a store for a new database in a temporary directory, keeping
+// the number of snapshots. The returned function closes and removes it.
+func openTemp(t *testing.T, keep int) (*BoltStore, func()) {
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenBoltStore(filepath.Join(dir, "game"+BoltExt), keep)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// same fails the test if the worlds differ.
+func same(t *testing.T, name string, world, expected entities.World) {
+	diffs, err := entities.Differences(world, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, diff := range diffs {
+		t.Errorf("%s: %s", name, diff)
+	}
+}
+
+func TestBoltStoreSave(t *testing.T) {
+	s, done := openTemp(t, 0)
+	defer done()
+
+	get := func(bucket, key []byte) []byte {
+		var value []byte
+		s.db.View(func(tx *bolt.Tx) error {
+			value = append([]byte(nil), tx.Bucket(bucket).Get(key)...)
+			return nil
+		})
+		if len(value) == 0 {
+			return nil
+		}
+		return value
+	}
+	put := func(bucket, key, value []byte) {
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucket).Put(key, value)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	world := sample(1, 3)
+	l := world.Objects.Entities()
+	if err := s.Save(world); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values which have not changed are not written again, so marking one in
+	// the database shows whether it was.
+	marker := []byte(`"marked"`)
+	put(objectsBucket, l[0].ID.Bytes(), marker)
+
+	moved := l[1]
+	moved.Set(objects.Position{X: 9, Y: 9})
+	world.Objects = world.Objects.MustUpdate(moved)
+	world.Objects = world.Objects.MustRemove(l[2])
+	world.Tick = 2
+	if err := s.Save(world); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := get(objectsBucket, l[0].ID.Bytes()); !reflect.DeepEqual(value, marker) {
+		t.Errorf("unchanged entity was written again as %s", value)
+	}
+	e := objects.Entity{}
+	if err := json.Unmarshal(get(objectsBucket, l[1].ID.Bytes()), &e); err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := e.Position(); pos != (objects.Position{X: 9, Y: 9}) {
+		t.Errorf("moved entity was saved at %+v, want 9, 9", pos)
+	}
+	if value := get(objectsBucket, l[2].ID.Bytes()); value != nil {
+		t.Errorf("removed entity is still saved as %s", value)
+	}
+	if value := get(worldBucket, []byte("tick")); string(value) != "2" {
+		t.Errorf("tick was saved as %s, want 2", value)
+	}
+
+	// Without knowing what the database holds, everything is written again
+	// and anything else removed.
+	put(objectsBucket, []byte("stale"), marker)
+	s.saved = nil
+	if err := s.Save(world); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := get(objectsBucket, l[0].ID.Bytes()); reflect.DeepEqual(value, marker) {
+		t.Error("unchanged entity was not written again after a full save")
+	}
+	if value := get(objectsBucket, []byte("stale")); value != nil {
+		t.Errorf("stale key is still saved as %s", value)
+	}
+
+	loaded, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "loaded", loaded, world)
+}
+
+func TestBoltStoreKeep(t *testing.T) {
+	tests := []struct {
+		keep int
+		want []int
+	}{
+		{0, []int{1, 2, 3, 4}},
+		{1, []int{4}},
+		{2, []int{3, 4}},
+		{5, []int{1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		s, done := openTemp(t, test.keep)
+
+		for tick := uint64(1); tick <= 4; tick++ {
+			if _, err := s.Snapshot(sample(tick, 1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		list, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]int, len(list))
+		for i, snapshot := range list {
+			got[i] = snapshot.Number
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("keeping %d: got snapshots %v, want %v", test.keep, got, test.want)
+		}
+
+		done()
+	}
+}
+
+func TestBoltStoreRollback(t *testing.T) {
+	s, done := openTemp(t, 0)
+	defer done()
+
+	first := sample(1, 2)
+	if err := s.Save(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Snapshot(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := sample(2, 3)
+	if err := s.Save(second); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := s.Rollback(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Number != 1 {
+		t.Errorf("rolled back to snapshot %d, want 1", snapshot.Number)
+	}
+
+	loaded, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "rolled back", loaded, first)
+
+	// The world rolled back from was itself taken as a snapshot, so the
+	// rollback can be undone.
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Number != 2 {
+		t.Fatalf("got snapshots %+v, want 1 and 2", list)
+	}
+	if _, err := s.Rollback(2); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "undone", loaded, second)
+
+	if _, err := s.Rollback(7); err == nil {
+		t.Error("rolled back to a snapshot which does not exist")
+	}
+}
@@ -0,0 +1,266 @@
+package saves
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/utils"
+)
+
+// Version is the version of the save format written by Save. Saves without a
+// version are version 0.
+const Version = 5
+
+// document is a save as its top-level fields, so that migrations can rewrite
+// parts of it without needing to understand the rest.
+type document map[string]json.RawMessage
+
+// migration upgrades a save from the previous version to its version.
+type migration struct {
+	Version     int
+	Description string
+	Migrate     func(doc document) error
+}
+
+// migrations are applied in order, to bring old saves up to date. A change to
+// the save format needs a new migration at the end, and Version bumped to
+// match it.
+var migrations = []migration{
+	{1, "entities are made of components", migrateLegacyEntities},
+	{2, "item damage types are saved as damage_type", migrateDamageTypes},
+	{3, "the luck attribute is saved as luck", migrateLuck},
+	{4, "health without a maximum is at its maximum", migrateMaxHealth},
+	{5, "entities which cannot act are static", migrateStatic},
+}
+
+// Migrate upgrades the save to the current version, returning it along with
+// the version it was saved in. Saves which are already up to date are
+// returned unchanged. An error is returned for saves from a newer version.
+func Migrate(data []byte) ([]byte, int, error) {
+	doc := document{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, 0, errors.New(err)
+	}
+
+	from := 0
+	if raw, ok := doc["version"]; ok {
+		err = json.Unmarshal(raw, &from)
+		if err != nil {
+			return nil, 0, errors.Errorf("invalid save version: %s", raw)
+		}
+	}
+
+	if from > Version {
+		return nil, from, errors.Errorf("save version %d is newer than the supported version %d", from, Version)
+	}
+	if from == Version {
+		return data, from, nil
+	}
+
+	for _, m := range migrations {
+		if m.Version <= from {
+			continue
+		}
+
+		err = m.Migrate(doc)
+		if err != nil {
+			return nil, from, errors.Errorf("migrating save to version %d (%s): %s", m.Version, m.Description, err)
+		}
+	}
+
+	doc["version"], err = json.Marshal(Version)
+	if err != nil {
+		return nil, from, errors.New(err)
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, from, errors.New(err)
+	}
+
+	return migrated, from, nil
+}
+
+// list calls the function with the fields of each object in the list stored
+// under the key, replacing each object with the fields it returns.
+func (doc document) list(key string, f func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error)) error {
+	raw, ok := doc[key]
+	if !ok || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil
+	}
+
+	list := make([]map[string]json.RawMessage, 0)
+	err := json.Unmarshal(raw, &list)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	for i, fields := range list {
+		list[i], err = f(fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	doc[key], err = json.Marshal(list)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// rename moves the field within the object stored in the fields under the
+// key, if it is set.
+func rename(fields map[string]json.RawMessage, key, from, to string) error {
+	raw, ok := fields[key]
+	if !ok || raw[0] != '{' {
+		return nil
+	}
+
+	object := make(map[string]json.RawMessage)
+	err := json.Unmarshal(raw, &object)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	value, ok := object[from]
+	if !ok {
+		return nil
+	}
+	if _, ok := object[to]; !ok {
+		object[to] = value
+	}
+	delete(object, from)
+
+	fields[key], err = json.Marshal(object)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// migrateLegacyEntities converts entities saved before entities were made of
+// components, such as those with a ui block and a bare health number.
+func migrateLegacyEntities(doc document) error {
+	return doc.list("objects", objects.MigrateLegacyEntity)
+}
+
+// migrateDamageTypes renames the type of item damage to damage_type, which
+// was otherwise ignored, and converts damage saved as a die range, roll
+// amount and modifier into a dice expression.
+func migrateDamageTypes(doc document) error {
+	return doc.list("items", func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		err := rename(fields, "damage", "type", "damage_type")
+		if err != nil {
+			return nil, err
+		}
+
+		raw, ok := fields["damage"]
+		if !ok || raw[0] != '{' {
+			return fields, nil
+		}
+
+		damage := make(map[string]json.RawMessage)
+		err = json.Unmarshal(raw, &damage)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		if _, ok := damage["dice"]; ok {
+			return fields, nil
+		}
+
+		legacy := struct {
+			DieRange   int `json:"die_range"`
+			Modifier   int `json:"modifier"`
+			RollAmount int `json:"roll_amount"`
+		}{}
+		err = json.Unmarshal(raw, &legacy)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+
+		dice := utils.Dice{
+			Count:    legacy.RollAmount,
+			Sides:    legacy.DieRange,
+			Modifier: legacy.Modifier,
+		}
+		damage["dice"], err = json.Marshal(dice)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		delete(damage, "die_range")
+		delete(damage, "modifier")
+		delete(damage, "roll_amount")
+
+		fields["damage"], err = json.Marshal(damage)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+
+		return fields, nil
+	})
+}
+
+// migrateLuck renames the luck attribute, which was saved as attribute.
+func migrateLuck(doc document) error {
+	return doc.list("objects", func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		return fields, rename(fields, objects.AttributesComponent, "attribute", "luck")
+	})
+}
+
+// migrateMaxHealth gives health saved without a maximum, as legacy health
+// was, a maximum of its current health, so that it can be restored once lost.
+func migrateMaxHealth(doc document) error {
+	return doc.list("objects", func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		raw, ok := fields[objects.HealthComponent]
+		if !ok || raw[0] != '{' {
+			return fields, nil
+		}
+
+		health := make(map[string]json.RawMessage)
+		err := json.Unmarshal(raw, &health)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+
+		max, ok := health["max"]
+		if ok && !bytes.Equal(bytes.TrimSpace(max), []byte("0")) {
+			return fields, nil
+		}
+
+		current, ok := health["current"]
+		if !ok {
+			return fields, nil
+		}
+		health["max"] = current
+
+		fields[objects.HealthComponent], err = json.Marshal(health)
+		if err != nil {
+			return nil, errors.New(err)
+		}
+
+		return fields, nil
+	})
+}
+
+// migrateStatic marks entities as static which were previously treated as
+// such: those with a position, but neither energy nor a timer with which to
+// act.
+func migrateStatic(doc document) error {
+	return doc.list("objects", func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		_, positioned := fields[objects.PositionComponent]
+		_, hasEnergy := fields[objects.EnergyComponent]
+		_, hasTimer := fields[objects.TimerComponent]
+		if positioned && !hasEnergy && !hasTimer {
+			fields[objects.StaticComponent] = json.RawMessage("{}")
+		}
+
+		return fields, nil
+	})
+}
@@ -0,0 +1,131 @@
+// Package saves reads and writes saved worlds. Saves are written atomically,
+// so that a crash part way through saving cannot leave a truncated save
+// behind, and can be kept as a rotating set of numbered snapshots.
+package saves
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/utils"
+)
+
+// DefaultPath is the file the world is loaded from and saved to, unless
+// configured otherwise.
+const DefaultPath = "game.save"
+
+// Load reads the saved world from the file at the path. Saves from older
+// versions are migrated as they are read, and any fields which do not belong
+// in the save are reported as a ValidationError.
+func Load(path string) (entities.World, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return entities.World{}, errors.New(err)
+	}
+
+	return Decode(data)
+}
+
+// Decode migrates, validates and unmarshals the saved world. Worlds saved
+// without an RNG are seeded with zero, so that every world has one.
+func Decode(data []byte) (entities.World, error) {
+	data, _, err := Migrate(data)
+	if err != nil {
+		return entities.World{}, err
+	}
+
+	err = Validate(data)
+	if err != nil {
+		return entities.World{}, err
+	}
+
+	world := entities.MakeWorld()
+	err = json.Unmarshal(data, &world)
+	if err != nil {
+		return entities.World{}, errors.New(err)
+	}
+	if world.RNG == nil {
+		world.RNG = utils.NewSource(0)
+	}
+
+	return world, nil
+}
+
+// Save writes the world to the file at the path, in the current version of
+// the save format.
+func Save(path string, world entities.World) error {
+	world.Version = Version
+
+	data, err := json.MarshalIndent(world, "", "    ")
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return WriteFile(path, append(data, '\n'), 0644)
+}
+
+// WriteFile atomically replaces the file at the path with the data. The data
+// is written and synced to a temporary file in the same directory, which is
+// then renamed over the original. Readers see either the old file or the new
+// one, never a partial write. The file is given the permissions perm.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.New(err)
+	}
+
+	// Removing the temporary file fails harmlessly once it has been renamed.
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return errors.New(err)
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return errors.New(err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return errors.New(err)
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory to disk, so that a rename within it survives
+// a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.New(err)
+	}
+	defer d.Close()
+
+	// Not every platform supports syncing directories; the rename has still
+	// happened, it may just not be durable yet.
+	d.Sync()
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package saves_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/entities/items"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/saves"
+)
+
+// The legacy save is the save shipped before saves were versioned, exactly as
+// the server wrote it, so it is migrated from version 0.
+func TestMigrateLegacySave(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/v0.save")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, from, err := saves.Migrate(data)
+	if err != nil {
+		t.Fatalf("migrating: %s", err)
+	}
+	if from != 0 {
+		t.Errorf("migrated from version %d, want 0", from)
+	}
+
+	err = saves.Validate(migrated)
+	if err != nil {
+		t.Fatalf("migrated save is invalid: %s", err)
+	}
+
+	again, from, err := saves.Migrate(migrated)
+	if err != nil || from != saves.Version || string(again) != string(migrated) {
+		t.Errorf("migrating the migrated save again changed it, from version %d: %v", from, err)
+	}
+
+	world, err := saves.Decode(data)
+	if err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	tests := []struct {
+		id     string
+		health objects.Health
+		static bool
+		glyph  rune
+	}{
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", objects.Health{Current: 999999, Max: 999999}, true, '#'},
+		{"6ba7b810-9dad-11d1-80b4-12c04fd430d4", objects.Health{Current: 999999, Max: 999999}, true, '+'},
+		{"dc71d346-ac20-4f1b-be03-f51bd4eddff3", objects.Health{Current: 5, Max: 5}, false, 'h'},
+		{"b5d9c244-b17d-4845-bd56-07c710536008", objects.Health{Current: 999999, Max: 999999}, false, '@'},
+	}
+	for _, test := range tests {
+		e, ok := world.Objects.FromID(uuid.FromStringOrNil(test.id))
+		if !ok {
+			t.Errorf("entity %s is missing", test.id)
+			continue
+		}
+
+		if health, _ := e.Health(); health != test.health {
+			t.Errorf("entity %s has health %+v, want %+v", test.id, health, test.health)
+		}
+		if e.Static() != test.static {
+			t.Errorf("entity %s static = %t, want %t", test.id, e.Static(), test.static)
+		}
+		if renderable, _ := e.Renderable(); renderable.Character != test.glyph {
+			t.Errorf("entity %s is drawn as %q, want %q", test.id, renderable.Character, test.glyph)
+		}
+	}
+
+	sword, ok := world.Items.FromID(uuid.FromStringOrNil("a079f188-cd0c-4820-b198-6167cba43d86"))
+	if !ok {
+		t.Fatal("sword is missing")
+	}
+	if sword.Damage.Dice.String() != "2d4+1" || sword.Damage.Type != items.MeleeDamage {
+		t.Errorf("sword damage = %+v, want 2d4+1 melee", sword.Damage)
+	}
+
+	chest, ok := world.Items.FromID(uuid.FromStringOrNil("2e46acef-4bc4-4dc3-8858-8cc03ec493d4"))
+	if !ok {
+		t.Fatal("chest armor is missing")
+	}
+	if chest.Armor != (items.Armor{MeleeReduction: 3, RangeReduction: 1}) {
+		t.Errorf("chest armor = %+v, want 3 melee and 1 range reduction", chest.Armor)
+	}
+}
+
+// Legacy timers hold the wall-clock time at which an entity may next act,
+// which is meaningless as a tick, so they are reset to be ready.
+func TestMigrateLegacyTimers(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/v0.save")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{0, 3} {
+		save := make(map[string]interface{})
+		err = json.Unmarshal(data, &save)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		object := save["objects"].([]interface{})[i].(map[string]interface{})
+		object["timer"] = map[string]interface{}{"next_timestamp": 1539500000}
+		timed, err := json.Marshal(save)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		world, err := saves.Decode(timed)
+		if err != nil {
+			t.Errorf("decoding with a timer on objects[%d]: %s", i, err)
+			continue
+		}
+
+		e, _ := world.Objects.FromID(uuid.FromStringOrNil(object["id"].(string)))
+		if timer, ok := e.Timer(); !ok || timer.NextTick != 0 {
+			t.Errorf("objects[%d] has timer %+v, want a ready timer", i, timer)
+		}
+	}
+}
+
+// The shipped save is kept at the current version, as written by save migrate.
+func TestGameSave(t *testing.T) {
+	data, err := ioutil.ReadFile("../game.save")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, from, err := saves.Migrate(data)
+	if err != nil {
+		t.Fatalf("migrating: %s", err)
+	}
+	if from != saves.Version || string(migrated) != string(data) {
+		t.Errorf("game.save is at version %d, want %d", from, saves.Version)
+	}
+
+	world, err := saves.Decode(data)
+	if err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	if world.RNG == nil || world.RNG.Seed != 20181014 {
+		t.Errorf("rng = %+v, want seed 20181014", world.RNG)
+	}
+
+	tests := []struct {
+		id     string
+		static bool
+		glyph  rune
+	}{
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", true, '#'},
+		{"6ba7b810-9dad-11d1-80b4-12c04fd430d4", true, '+'},
+		{"dc71d346-ac20-4f1b-be03-f51bd4eddff3", false, 'h'},
+		{"b5d9c244-b17d-4845-bd56-07c710536008", false, '@'},
+		{"6da0648c-5fda-4d7a-a086-2f38b6e1fba0", false, '@'},
+	}
+	for _, test := range tests {
+		e, ok := world.Objects.FromID(uuid.FromStringOrNil(test.id))
+		if !ok {
+			t.Errorf("entity %s is missing", test.id)
+			continue
+		}
+
+		if e.Static() != test.static {
+			t.Errorf("entity %s static = %t, want %t", test.id, e.Static(), test.static)
+		}
+		if renderable, _ := e.Renderable(); renderable.Character != test.glyph {
+			t.Errorf("entity %s is drawn as %q, want %q", test.id, renderable.Character, test.glyph)
+		}
+	}
+
+	dagger, ok := world.Items.FromID(uuid.FromStringOrNil("f3a3c1a2-5b7e-4d8e-9c61-0e8f6b2d7a41"))
+	if !ok {
+		t.Fatal("dagger is missing")
+	}
+	if !dagger.OnGround() || dagger.Damage.Dice.String() != "1d6" {
+		t.Errorf("dagger = %+v, want 1d6 damage lying on the ground", dagger)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	id := `"id":"b5d9c244-b17d-4845-bd56-07c710536008"`
+
+	tests := []struct {
+		save     string
+		problems []string
+	}{
+		{
+			fmt.Sprintf(`{"tick":3,"objects":[{%s,"position":{"x":1,"y":2}}],"items":[]}`, id),
+			nil,
+		},
+		{
+			`{"tick":3,"rgn":{"seed":1}}`,
+			[]string{`rgn: unknown field "rgn"; did you mean "rng"?`},
+		},
+		{
+			fmt.Sprintf(`{"objects":[{%s,"positon":{"x":1,"y":2}}]}`, id),
+			[]string{`objects[0].positon: unknown field "positon"; did you mean "position"?`},
+		},
+		{
+			fmt.Sprintf(`{"objects":[{%s,"health":{"current":1,"maximum":2}}]}`, id),
+			[]string{`objects[0].health.maximum: unknown field "maximum"`},
+		},
+		{
+			fmt.Sprintf(`{"objects":[{%s,"ui":{}},{%s,"static":{},"spells":[]}]}`, id, id),
+			[]string{
+				`objects[0].ui: unknown field "ui"; did you mean "ai"?`,
+				`objects[1].spells: unknown field "spells"`,
+			},
+		},
+		{
+			`{"items":[{"id":"a079f188-cd0c-4820-b198-6167cba43d86","damage":{"dice":"1d6","type":0}}]}`,
+			[]string{`items[0].damage.type: unknown field "type"`},
+		},
+	}
+
+	for _, test := range tests {
+		err := saves.Validate([]byte(test.save))
+
+		var problems []string
+		if err != nil {
+			validationErr, ok := err.(*errors.Error).Err.(saves.ValidationError)
+			if !ok {
+				t.Errorf("validating %s: %s", test.save, err)
+				continue
+			}
+			problems = validationErr.Problems
+		}
+
+		if !reflect.DeepEqual(problems, test.problems) {
+			t.Errorf("validating %s:\ngot  %q\nwant %q", test.save, problems, test.problems)
+		}
+	}
+}
+
+// Saves written by a newer version cannot be understood, so are refused.
+func TestMigrateNewerVersion(t *testing.T) {
+	data, err := json.Marshal(map[string]int{"version": saves.Version + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, from, err := saves.Migrate(data)
+	if err == nil {
+		t.Errorf("migrating a save from version %d succeeded", from)
+	}
+}
@@ -0,0 +1,187 @@
+package saves
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/entities"
+)
+
+// Default snapshot policy, unless configured otherwise.
+const (
+	DefaultSnapshotsPath = "snapshots"
+	DefaultSnapshotsKept = 10
+)
+
+const snapshotExt = ".save"
+
+// Snapshot is a numbered copy of the world, saved at some point in the past.
+// Higher numbers are more recent.
+type Snapshot struct {
+	Number  int
+	Path    string
+	ModTime time.Time
+}
+
+// Snapshots manages a rotating set of numbered snapshots within a directory.
+// Each snapshot is numbered one higher than the last, and once there are more
+// than Keep snapshots the oldest are removed. Snapshots are never removed if
+// Keep is zero.
+type Snapshots struct {
+	Dir  string
+	Keep int
+}
+
+// List returns every snapshot in the directory, oldest first. A missing
+// directory has no snapshots.
+func (s Snapshots) List() ([]Snapshot, error) {
+	infos, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	snapshots := make([]Snapshot, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, snapshotExt) {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSuffix(name, snapshotExt))
+		if err != nil || n <= 0 {
+			continue
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			Number:  n,
+			Path:    filepath.Join(s.Dir, name),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Number < snapshots[j].Number
+	})
+
+	return snapshots, nil
+}
+
+// Find returns the snapshot with the number.
+func (s Snapshots) Find(n int) (Snapshot, error) {
+	snapshots, err := s.List()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Number == n {
+			return snapshot, nil
+		}
+	}
+
+	return Snapshot{}, errors.Errorf("snapshot %d does not exist in %s", n, s.Dir)
+}
+
+// Take saves the world as the next snapshot, then removes the oldest
+// snapshots beyond those kept.
+func (s Snapshots) Take(world entities.World) (Snapshot, error) {
+	return s.take(func(path string) error {
+		return Save(path, world)
+	})
+}
+
+// TakeFile copies the save at the path as the next snapshot, then removes the
+// oldest snapshots beyond those kept.
+func (s Snapshots) TakeFile(path string) (Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, errors.New(err)
+	}
+
+	return s.take(func(path string) error {
+		return WriteFile(path, data, 0644)
+	})
+}
+
+func (s Snapshots) take(write func(path string) error) (Snapshot, error) {
+	err := os.MkdirAll(s.Dir, 0755)
+	if err != nil {
+		return Snapshot{}, errors.New(err)
+	}
+
+	snapshots, err := s.List()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	n := 1
+	if len(snapshots) > 0 {
+		n = snapshots[len(snapshots)-1].Number + 1
+	}
+
+	path := filepath.Join(s.Dir, fmt.Sprintf("%06d%s", n, snapshotExt))
+	err = write(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{Number: n, Path: path, ModTime: time.Now()}
+	snapshots = append(snapshots, snapshot)
+
+	if s.Keep > 0 && len(snapshots) > s.Keep {
+		for _, old := range snapshots[:len(snapshots)-s.Keep] {
+			err = os.Remove(old.Path)
+			if err != nil && !os.IsNotExist(err) {
+				return snapshot, errors.New(err)
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Rollback replaces the save at the path with the numbered snapshot. The save
+// being replaced is first taken as a snapshot of its own, so that the rollback
+// can itself be undone.
+func (s Snapshots) Rollback(path string, n int) (Snapshot, error) {
+	snapshot, err := s.Find(n)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	// Make sure the snapshot is a valid save before replacing anything.
+	_, err = Load(snapshot.Path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	data, err := ioutil.ReadFile(snapshot.Path)
+	if err != nil {
+		return Snapshot{}, errors.New(err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		_, err = s.TakeFile(path)
+		if err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	err = WriteFile(path, data, 0644)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
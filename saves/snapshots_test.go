@@ -0,0 +1,150 @@
+package saves_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/saves"
+)
+
+// at returns a world at the tick, holding a single entity.
+func at(tick uint64) entities.World {
+	world := entities.MakeWorld()
+	world.Tick = tick
+
+	e := objects.New()
+	e.Set(objects.Position{X: int(tick), Y: 0})
+	world.Objects = world.Objects.Append(*e)
+
+	return world
+}
+
+// numbers returns the numbers of the snapshots in the directory.
+func numbers(t *testing.T, snapshots saves.Snapshots) []int {
+	list, err := snapshots.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]int, len(list))
+	for i, snapshot := range list {
+		got[i] = snapshot.Number
+	}
+	return got
+}
+
+func TestSnapshotsKeep(t *testing.T) {
+	tests := []struct {
+		keep int
+		want []int
+	}{
+		{0, []int{1, 2, 3, 4}},
+		{1, []int{4}},
+		{2, []int{3, 4}},
+		{5, []int{1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "snapshots")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshots := saves.Snapshots{Dir: dir, Keep: test.keep}
+		for tick := uint64(1); tick <= 4; tick++ {
+			if _, err := snapshots.Take(at(tick)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := numbers(t, snapshots); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("keeping %d: got snapshots %v, want %v", test.keep, got, test.want)
+		}
+
+		// Pruned snapshots are removed from the directory, not just the list.
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != len(test.want) {
+			t.Errorf("keeping %d: %d files are left, want %d", test.keep, len(files), len(test.want))
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSnapshotsRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "game.save")
+	snapshots := saves.Snapshots{Dir: filepath.Join(dir, "snapshots")}
+
+	first := at(1)
+	for _, world := range []entities.World{first, at(2)} {
+		if _, err := snapshots.Take(world); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rolling back without a save takes no snapshot of it.
+	if _, err := snapshots.Rollback(path, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := numbers(t, snapshots); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("rolling back without a save left snapshots %v, want [1 2]", got)
+	}
+
+	current := at(3)
+	if err := saves.Save(path, current); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := snapshots.Rollback(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Number != 1 {
+		t.Errorf("rolled back to snapshot %d, want 1", snapshot.Number)
+	}
+
+	compare := func(name, path string, expected entities.World) {
+		world, err := saves.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs, err := entities.Differences(world, expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, diff := range diffs {
+			t.Errorf("%s: %s", name, diff)
+		}
+	}
+	compare("rolled back", path, first)
+
+	// The save rolled back from was itself taken as a snapshot, so the
+	// rollback can be undone.
+	if got := numbers(t, snapshots); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("got snapshots %v, want [1 2 3]", got)
+	}
+	taken, err := snapshots.Find(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare("snapshot of the replaced save", taken.Path, current)
+
+	if _, err := snapshots.Rollback(path, 7); err == nil {
+		t.Error("rolled back to a snapshot which does not exist")
+	}
+	compare("after a failed rollback", path, first)
+}
@@ -0,0 +1,83 @@
+package saves
+
+import (
+	"path/filepath"
+
+	"github.com/clagraff/pitch/entities"
+)
+
+// WorldStore persists the world, along with a rotating set of numbered
+// snapshots of it.
+type WorldStore interface {
+	// Load returns the saved world, migrated to the current version.
+	Load() (entities.World, error)
+	// Save replaces the saved world with the world.
+	Save(world entities.World) error
+	// Snapshot saves the world as the next snapshot.
+	Snapshot(world entities.World) (Snapshot, error)
+	// List returns every snapshot, oldest first.
+	List() ([]Snapshot, error)
+	// Rollback replaces the saved world with the numbered snapshot, first
+	// taking a snapshot of the world being replaced.
+	Rollback(n int) (Snapshot, error)
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// Open returns the store for the save at the path, keeping up to the
+// specified number of snapshots. Saves ending in .db are kept in an embedded
+// database; anything else is a JSON file, with its snapshots kept in the
+// snapshots directory alongside it.
+func Open(path string, keep int) (WorldStore, error) {
+	if filepath.Ext(path) == BoltExt {
+		return OpenBoltStore(path, keep)
+	}
+
+	return NewJSONStore(path, Snapshots{
+		Dir:  filepath.Join(filepath.Dir(path), DefaultSnapshotsPath),
+		Keep: keep,
+	}), nil
+}
+
+// JSONStore saves the whole world as a single JSON file, with its snapshots
+// kept as copies of it.
+type JSONStore struct {
+	Path      string
+	Snapshots Snapshots
+}
+
+// NewJSONStore returns a store which saves the world to the file at the path.
+func NewJSONStore(path string, snapshots Snapshots) *JSONStore {
+	return &JSONStore{Path: path, Snapshots: snapshots}
+}
+
+// Load reads the world from the file.
+func (s *JSONStore) Load() (entities.World, error) {
+	return Load(s.Path)
+}
+
+// Save atomically replaces the file with the world.
+func (s *JSONStore) Save(world entities.World) error {
+	return Save(s.Path, world)
+}
+
+// Snapshot saves the world as the next snapshot.
+func (s *JSONStore) Snapshot(world entities.World) (Snapshot, error) {
+	return s.Snapshots.Take(world)
+}
+
+// List returns every snapshot, oldest first.
+func (s *JSONStore) List() ([]Snapshot, error) {
+	return s.Snapshots.List()
+}
+
+// Rollback replaces the file with the numbered snapshot.
+func (s *JSONStore) Rollback(n int) (Snapshot, error) {
+	return s.Snapshots.Rollback(s.Path, n)
+}
+
+// Close does nothing, as the file is only open while it is being read or
+// written.
+func (s *JSONStore) Close() error {
+	return nil
+}
@@ -0,0 +1,275 @@
+package saves
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/items"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// ValidationError lists every field in a save which does not belong in it.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns every problem, one per line.
+func (err ValidationError) Error() string {
+	return "invalid save:\n\t" + strings.Join(err.Problems, "\n\t")
+}
+
+var (
+	entityType = reflect.TypeOf(objects.Entity{})
+
+	// collections are saved as lists of their elements.
+	collections = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(objects.Collection{}): entityType,
+		reflect.TypeOf(items.Collection{}):   reflect.TypeOf(items.Item{}),
+	}
+
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Validate checks the save, which must be of the current version, for fields
+// which do not belong in it, such as misspelled or unknown fields and
+// components. Such fields would otherwise be silently ignored as the save is
+// loaded. A ValidationError is returned listing every such field.
+func Validate(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return errors.New(err)
+	}
+
+	problems := check(value, reflect.TypeOf(entities.World{}), "")
+	if len(problems) > 0 {
+		return errors.New(ValidationError{Problems: problems})
+	}
+
+	return nil
+}
+
+// check returns a problem for every field within the decoded JSON value which
+// the type does not have. Values of the wrong kind are left for unmarshalling
+// to report.
+func check(value interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if value == nil {
+		return nil
+	}
+	if elem, ok := collections[t]; ok {
+		return checkList(value, elem, path)
+	}
+	if t == entityType {
+		return checkEntity(value, path)
+	}
+
+	// Types which unmarshal themselves from strings, such as UUIDs and dice,
+	// have no fields to check.
+	if _, ok := value.(string); ok && unmarshals(t) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		known := fields(t)
+		problems := make([]string, 0)
+		for _, key := range sortedKeys(object) {
+			ft, ok := known[key]
+			if !ok {
+				problems = append(problems, unknown(join(path, key), key, known))
+				continue
+			}
+			problems = append(problems, check(object[key], ft, join(path, key))...)
+		}
+		return problems
+
+	case reflect.Slice, reflect.Array:
+		return checkList(value, t.Elem(), path)
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		problems := make([]string, 0)
+		for _, key := range sortedKeys(object) {
+			problems = append(problems, check(object[key], t.Elem(), join(path, key))...)
+		}
+		return problems
+	}
+
+	return nil
+}
+
+// checkList checks each element of the decoded JSON list.
+func checkList(value interface{}, elem reflect.Type, path string) []string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	for i, e := range list {
+		problems = append(problems, check(e, elem, fmt.Sprintf("%s[%d]", path, i))...)
+	}
+	return problems
+}
+
+// checkEntity checks that every field of the decoded JSON entity is its ID or
+// a registered component.
+func checkEntity(value interface{}, path string) []string {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	known := make(map[string]reflect.Type)
+	for _, name := range objects.ComponentNames() {
+		c, _ := objects.NewComponent(name)
+		known[name] = reflect.TypeOf(c)
+	}
+
+	problems := make([]string, 0)
+	for _, key := range sortedKeys(object) {
+		if key == "id" {
+			continue
+		}
+
+		t, ok := known[key]
+		if !ok {
+			problems = append(problems, unknown(join(path, key), key, known))
+			continue
+		}
+		problems = append(problems, check(object[key], t, join(path, key))...)
+	}
+	return problems
+}
+
+// unmarshals returns true when the type unmarshals itself.
+func unmarshals(t reflect.Type) bool {
+	p := reflect.PtrTo(t)
+	return p.Implements(jsonUnmarshaler) || p.Implements(textUnmarshaler)
+}
+
+// fields returns the type of each field of the struct, by its JSON name.
+// Fields of embedded structs are included as if they were the struct's own.
+func fields(t reflect.Type) map[string]reflect.Type {
+	known := make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for embedded, ft := range fields(f.Type) {
+				known[embedded] = ft
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		known[name] = f.Type
+	}
+
+	return known
+}
+
+// unknown describes the unknown field, suggesting a known field it may have
+// been meant as.
+func unknown(path, key string, known map[string]reflect.Type) string {
+	best, distance := "", 3
+	for name := range known {
+		d := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if d < distance || (d == distance && name < best) {
+			best, distance = name, d
+		}
+	}
+
+	if best != "" {
+		return fmt.Sprintf("%s: unknown field %q; did you mean %q?", path, key, best)
+	}
+	return fmt.Sprintf("%s: unknown field %q", path, key)
+}
+
+// levenshtein returns the number of single character edits needed to turn
+// one string into the other.
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = smallest(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func smallest(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
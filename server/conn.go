@@ -0,0 +1,515 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/olebedev/emitter"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/auth"
+	"github.com/clagraff/pitch/comms/codec"
+	"github.com/clagraff/pitch/comms/protocol"
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/logging"
+)
+
+// connCounter is used to assign every connection a unique ID.
+var connCounter uint64
+
+// connection represents an authenticated client connection.
+type connection struct {
+	ID        uint64
+	Conn      net.Conn
+	Reader    *bufio.Reader
+	ActorID   uuid.UUID
+	Session   auth.Session
+	Version   int
+	Codec     codec.Codec
+	Requests  []string
+	Responses []string
+}
+
+// requiredResponses are the response types a client must understand to be
+// able to play at all. Besides the full view, changes to the actor's view are
+// sent as they happen, several at a time wrapped together.
+var requiredResponses = []string{
+	"ViewResponse",
+	"EntityEnteredView",
+	"EntityChanged",
+	"EntityLeftView",
+	"Wrapper",
+}
+
+// multiplexed returns true when requests and responses share the connection.
+func (c connection) multiplexed() bool {
+	return c.Version >= protocol.MultiplexVersion
+}
+
+// origin identifies the connection a request arrived on, and the correlation
+// ID the client assigned it, so the response can be matched to the request.
+// Account is the name of the account the connection logged in as.
+type origin struct {
+	ConnID        uint64
+	CorrelationID uint64
+	Account       string
+}
+
+// Handle accepted connections to the server. The connection is closed once
+// the client disconnects, sends something unreadable, or stays idle for
+// longer than the server's IdleTimeout.
+func (s Server) Handle(conn net.Conn) {
+	logger, closeLog := logging.Logger("server.Server.Handle")
+	defer closeLog()
+	defer conn.Close()
+
+	started := time.Now()
+	c := connection{
+		ID:     atomic.AddUint64(&connCounter, 1),
+		Conn:   conn,
+		Reader: bufio.NewReader(conn),
+		Codec:  codec.JSON,
+	}
+
+	h, err := s.handshake(&c)
+	if err != nil {
+		stack := errors.New(err).ErrorStack()
+		logger.Printf("%s\n", stack)
+		logger.Println("handshake failed; closing connection")
+		return
+	}
+
+	defer s.players.Leave(c.ActorID)
+
+	logger.Printf("session started for %s using protocol version %d and codec %s\n", c.ActorID.String(), c.Version, c.Codec.Name())
+	<-s.Emitter.Emit(ConnectedTopic, c.ActorID)
+
+	switch {
+	case c.multiplexed():
+		closed := make(chan error, 1)
+		go func() {
+			closed <- s.readRequests(c)
+		}()
+		s.writeResponses(c, closed)
+
+	case h.Subscribe:
+		closed := make(chan error, 1)
+		go func() {
+			closed <- s.discardHeartbeats(c)
+		}()
+		s.writeResponses(c, closed)
+
+	default:
+		err = s.readRequests(c)
+		logDisconnect(logger, c.ActorID, err)
+	}
+
+	logger.Printf("session ended for %s after %s\n", c.ActorID.String(), time.Since(started))
+	<-s.Emitter.Emit(DisconnectedTopic, c.ActorID)
+}
+
+// handshake reads the client's handshake, authenticates it, and negotiates the
+// protocol version, codec, and the request and response types to use,
+// populating the connection accordingly. Handshakes are always exchanged as
+// JSON.
+func (s Server) handshake(c *connection) (protocol.Handshake, error) {
+	logger, closeLog := logging.Logger("server.Server.handshake")
+	defer closeLog()
+
+	logger.Println("starting handshake")
+
+	h := protocol.Handshake{}
+
+	c.Conn.SetDeadline(time.Now().Add(s.IdleTimeout))
+	message, err := c.Reader.ReadBytes(protocol.Delimiter)
+	if err != nil {
+		return h, errors.New(err)
+	}
+	message = message[:len(message)-1]
+
+	logger.Println("received handshake client id")
+
+	err = json.Unmarshal(message, &h)
+	if err != nil {
+		return h, errors.New(err)
+	}
+
+	logger.Println("successfully parse client id:", h.ID.String())
+
+	c.ActorID = h.ID
+	c.Requests = protocol.Intersect(h.Requests, requests.Names())
+	c.Responses = protocol.Intersect(h.Responses, responses.Names())
+
+	c.Version, err = protocol.Negotiate(h.Version)
+	if err == nil && c.multiplexed() {
+		c.Codec, err = codec.Negotiate(h.Codecs)
+	}
+	if err == nil {
+		err = checkCapabilities(*c)
+	}
+	if err == nil {
+		c.Session, err = s.authenticate(h)
+	}
+	if err == nil {
+		err = s.players.Join(c.ActorID, s.MaxPlayers)
+	}
+
+	reply := protocol.HandshakeReply{
+		ID:        h.ID,
+		Version:   c.Version,
+		Requests:  c.Requests,
+		Responses: c.Responses,
+		Token:     c.Session.Token,
+	}
+	if c.multiplexed() && c.Codec != nil {
+		reply.Codec = c.Codec.Name()
+	}
+	if err != nil {
+		logger.Println("refusing handshake for:", h.ID.String())
+		reply.Token = ""
+		reply.Error = err.Error()
+	}
+
+	logger.Println("sending handshake response")
+
+	bites, marshalErr := json.Marshal(reply)
+	if marshalErr != nil {
+		return h, errors.New(marshalErr)
+	}
+
+	if err == nil {
+		logger.Println("negotiated requests:", c.Requests)
+		logger.Println("negotiated responses:", c.Responses)
+		logger.Println("negotiated codec:", c.Codec.Name())
+	}
+
+	_, writeErr := c.Conn.Write(append(bites, protocol.Delimiter))
+	if writeErr != nil {
+		if err == nil {
+			s.players.Leave(c.ActorID)
+		}
+		return h, errors.New(writeErr)
+	}
+
+	return h, err
+}
+
+// checkCapabilities returns an error when the client and server do not share
+// enough request and response types for the client to play.
+func checkCapabilities(c connection) error {
+	if len(c.Requests) == 0 {
+		return errors.New("client does not support any of the server's request types")
+	}
+
+	missing := protocol.Missing(c.Responses, requiredResponses)
+	if len(missing) > 0 {
+		return errors.Errorf("client does not support required response types: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// authenticate returns the session for the handshake, either by looking up
+// its token or by creating a new session if its credentials are valid. The
+// session's account must own the entity the client is connecting as.
+func (s Server) authenticate(h protocol.Handshake) (auth.Session, error) {
+	if h.Token != "" {
+		session, ok := s.Sessions.FromToken(h.Token)
+		if !ok {
+			return session, errors.New("invalid or expired session token")
+		}
+		if !session.Owns(h.ID) {
+			return auth.Session{}, errors.Errorf("account does not own entity %s", h.ID.String())
+		}
+
+		return session, nil
+	}
+
+	acct, err := s.Accounts.Authenticate(h.Name, h.Password)
+	if err != nil {
+		return auth.Session{}, err
+	}
+	if !acct.Owns(h.ID) {
+		return auth.Session{}, errors.Errorf("account does not own entity %s", h.ID.String())
+	}
+
+	return s.Sessions.Create(acct)
+}
+
+// readRequests reads requests from the connection and emits them to be
+// processed, until the connection is closed or becomes idle, returning the
+// reason. Empty messages are treated as heartbeats. Requests for actors which
+// the session does not own are rejected.
+func (s Server) readRequests(c connection) error {
+	logger, closeLog := logging.Logger("server.Server.readRequests")
+	defer closeLog()
+
+	id := c.ActorID
+
+	for {
+		logger.Println("awaiting request from", id.String())
+
+		c.Conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		message, err := c.Codec.ReadFrame(c.Reader)
+		if err != nil {
+			return err
+		}
+		if len(message) == 0 {
+			logger.Println("received heartbeat from:", id.String())
+			continue
+		}
+
+		logger.Println("received request from:", id.String())
+
+		o := origin{ConnID: c.ID, Account: c.Session.Account.Name}
+		if c.multiplexed() {
+			env := protocol.Envelope{}
+			err = c.Codec.Unmarshal(message, &env)
+			if err == nil && env.Direction != protocol.RequestDirection {
+				err = errors.Errorf("unexpected %s envelope", env.Direction)
+			}
+			o.CorrelationID = env.ID
+			message = env.Payload
+		}
+
+		var name string
+		if err == nil {
+			name, err = requests.TypeName(c.Codec, message)
+		}
+		if err == nil && !protocol.Contains(c.Requests, name) {
+			logger.Println("received unsupported request type:", name)
+			<-s.Emitter.Emit(id.String(), responses.ErrorResponse{
+				ActorID:     id,
+				Code:        responses.UnsupportedRequestError,
+				Message:     fmt.Sprintf("request type %q was not negotiated for this connection", name),
+				RequestType: name,
+			}, o)
+			continue
+		}
+
+		var req requests.Request
+		if err == nil {
+			req, err = requests.Decode(c.Codec, message)
+		}
+		if err != nil {
+			logger.Println("failed to unmarshal message from:", id.String())
+			logger.Println(string(message))
+			stack := errors.New(err).ErrorStack()
+			logger.Printf("%s\n", stack)
+
+			<-s.Emitter.Emit(id.String(), responses.ErrorResponse{
+				ActorID: id,
+				Code:    responses.MalformedRequestError,
+				Message: err.Error(),
+			}, o)
+			continue
+		}
+
+		if !c.Session.Owns(req.Actor()) {
+			logger.Printf("%s attempted to act as %s\n", id.String(), req.Actor().String())
+			<-s.Emitter.Emit(id.String(), responses.ErrorResponse{
+				ActorID:     id,
+				Code:        responses.UnauthorizedError,
+				Message:     fmt.Sprintf("not permitted to act as %s", req.Actor().String()),
+				RequestType: name,
+			}, o)
+			continue
+		}
+
+		logger.Println("emitting request:", reflect.TypeOf(req))
+		<-s.Emitter.Emit(requestTopic, req, o)
+		logger.Println("emitted request:", reflect.TypeOf(req))
+	}
+}
+
+// discardHeartbeats reads from a legacy subscription connection, where
+// clients only send heartbeats, solely to detect when the client has gone
+// away. It returns the reason the connection ended.
+func (s Server) discardHeartbeats(c connection) error {
+	for {
+		c.Conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		_, err := c.Codec.ReadFrame(c.Reader)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeResponses writes responses for the connection's actor to the
+// connection, sending a heartbeat whenever the connection would otherwise be
+// quiet. While writing, the actor's view is tracked so that changes to what
+// it can see are sent as they happen. It returns once the connection is
+// closed, as signalled by the closed channel, or can no longer be written to,
+// unsubscribing from further responses.
+func (s Server) writeResponses(c connection, closed <-chan error) {
+	logger, closeLog := logging.Logger("server.Server.writeResponses")
+	defer closeLog()
+
+	id := c.ActorID
+
+	events := s.Emitter.On(id.String())
+	defer s.Emitter.Off(id.String(), events)
+
+	// Responses are emitted by the processing goroutine, which waits for them
+	// to be received. They are buffered rather than received as they are
+	// written, so that it never waits on the connection.
+	size := s.OutboundBuffer
+	if size == 0 {
+		size = DefaultOutboundBuffer
+	}
+	outbound, overflowed := buffer(events, size)
+
+	// A client which falls too far behind is disconnected, closing the
+	// connection to interrupt any write it is holding up.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-overflowed:
+			logger.Println("client fell too far behind; disconnecting:", id.String())
+			c.Conn.Close()
+		case <-stop:
+		}
+	}()
+
+	// The processing goroutine may be part way through a tick, so these are
+	// emitted without waiting for them to be received.
+	s.Emitter.Emit(subscribeTopic, id)
+	defer s.Emitter.Emit(unsubscribeTopic, id)
+
+	heartbeat := time.NewTicker(s.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	logger.Println("awaiting responses to write for:", id.String())
+
+	for {
+		var message []byte
+
+		select {
+		case err := <-closed:
+			logDisconnect(logger, id, err)
+			return
+
+		case <-heartbeat.C:
+			logger.Println("sending heartbeat to:", id.String())
+
+		case event, ok := <-outbound:
+			if !ok {
+				logger.Println("response subscription closed for:", id.String())
+				return
+			}
+			if len(event.Args) == 0 {
+				continue
+			}
+
+			logger.Println("receiving response to write")
+			resp := event.Args[0].(responses.Response)
+			if !supports(c.Responses, resp) {
+				logger.Println("dropping response unsupported by client:", reflect.TypeOf(resp))
+				continue
+			}
+			logger.Println("mashralling response:", reflect.TypeOf(resp))
+
+			bites, err := responses.Encode(c.Codec, resp)
+			if err == nil && c.multiplexed() {
+				env := protocol.Envelope{
+					Direction: protocol.ResponseDirection,
+					Payload:   codec.RawMessage(bites),
+				}
+				if len(event.Args) > 1 {
+					if o, ok := event.Args[1].(origin); ok && o.ConnID == c.ID {
+						env.ID = o.CorrelationID
+					}
+				}
+				bites, err = c.Codec.Marshal(env)
+			}
+			if err != nil {
+				stack := errors.New(err).ErrorStack()
+				logger.Printf("%s\n", stack)
+				continue
+			}
+
+			logger.Println("sending response:", reflect.TypeOf(resp))
+			message = bites
+		}
+
+		c.Conn.SetWriteDeadline(time.Now().Add(s.IdleTimeout))
+		err := c.Codec.WriteFrame(c.Conn, message)
+		if err != nil {
+			logDisconnect(logger, id, err)
+			return
+		}
+	}
+}
+
+// buffer relays the events into a channel holding up to size events, so that
+// events are always received straight away. Should the buffer fill up, the
+// overflowed channel is closed, and later events are discarded until events is
+// closed.
+func buffer(events <-chan emitter.Event, size int) (<-chan emitter.Event, <-chan struct{}) {
+	buffered := make(chan emitter.Event, size)
+	overflowed := make(chan struct{})
+
+	go func() {
+		defer close(buffered)
+
+		full := false
+		for event := range events {
+			if full {
+				continue
+			}
+
+			select {
+			case buffered <- event:
+			default:
+				full = true
+				close(overflowed)
+			}
+		}
+	}()
+
+	return buffered, overflowed
+}
+
+// supports returns true when the response, and any responses it wraps, are
+// all of the specified types.
+func supports(names []string, resp responses.Response) bool {
+	name, _ := responses.NameOf(resp)
+	if !protocol.Contains(names, name) {
+		return false
+	}
+
+	if w, ok := resp.(responses.Wrapper); ok {
+		for _, r := range w.Responses {
+			if !supports(names, r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// logDisconnect logs why the connection for the specified ID has ended.
+func logDisconnect(logger *log.Logger, id uuid.UUID, err error) {
+	if err == io.EOF {
+		logger.Println("client closed connection:", id.String())
+		return
+	}
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		logger.Println("client connection idled out:", id.String())
+		return
+	}
+
+	logger.Printf("connection lost for %s: %s\n", id.String(), err)
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olebedev/emitter"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/auth"
+	"github.com/clagraff/pitch/comms/codec"
+	"github.com/clagraff/pitch/comms/protocol"
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+)
+
+// Requests are only emitted for actors which the session's account owns;
+// anything else is rejected back to the connection's actor.
+func TestReadRequestsOwnership(t *testing.T) {
+	owned := uuid.Must(uuid.NewV4())
+	other := uuid.Must(uuid.NewV4())
+
+	s := Server{Emitter: &emitter.Emitter{}, IdleTimeout: time.Minute}
+	reqs := s.Emitter.On(requestTopic)
+	defer s.Emitter.Off(requestTopic, reqs)
+	resps := s.Emitter.On(owned.String())
+	defer s.Emitter.Off(owned.String(), resps)
+
+	conn, client := net.Pipe()
+	defer client.Close()
+
+	c := connection{
+		Conn:     conn,
+		Reader:   bufio.NewReader(conn),
+		ActorID:  owned,
+		Session:  auth.Session{Account: auth.Account{Name: "player", EntityIDs: []uuid.UUID{owned}}},
+		Codec:    codec.JSON,
+		Requests: requests.Names(),
+	}
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.readRequests(c)
+	}()
+
+	send := func(req requests.Request) {
+		bites, err := requests.Encode(codec.JSON, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = codec.JSON.WriteFrame(client, bites)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(requests.MoveRequest{ActorID: other, Direction: requests.North})
+	select {
+	case event := <-resps:
+		resp, ok := event.Args[0].(responses.ErrorResponse)
+		if !ok || resp.Code != responses.UnauthorizedError || resp.RequestType != "MoveRequest" {
+			t.Errorf("acting as another entity was answered with %+v, want an unauthorized error", event.Args[0])
+		}
+	case event := <-reqs:
+		t.Errorf("acting as another entity emitted %+v", event.Args[0])
+	case <-time.After(time.Second):
+		t.Fatal("acting as another entity was not rejected")
+	}
+
+	send(requests.MoveRequest{ActorID: owned, Direction: requests.North})
+	select {
+	case event := <-reqs:
+		req, ok := event.Args[0].(requests.MoveRequest)
+		if !ok || req.ActorID != owned {
+			t.Errorf("emitted %+v, want the move of the owned entity", event.Args[0])
+		}
+		if o := event.Args[1].(origin); o.Account != "player" {
+			t.Errorf("request came from account %q, want player", o.Account)
+		}
+	case event := <-resps:
+		t.Errorf("acting as the owned entity was answered with %+v", event.Args[0])
+	case <-time.After(time.Second):
+		t.Fatal("acting as the owned entity was not emitted")
+	}
+
+	client.Close()
+	if err := <-closed; err == nil {
+		t.Error("readRequests returned no reason for the connection closing")
+	}
+}
+
+// Responses are received straight away, even while the client is not reading
+// them, until so many are waiting that the client is disconnected.
+func TestWriteResponsesOverflow(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+
+	s := Server{
+		Emitter:           &emitter.Emitter{},
+		IdleTimeout:       time.Minute,
+		HeartbeatInterval: time.Minute,
+		OutboundBuffer:    4,
+	}
+	subscribes := s.Emitter.On(subscribeTopic)
+	defer s.Emitter.Off(subscribeTopic, subscribes)
+
+	conn, client := net.Pipe()
+	defer client.Close()
+
+	c := connection{
+		Conn:      conn,
+		ActorID:   id,
+		Codec:     codec.JSON,
+		Responses: responses.Names(),
+	}
+	done := make(chan struct{})
+	go func() {
+		s.writeResponses(c, make(chan error))
+		close(done)
+	}()
+	<-subscribes
+
+	// The client never reads, so the first response is stuck being written
+	// while the rest wait in the buffer.
+	for i := 0; i < 10; i++ {
+		select {
+		case <-s.Emitter.Emit(id.String(), responses.ErrorResponse{ActorID: id}):
+		case <-time.After(time.Second):
+			t.Fatalf("emitting response %d waited on the client", i)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the client was not disconnected once its buffer filled")
+	}
+}
+
+// The handshake settles on the newest protocol version and first codec both
+// sides support, refusing clients which cannot play.
+func TestHandshake(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+
+	s := NewServer("localhost", 0)
+	acct, err := auth.NewAccount("player", "secret", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Accounts.Insert(acct)
+
+	handshake := func(h protocol.Handshake) protocol.HandshakeReply {
+		h.ID = id
+		h.Name = "player"
+		if h.Password == "" {
+			h.Password = "secret"
+		}
+
+		conn, client := net.Pipe()
+		defer client.Close()
+
+		c := connection{Conn: conn, Reader: bufio.NewReader(conn), Codec: codec.JSON}
+		go func() {
+			if _, err := s.handshake(&c); err == nil {
+				s.players.Leave(id)
+			}
+			conn.Close()
+		}()
+
+		bites, err := json.Marshal(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Write(append(bites, protocol.Delimiter))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		message, err := bufio.NewReader(client).ReadBytes(protocol.Delimiter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := protocol.HandshakeReply{}
+		err = json.Unmarshal(message, &reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return reply
+	}
+
+	tests := []struct {
+		name      string
+		handshake protocol.Handshake
+		version   int
+		codec     string
+		refused   string
+	}{
+		{
+			"clients without a version use the legacy protocol",
+			protocol.Handshake{},
+			protocol.LegacyVersion, "", "",
+		},
+		{
+			"clients newer than the server use the current protocol",
+			protocol.Handshake{Version: protocol.CurrentVersion + 1},
+			protocol.CurrentVersion, "json", "",
+		},
+		{
+			"the first supported codec is used",
+			protocol.Handshake{Version: protocol.MultiplexVersion, Codecs: []string{"xml", "msgpack", "json"}},
+			protocol.MultiplexVersion, "msgpack", "",
+		},
+		{
+			"clients without a supported codec are refused",
+			protocol.Handshake{Version: protocol.MultiplexVersion, Codecs: []string{"xml"}},
+			protocol.MultiplexVersion, "", "codecs",
+		},
+		{
+			"clients without any supported requests are refused",
+			protocol.Handshake{Version: protocol.MultiplexVersion, Requests: []string{"TeleportRequest"}},
+			protocol.MultiplexVersion, "json", "request types",
+		},
+		{
+			"clients which cannot follow changes to their view are refused",
+			protocol.Handshake{Version: protocol.MultiplexVersion, Responses: []string{"ViewResponse", "EntityChanged"}},
+			protocol.MultiplexVersion, "json", "EntityEnteredView, EntityLeftView, Wrapper",
+		},
+		{
+			"clients with the wrong password are refused",
+			protocol.Handshake{Version: protocol.MultiplexVersion, Password: "guess"},
+			protocol.MultiplexVersion, "json", "password",
+		},
+	}
+
+	for _, test := range tests {
+		reply := handshake(test.handshake)
+
+		if reply.Version != test.version {
+			t.Errorf("%s: negotiated version %d, want %d", test.name, reply.Version, test.version)
+		}
+		if reply.Codec != test.codec {
+			t.Errorf("%s: negotiated codec %q, want %q", test.name, reply.Codec, test.codec)
+		}
+
+		if test.refused == "" {
+			if reply.Error != "" || reply.Token == "" {
+				t.Errorf("%s: refused with %q", test.name, reply.Error)
+			}
+			continue
+		}
+		if !strings.Contains(reply.Error, test.refused) || reply.Token != "" {
+			t.Errorf("%s: got error %q, want it to mention %q", test.name, reply.Error, test.refused)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/go-errors/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// players counts the connections open for each connected player, so that the
+// number of players can be limited. A player may have several connections
+// open at once, such as when reconnecting, and is only counted once.
+type players struct {
+	mutex       sync.Mutex
+	connections map[uuid.UUID]int
+}
+
+func newPlayers() *players {
+	return &players{connections: make(map[uuid.UUID]int)}
+}
+
+// Join records another connection for the player. An error is returned if the
+// player is not already connected and the limit has been reached; there is no
+// limit when it is zero.
+func (p *players) Join(id uuid.UUID, limit int) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.connections[id]; !ok && limit > 0 && len(p.connections) >= limit {
+		return errors.Errorf("server is full; the limit is %d players", limit)
+	}
+
+	p.connections[id]++
+	return nil
+}
+
+// Leave records that one of the player's connections has closed.
+func (p *players) Leave(id uuid.UUID) {
+	if p == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.connections[id]--
+	if p.connections[id] <= 0 {
+		delete(p.connections, id)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+)
+
+// maxQueuedRequests is the most requests an actor may have waiting to be
+// resolved at once.
+const maxQueuedRequests = 10
+
+// queuedRequest is a request waiting to be resolved, along with the origin to
+// send its response to.
+type queuedRequest struct {
+	Request requests.Request
+	Origin  origin
+}
+
+// actionQueue holds the requests waiting to be resolved, per actor, in the
+// order they arrived.
+type actionQueue struct {
+	queues map[uuid.UUID][]queuedRequest
+}
+
+func newActionQueue() *actionQueue {
+	return &actionQueue{
+		queues: make(map[uuid.UUID][]queuedRequest),
+	}
+}
+
+// Push adds the request to the end of its actor's queue. It returns false if
+// the actor already has too many requests queued.
+func (q *actionQueue) Push(req requests.Request, o origin) bool {
+	id := req.Actor()
+	if len(q.queues[id]) >= maxQueuedRequests {
+		return false
+	}
+
+	q.queues[id] = append(q.queues[id], queuedRequest{
+		Request: req,
+		Origin:  o,
+	})
+
+	return true
+}
+
+// Next removes and returns the oldest request of every actor with requests
+// queued, for which ready returns true. A nil ready function accepts every
+// request. Actors are ordered by ID, starting from a different actor each
+// tick, so the order is deterministic but no actor always acts first.
+func (q *actionQueue) Next(tick uint64, ready func(requests.Request) bool) []queuedRequest {
+	ids := make([]uuid.UUID, 0, len(q.queues))
+	for id := range q.queues {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0
+	})
+
+	next := make([]queuedRequest, 0, len(ids))
+	for i := range ids {
+		id := ids[(uint64(i)+tick)%uint64(len(ids))]
+
+		head := q.queues[id][0]
+		if ready != nil && !ready(head.Request) {
+			continue
+		}
+
+		next = append(next, head)
+		q.queues[id] = q.queues[id][1:]
+		if len(q.queues[id]) == 0 {
+			delete(q.queues, id)
+		}
+	}
+
+	return next
+}
+
+// Queued returns true when the actor has requests waiting to be resolved.
+func (q *actionQueue) Queued(id uuid.UUID) bool {
+	return len(q.queues[id]) > 0
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+)
+
+func TestActionQueuePush(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	queue := newActionQueue()
+
+	for i := 0; i < maxQueuedRequests; i++ {
+		if !queue.Push(requests.MoveRequest{ActorID: id}, origin{}) {
+			t.Fatalf("request %d was refused, want at most %d queued", i, maxQueuedRequests)
+		}
+	}
+	if queue.Push(requests.MoveRequest{ActorID: id}, origin{}) {
+		t.Errorf("request %d was queued, want at most %d queued", maxQueuedRequests, maxQueuedRequests)
+	}
+
+	// Other actors have queues of their own.
+	if !queue.Push(requests.MoveRequest{ActorID: uuid.Must(uuid.NewV4())}, origin{}) {
+		t.Error("another actor's request was refused")
+	}
+}
+
+func TestActionQueueNext(t *testing.T) {
+	a := uuid.FromStringOrNil("00000000-0000-0000-0000-00000000000a")
+	b := uuid.FromStringOrNil("00000000-0000-0000-0000-00000000000b")
+
+	move := func(id uuid.UUID, direction requests.Direction) requests.Request {
+		return requests.MoveRequest{ActorID: id, Direction: direction}
+	}
+	onlyA := func(req requests.Request) bool {
+		return req.Actor() == a
+	}
+
+	tests := []struct {
+		name   string
+		queued []requests.Request
+		tick   uint64
+		ready  func(requests.Request) bool
+		want   []requests.Request
+		left   []uuid.UUID
+	}{
+		{
+			"nothing is queued",
+			nil, 0, nil,
+			[]requests.Request{},
+			nil,
+		},
+		{
+			"the oldest request of each actor is next",
+			[]requests.Request{move(a, requests.North), move(a, requests.South), move(b, requests.East)},
+			0, nil,
+			[]requests.Request{move(a, requests.North), move(b, requests.East)},
+			[]uuid.UUID{a},
+		},
+		{
+			"actors take turns going first",
+			[]requests.Request{move(a, requests.North), move(b, requests.East)},
+			1, nil,
+			[]requests.Request{move(b, requests.East), move(a, requests.North)},
+			nil,
+		},
+		{
+			"requests which are not ready stay queued",
+			[]requests.Request{move(a, requests.North), move(b, requests.East), move(b, requests.West)},
+			0, onlyA,
+			[]requests.Request{move(a, requests.North)},
+			[]uuid.UUID{b},
+		},
+	}
+
+	for _, test := range tests {
+		queue := newActionQueue()
+		for _, req := range test.queued {
+			queue.Push(req, origin{})
+		}
+
+		next := queue.Next(test.tick, test.ready)
+		got := make([]requests.Request, len(next))
+		for i, q := range next {
+			got[i] = q.Request
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d requests, want %d", test.name, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: request %d is %+v, want %+v", test.name, i, got[i], test.want[i])
+			}
+		}
+
+		for _, id := range []uuid.UUID{a, b} {
+			left := false
+			for _, l := range test.left {
+				left = left || l == id
+			}
+			if queue.Queued(id) != left {
+				t.Errorf("%s: %s queued is %t, want %t", test.name, id, queue.Queued(id), left)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"reflect"
+
+	"github.com/go-errors/errors"
+
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/journal"
+	"github.com/clagraff/pitch/logging"
+)
+
+// Replay performs the journalled requests against the world headlessly, as
+// the server originally did: the world is advanced to the tick of each entry,
+// running the server's systems, before the entry's request is performed.
+//
+// Journals start with an entry marking the save they were recorded from, and
+// an error is returned if the world is not that save. Journals without one are
+// assumed to have been recorded from the world at tick zero: as the world is
+// saved between ticks, once that tick's requests have been performed, entries
+// up to and including the tick of a later save are skipped.
+//
+// Before each request, the world's RNG is checked against the journalled
+// state. Should they differ, the world has diverged from the one the journal
+// was recorded against, and an error is returned along with the world as it
+// was just before the entry.
+func (s Server) Replay(world entities.World, entries []journal.Entry) (entities.World, error) {
+	logger, closeLog := logging.Logger("server.Server.Replay")
+	defer closeLog()
+
+	if len(entries) > 0 && entries[0].Saved() {
+		if entries[0].Tick != world.Tick || entries[0].RNG != *world.RNG {
+			return world, errors.Errorf(
+				"journal was recorded from the world saved at tick %d with rng %+v, but the world is at tick %d with rng %+v",
+				entries[0].Tick, entries[0].RNG, world.Tick, world.RNG,
+			)
+		}
+	} else if world.Tick > 0 {
+		skipped := 0
+		for skipped < len(entries) && entries[skipped].Tick <= world.Tick {
+			skipped++
+		}
+		logger.Printf("skipping %d journal entries up to tick %d\n", skipped, world.Tick)
+		entries = entries[skipped:]
+	}
+
+	for i, entry := range entries {
+		if entry.Tick < world.Tick {
+			return world, errors.Errorf(
+				"journal entry %d is for tick %d, but the world is already at tick %d",
+				i+1, entry.Tick, world.Tick,
+			)
+		}
+
+		for world.Tick < entry.Tick {
+			world = s.advance(world)
+		}
+
+		if *world.RNG != entry.RNG {
+			return world, errors.Errorf(
+				"journal entry %d diverged at tick %d: expected rng %+v, but the world has %+v",
+				i+1, entry.Tick, entry.RNG, world.RNG,
+			)
+		}
+		if entry.Saved() {
+			continue
+		}
+
+		logger.Printf("tick %d: replaying request: %s\n", world.Tick, reflect.TypeOf(entry.Request))
+
+		var err error
+		world, _, err = perform(entry.Request, world)
+		if err != nil {
+			logger.Printf("tick %d: request failed: %s\n", world.Tick, err)
+		}
+	}
+
+	return world, nil
+}
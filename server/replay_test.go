@@ -0,0 +1,161 @@
+package server_test
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/journal"
+	"github.com/clagraff/pitch/server"
+	"github.com/clagraff/pitch/systems"
+	"github.com/clagraff/pitch/utils"
+)
+
+// saved returns a world saved at the tick, holding a single actor at the
+// origin.
+func saved(tick uint64) (entities.World, uuid.UUID) {
+	world := entities.MakeWorld()
+	world.Tick = tick
+
+	actor := objects.New()
+	actor.Set(objects.Position{X: 0, Y: 0})
+	world.Objects = world.Objects.Append(*actor)
+
+	return world, actor.ID
+}
+
+func TestReplay(t *testing.T) {
+	s := server.NewServer("localhost", 0)
+	s.Systems = nil
+
+	tests := []struct {
+		name    string
+		tick    uint64
+		entries func(id uuid.UUID) []journal.Entry
+		y       int
+		fails   bool
+	}{
+		{
+			"requests up to the tick of the save were already performed",
+			5,
+			func(id uuid.UUID) []journal.Entry {
+				return []journal.Entry{
+					{Tick: 4, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+					{Tick: 5, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+					{Tick: 6, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+				}
+			},
+			1, false,
+		},
+		{
+			"requests on tick zero are performed against a save at tick zero",
+			0,
+			func(id uuid.UUID) []journal.Entry {
+				return []journal.Entry{
+					{Tick: 0, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+				}
+			},
+			1, false,
+		},
+		{
+			"a segment started from the save is performed in full",
+			5,
+			func(id uuid.UUID) []journal.Entry {
+				return []journal.Entry{
+					{Tick: 5, RNG: *utils.NewSource(0)},
+					{Tick: 5, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+					{Tick: 7, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+				}
+			},
+			2, false,
+		},
+		{
+			"a segment started from another save is refused",
+			5,
+			func(id uuid.UUID) []journal.Entry {
+				return []journal.Entry{
+					{Tick: 3, RNG: *utils.NewSource(0)},
+					{Tick: 4, RNG: *utils.NewSource(0), Request: requests.MoveRequest{ActorID: id, Direction: requests.South}},
+				}
+			},
+			0, true,
+		},
+	}
+
+	for _, test := range tests {
+		world, id := saved(test.tick)
+
+		world, err := s.Replay(world, test.entries(id))
+		if (err != nil) != test.fails {
+			t.Errorf("%s: replay error = %v, want failure %t", test.name, err, test.fails)
+			continue
+		}
+
+		actor, _ := world.Objects.FromID(id)
+		if pos, _ := actor.Position(); pos.Y != test.y {
+			t.Errorf("%s: actor is at y %d, want %d", test.name, pos.Y, test.y)
+		}
+	}
+}
+
+// teleport moves the actor, then panics part way through.
+type teleport struct {
+	ActorID uuid.UUID
+}
+
+func (req teleport) Actor() uuid.UUID {
+	return req.ActorID
+}
+
+func (req teleport) Execute(ctx requests.Context, world entities.World) (entities.World, responses.Response, error) {
+	actor, _ := world.Objects.FromID(req.ActorID)
+	actor.Set(objects.Position{X: 9, Y: 9})
+	world.Objects = world.Objects.MustUpdate(actor)
+
+	panic("teleport failed")
+}
+
+// drift moves every entity, then panics part way through.
+type drift struct{}
+
+func (drift) Name() string {
+	return "drift"
+}
+
+func (drift) Update(world entities.World) (entities.World, error) {
+	for _, e := range world.Objects.With(objects.PositionComponent) {
+		e.Set(objects.Position{X: 9, Y: 9})
+		world.Objects = world.Objects.MustUpdate(e)
+	}
+
+	panic("drift failed")
+}
+
+// Requests and systems which panic leave the world as it was before them.
+func TestReplayPanics(t *testing.T) {
+	s := server.NewServer("localhost", 0)
+	s.Systems = []systems.System{drift{}}
+
+	world, id := saved(0)
+	world, err := s.Replay(world, []journal.Entry{
+		{Tick: 1, RNG: *utils.NewSource(0), Request: teleport{ActorID: id}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actor, _ := world.Objects.FromID(id)
+	if pos, _ := actor.Position(); pos != (objects.Position{}) {
+		t.Errorf("actor is at %+v, want the origin", pos)
+	}
+	if l := world.Objects.FromXY(0, 0); len(l) != 1 || l[0].ID != id {
+		t.Errorf("origin holds %d entities, want the actor", len(l))
+	}
+	if l := world.Objects.FromXY(9, 9); len(l) != 0 {
+		t.Errorf("%d entities were left at 9, 9", len(l))
+	}
+}
@@ -1,31 +1,96 @@
 package server
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net"
+	"os"
+	"os/signal"
 	"reflect"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-errors/errors"
 	"github.com/olebedev/emitter"
 	uuid "github.com/satori/go.uuid"
 
+	"github.com/clagraff/pitch/auth"
 	"github.com/clagraff/pitch/comms/requests"
 	"github.com/clagraff/pitch/comms/responses"
 	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+	"github.com/clagraff/pitch/journal"
 	"github.com/clagraff/pitch/logging"
+	"github.com/clagraff/pitch/saves"
+	"github.com/clagraff/pitch/systems"
 )
 
-var delimiter = byte('\n')
+const requestTopic = "request"
+
+// Topics emitted on the server's Emitter which other subsystems can hook into.
+// Both are emitted with the player's entity UUID as the sole argument, once
+// for each connection a player opens or closes.
+const (
+	ConnectedTopic    = "player.connected"
+	DisconnectedTopic = "player.disconnected"
+)
+
+// Default connection and simulation policy used by NewServer.
+const (
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultHeartbeatInterval = 30 * time.Second
+	DefaultTickInterval      = 100 * time.Millisecond
+	DefaultAccountsPath      = "accounts.json"
+	DefaultAutosaveInterval  = time.Minute
+	DefaultOutboundBuffer    = 256
+)
 
 // Server is used to manage a TCP game server.
 type Server struct {
 	Emitter *emitter.Emitter
 	Host    string
 	Port    int
+
+	// IdleTimeout is how long a connection may go without receiving anything,
+	// including heartbeats, before it is closed.
+	IdleTimeout time.Duration
+	// HeartbeatInterval is how often an otherwise quiet subscription is sent
+	// a heartbeat, so the client knows the server is still alive.
+	HeartbeatInterval time.Duration
+	// OutboundBuffer is how many responses may wait to be written to a
+	// connection. Clients which fall further behind are disconnected, so that
+	// the game never waits on them. DefaultOutboundBuffer is used when it is
+	// zero.
+	OutboundBuffer int
+
+	// TickInterval is how much real time passes between each game tick.
+	TickInterval time.Duration
+	// ViewInterval is how often subscribed players are sent any changes to
+	// their view. Views are refreshed every tick when it is zero.
+	ViewInterval time.Duration
+	// Systems are run against the world, in order, once every tick.
+	Systems []systems.System
+
+	// AccountsPath is the file from which player accounts are loaded.
+	AccountsPath string
+	Accounts     auth.Accounts
+	Sessions     *auth.Sessions
+
+	// MaxPlayers is how many players may be connected at once. There is no
+	// limit when it is zero.
+	MaxPlayers int
+	players    *players
+
+	// JournalPath is the file every performed request is appended to, so
+	// that the game can be replayed. Journalling is disabled when empty.
+	JournalPath string
+	journal     *journal.Writer
+
+	// Store is where the world is loaded from, and saved back to every
+	// AutosaveInterval and when the server is shut down, taking a snapshot
+	// with each autosave. Autosaving is disabled when the interval is zero.
+	Store            saves.WorldStore
+	AutosaveInterval time.Duration
 }
 
 // NewServer returns a pointer to an instantiated Server instance.
@@ -39,6 +104,27 @@ func NewServer(host string, port int) *Server {
 		Emitter: &emitter.Emitter{},
 		Host:    host,
 		Port:    port,
+
+		IdleTimeout:       DefaultIdleTimeout,
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		OutboundBuffer:    DefaultOutboundBuffer,
+
+		TickInterval: DefaultTickInterval,
+		Systems:      systems.Defaults(),
+
+		AccountsPath: DefaultAccountsPath,
+		Accounts:     auth.MakeAccounts(),
+		Sessions:     auth.NewSessions(),
+
+		players: newPlayers(),
+
+		JournalPath: journal.DefaultPath,
+
+		Store: saves.NewJSONStore(saves.DefaultPath, saves.Snapshots{
+			Dir:  saves.DefaultSnapshotsPath,
+			Keep: saves.DefaultSnapshotsKept,
+		}),
+		AutosaveInterval: DefaultAutosaveInterval,
 	}
 
 	return &s
@@ -49,22 +135,44 @@ func (s Server) Serve() error {
 	logger, closeLog := logging.Logger("server.Server.Serve")
 	defer closeLog()
 
-	logger.Printf("Server listening on %s:%d\n", s.Host, s.Port)
+	logger.Println("loading accounts from:", s.AccountsPath)
+	accounts, err := auth.LoadAccounts(s.AccountsPath)
+	if err != nil {
+		return err
+	}
+	s.Accounts = accounts
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	logger.Println("Server listening on", addr)
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.Host, s.Port))
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
 
+	// Once the world has been saved after a shutdown signal, stop accepting
+	// connections so that Serve returns.
+	stopped := make(chan struct{})
+
 	logger.Printf("starting process goroutine")
-	go s.Process()
+	go func() {
+		s.Process()
+		close(stopped)
+		listener.Close()
+	}()
 
 	for {
 		logger.Printf("awaiting connection")
 		conn, err := listener.Accept()
 		if err != nil {
-			return err
+			select {
+			case <-stopped:
+				logger.Println("server stopped")
+				return nil
+			default:
+				return err
+			}
 		}
 
 		logger.Printf("received connection")
@@ -72,157 +180,357 @@ func (s Server) Serve() error {
 	}
 }
 
-type connHandshake struct {
-	ID        uuid.UUID `json:"id"`
-	Subscribe bool      `json:"subscribe"`
-}
-
-// Handle accepted connections to the server.
-func (s Server) Handle(conn net.Conn) {
-	logger, closeLog := logging.Logger("server.Server.Handle")
+// Process requests against the game world. Requests are queued per actor as
+// they arrive, and resolved at the next tick: immediately in real-time worlds,
+// or once the actor has enough energy in turn-based worlds. Subscribed actors
+// are then sent any changes to their view.
+//
+// The world is autosaved periodically. Process returns once the world has
+// been saved after the process receives SIGINT or SIGTERM.
+func (s Server) Process() {
+	logger, closeLog := logging.Logger("server.Server.Process")
 	defer closeLog()
 
-	logger.Println("starting handshake")
+	logger.Println("loading game world")
 
-	// Perform handshake
-	message, err := bufio.NewReader(conn).ReadBytes(delimiter)
+	world, err := s.Store.Load()
 	if err != nil {
-		stack := errors.New(err).ErrorStack()
+		stack := errors.Wrap(err, 0).ErrorStack()
 		logger.Printf("%s\n", stack)
 		panic(stack)
 	}
-	message = message[:len(message)-1]
+	defer s.Store.Close()
 
-	logger.Println("received handshake client id")
+	logger.Println("game world loaded at tick:", world.Tick)
 
-	h := connHandshake{}
-	err = json.Unmarshal(message, &h)
-	if err != nil {
-		stack := errors.New(err).ErrorStack()
-		logger.Printf("%s\n", stack)
-		panic(stack)
+	if s.JournalPath != "" {
+		logger.Println("journalling requests to:", s.JournalPath)
+		s.journal, err = journal.Open(s.JournalPath, world.Tick, *world.RNG)
+		if err != nil {
+			stack := errors.New(err).ErrorStack()
+			logger.Printf("%s\n", stack)
+			panic(stack)
+		}
+		defer s.journal.Close()
 	}
+	logger.Println("await requests to process")
 
-	logger.Println("successfully parse client id:", h.ID.String())
-	logger.Println("sending handshake response")
+	reqs := s.Emitter.On(requestTopic)
+	subscribes := s.Emitter.On(subscribeTopic)
+	unsubscribes := s.Emitter.On(unsubscribeTopic)
+	queue := newActionQueue()
+	v := newViews()
 
-	message, err = json.Marshal(h.ID)
-	if err != nil {
-		stack := errors.New(err).ErrorStack()
-		logger.Printf("%s\n", stack)
-		panic(stack)
+	ticker := time.NewTicker(s.TickInterval)
+	defer ticker.Stop()
+
+	var autosaves <-chan time.Time
+	if s.AutosaveInterval > 0 {
+		autosave := time.NewTicker(s.AutosaveInterval)
+		defer autosave.Stop()
+		autosaves = autosave.C
 	}
 
-	conn.Write(message)
-	conn.Write([]byte{delimiter})
+	var refreshes <-chan time.Time
+	if s.ViewInterval > 0 {
+		refresh := time.NewTicker(s.ViewInterval)
+		defer refresh.Stop()
+		refreshes = refresh.C
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case sig := <-signals:
+			logger.Printf("received %s; saving game world at tick %d\n", sig, world.Tick)
+			s.save(world)
+			return
+
+		case <-autosaves:
+			logger.Println("autosaving game world at tick:", world.Tick)
+			if s.save(world) {
+				s.snapshot(world)
+			}
+
+		case event := <-subscribes:
+			if len(event.Args) == 1 {
+				id := event.Args[0].(uuid.UUID)
+				logger.Println("subscribing view for:", id.String())
+				v.Subscribe(id)
+				s.syncView(v, world, id)
+			}
+
+		case event := <-unsubscribes:
+			if len(event.Args) == 1 {
+				id := event.Args[0].(uuid.UUID)
+				logger.Println("unsubscribing view for:", id.String())
+				v.Unsubscribe(id)
+			}
+
+		case event := <-reqs:
+			if len(event.Args) == 2 {
+				req := event.Args[0].(requests.Request)
+				o := event.Args[1].(origin)
+				logger.Println("queueing request:", reflect.TypeOf(req))
+
+				if !queue.Push(req, o) {
+					err := errors.New(requests.Error{
+						Code:    responses.NotReadyError,
+						Message: fmt.Sprintf("too many requests queued; at most %d are allowed", maxQueuedRequests),
+					})
+					<-s.Emitter.Emit(req.Actor().String(), requests.NewErrorResponse(req, err), o)
+				}
+			}
 
-	if h.Subscribe {
-		// setup response writer
-		writeResponses(s.Emitter, conn, h.ID)
-		// listen for further requests
-	} else {
-		buff := bufio.NewReader(conn)
-		for {
-			logger.Println("awaiting request from", h.ID.String())
+		case <-ticker.C:
+			if world.TurnBased() {
+				world = s.takeTurns(world, queue, v.Subscribers())
+			} else {
+				world = s.tick(world, queue)
+			}
 
-			message, err = buff.ReadBytes(delimiter)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
+			if s.ViewInterval == 0 {
+				for _, id := range v.Subscribers() {
+					s.syncView(v, world, id)
+				}
 			}
-			logger.Println("received request from:", h.ID.String())
-			message = message[:len(message)-1]
-			req, err := requests.Unmarshal(message)
-			if err != nil {
-				logger.Println("failed to unmarshal message from:", h.ID.String())
-				logger.Println(string(message))
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
-				//continue
+
+		case <-refreshes:
+			for _, id := range v.Subscribers() {
+				s.syncView(v, world, id)
 			}
-			logger.Println("emitting request:", reflect.TypeOf(req))
-			<-s.Emitter.Emit("request", req)
-			logger.Println("emitted request:", reflect.TypeOf(req))
-			conn.SetDeadline(time.Now().Add(2 * time.Minute))
 		}
 	}
 }
 
-func writeResponses(e *emitter.Emitter, conn net.Conn, id uuid.UUID) {
-	logger, closeLog := logging.Logger("server.writeResponses")
-	defer closeLog()
+// tick advances a real-time world by a single tick, running the world's
+// systems and then resolving the next queued request of each actor. Requests
+// stay queued until their actor's timer is ready, but free requests are
+// resolved immediately.
+func (s Server) tick(world entities.World, queue *actionQueue) entities.World {
+	world = s.advance(world)
+
+	ready := func(req requests.Request) bool {
+		if requests.EnergyCost(req) == 0 {
+			return true
+		}
 
-	logger.Println("awaiting responses to write for:", id.String())
+		actor, ok := world.Objects.FromID(req.Actor())
+		if !ok {
+			return true
+		}
 
-	for event := range e.On(id.String()) {
-		if len(event.Args) == 1 {
-			logger.Println("receiving response to write")
-			resp := event.Args[0].(responses.Response)
-			logger.Println("mashralling response:", reflect.TypeOf(resp))
+		return requests.CanAct(world, actor)
+	}
 
-			bites, err := responses.Marshal(resp)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
+	for _, q := range queue.Next(world.Tick, ready) {
+		world = s.resolve(world, q)
+	}
+
+	return world
+}
+
+// advance moves the world on to the next tick, running the world's systems.
+// In turn-based worlds, entities also gain energy.
+func (s Server) advance(world entities.World) entities.World {
+	logger, closeLog := logging.Logger("server.Server.advance")
+	defer closeLog()
+
+	world.Tick++
+
+	if world.TurnBased() {
+		for _, e := range world.Objects.With(objects.EnergyComponent) {
+			energy, _ := e.Energy()
+			if energy.Scheduled() {
+				energy.Gain()
+				e.Set(energy)
+				world.Objects = world.Objects.MustUpdate(e)
 			}
+		}
+	}
 
-			logger.Println("sending response:", reflect.TypeOf(resp))
-			conn.Write(bites)
-			conn.Write([]byte{delimiter})
-			conn.SetDeadline(time.Now().Add(2 * time.Minute))
-			logger.Println("sent response:", reflect.TypeOf(resp))
+	for _, sys := range s.Systems {
+		var err error
+		world, err = update(sys, world)
+		if err != nil {
+			stack := errors.New(err).ErrorStack()
+			logger.Printf("tick %d: %s system failed: %s\n", world.Tick, sys.Name(), stack)
 		}
 	}
+
+	return world
 }
 
-// Process requests against the game world.
-func (s Server) Process() {
-	logger, closeLog := logging.Logger("server.Server.Process")
+// resolve journals and performs the queued request against the world, sending
+// the response to everyone involved.
+func (s Server) resolve(world entities.World, q queuedRequest) entities.World {
+	logger, closeLog := logging.Logger("server.Server.resolve")
 	defer closeLog()
 
-	logger.Println("loading game world")
+	logger.Printf("tick %d: processing request: %s\n", world.Tick, reflect.TypeOf(q.Request))
+
+	if s.journal != nil {
+		entry := journal.Entry{
+			Tick:    world.Tick,
+			Time:    time.Now().UTC(),
+			Session: q.Origin.Account,
+			RNG:     *world.RNG,
+			Request: q.Request,
+		}
 
-	gameData, err := ioutil.ReadFile("game.save")
+		err := s.journal.Append(entry)
+		if err != nil {
+			stack := errors.New(err).ErrorStack()
+			logger.Printf("failed to journal request: %s\n", stack)
+		}
+	}
+
+	world, resp, err := perform(q.Request, world)
 	if err != nil {
 		stack := errors.New(err).ErrorStack()
 		logger.Printf("%s\n", stack)
-		panic(stack)
+		resp = requests.NewErrorResponse(q.Request, err)
+	}
+
+	ids := resp.IDs()
+	for _, id := range ids {
+		<-s.Emitter.Emit(id.String(), resp, q.Origin)
 	}
 
-	world := entities.MakeWorld()
-	err = json.Unmarshal(gameData, &world)
+	return world
+}
+
+// save writes the world to the server's store, returning false if it could
+// not be saved. A failed save is logged rather than stopping the game; the
+// previous save is left untouched. Once saved, a new segment of the journal is
+// started, so that the journal holds only the requests since the save.
+func (s Server) save(world entities.World) bool {
+	logger, closeLog := logging.Logger("server.Server.save")
+	defer closeLog()
+
+	err := s.Store.Save(world)
+	if err != nil {
+		stack := errors.Wrap(err, 0).ErrorStack()
+		logger.Printf("failed to save game world: %s\n", stack)
+		return false
+	}
+
+	if s.journal != nil {
+		err = s.journal.Rotate(world.Tick, *world.RNG)
+		if err != nil {
+			stack := errors.Wrap(err, 0).ErrorStack()
+			logger.Printf("failed to start a new journal segment: %s\n", stack)
+		}
+	}
+
+	return true
+}
+
+// snapshot takes a numbered snapshot of the world.
+func (s Server) snapshot(world entities.World) {
+	logger, closeLog := logging.Logger("server.Server.snapshot")
+	defer closeLog()
+
+	snapshot, err := s.Store.Snapshot(world)
+	if err != nil {
+		stack := errors.Wrap(err, 0).ErrorStack()
+		logger.Printf("failed to snapshot game world: %s\n", stack)
+		return
+	}
+
+	logger.Printf("snapshot %d taken at tick %d\n", snapshot.Number, world.Tick)
+}
+
+// syncView sends the actor any changes to its view since it was last synced.
+func (s Server) syncView(v *views, world entities.World, id uuid.UUID) {
+	logger, closeLog := logging.Logger("server.Server.syncView")
+	defer closeLog()
+
+	resp, err := v.Sync(world, id)
 	if err != nil {
 		stack := errors.New(err).ErrorStack()
 		logger.Printf("%s\n", stack)
-		panic(stack)
+		return
+	}
+	if resp == nil {
+		return
 	}
 
-	logger.Println("game world loaded")
-	logger.Println("await requests to process")
+	logger.Println("sending view changes to:", id.String())
+	<-s.Emitter.Emit(id.String(), resp, origin{})
+}
 
-	for event := range s.Emitter.On("request") {
-		if len(event.Args) == 1 {
-
-			var (
-				resp responses.Response
-				err  error
-			)
-			req := event.Args[0].(requests.Request)
-			logger.Println("processing request:", reflect.TypeOf(req))
-			world, resp, err = req.Execute(world)
-			if err != nil {
-				stack := errors.New(err).ErrorStack()
-				logger.Printf("%s\n", stack)
-				panic(stack)
-				// close connections?
-			}
-			ids := resp.IDs()
-			for _, id := range ids {
-				<-s.Emitter.Emit(id.String(), resp)
-			}
+// perform executes the request against the world. Successful requests spend
+// the actor's energy in turn-based worlds, or delay its timer in real-time
+// worlds. Actions of actors which do not take turns are refused in turn-based
+// worlds, as they would otherwise cost nothing.
+func perform(req requests.Request, world entities.World) (entities.World, responses.Response, error) {
+	if world.TurnBased() && requests.EnergyCost(req) > 0 {
+		actor, ok := world.Objects.FromID(req.Actor())
+		if ok && !requests.Scheduled(actor) {
+			return world, nil, errors.New(requests.Error{
+				Code:    responses.NotReadyError,
+				Message: fmt.Sprintf("actor %s does not take turns", req.Actor()),
+			})
+		}
+	}
+
+	world, resp, err := execute(req, world)
+	if err != nil {
+		return world, resp, err
+	}
+
+	return requests.Spend(world, req.Actor(), requests.SpentEnergy(req, resp)), resp, nil
+}
+
+// execute performs the request, recording the changes it makes to the world.
+// The changes are only kept if the request succeeds: should it fail or panic,
+// they are rolled back and the original world is returned along with the
+// error, so a single bad request cannot take down the server or leave the
+// world half changed. Panics are returned as an InternalError.
+func execute(req requests.Request, world entities.World) (w entities.World, resp responses.Response, err error) {
+	tx := world.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			w = tx.Rollback()
+			resp = nil
+			err = errors.New(requests.Error{
+				Code:    responses.InternalError,
+				Message: fmt.Sprintf("request could not be performed: %v", r),
+			})
 		}
+	}()
+
+	w, resp, err = req.Execute(requests.NewContext(world), world)
+	if err != nil {
+		return tx.Rollback(), resp, err
 	}
+
+	tx.Commit()
+	return w, resp, nil
+}
+
+// update runs the system, recording the changes it makes to the world. Should
+// the system fail or panic, the changes are rolled back and the original world
+// is returned along with the error, so that a broken system cannot take down
+// the server or leave the world half changed.
+func update(sys systems.System, world entities.World) (w entities.World, err error) {
+	tx := world.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			w = tx.Rollback()
+			err = errors.Errorf("system panicked: %v", r)
+		}
+	}()
+
+	w, err = sys.Update(world)
+	if err != nil {
+		return tx.Rollback(), err
+	}
+
+	tx.Commit()
+	return w, nil
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/entities"
+)
+
+// maxTurnsPerTick is the most ticks a turn-based world may advance by in a
+// single tick of the server, so that players with little speed cannot stall
+// the server while the world catches up.
+const maxTurnsPerTick = 100
+
+// takeTurns advances a turn-based world for as long as no connected player is
+// able to act. Queued requests are resolved once their actor has enough
+// energy, and free requests are resolved immediately, as are the requests of
+// actors which do not take turns, which are refused. The world waits once a
+// connected player may act but has not yet submitted an action. While no
+// connected player takes turns, the world advances a single turn per tick of
+// the server, so that everyone else keeps acting.
+func (s Server) takeTurns(world entities.World, queue *actionQueue, players []uuid.UUID) entities.World {
+	ready := func(req requests.Request) bool {
+		if requests.EnergyCost(req) == 0 {
+			return true
+		}
+
+		actor, ok := world.Objects.FromID(req.Actor())
+		if !ok || !requests.Scheduled(actor) {
+			return true
+		}
+
+		return requests.CanAct(world, actor)
+	}
+
+	for turn := 0; turn < maxTurnsPerTick; turn++ {
+		for next := queue.Next(world.Tick, ready); len(next) > 0; next = queue.Next(world.Tick, ready) {
+			for _, q := range next {
+				world = s.resolve(world, q)
+			}
+		}
+
+		scheduled := false
+		for _, id := range players {
+			actor, ok := world.Objects.FromID(id)
+			if !ok || !requests.Scheduled(actor) {
+				continue
+			}
+			scheduled = true
+
+			if requests.CanAct(world, actor) && !queue.Queued(id) {
+				return world
+			}
+		}
+		if !scheduled {
+			return s.advance(world)
+		}
+
+		world = s.advance(world)
+	}
+
+	return world
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+func TestTakeTurns(t *testing.T) {
+	s := NewServer("localhost", 0)
+	s.Systems = nil
+
+	tests := []struct {
+		name      string
+		energy    *objects.Energy
+		connected bool
+		queued    bool
+		tick      uint64
+		y         int
+	}{
+		{
+			"a ready player is waited for",
+			&objects.Energy{Speed: 100, Points: 100}, true, false,
+			0, 0,
+		},
+		{
+			"a ready player acts, then is waited for once ready again",
+			&objects.Energy{Speed: 100, Points: 100}, true, true,
+			1, 1,
+		},
+		{
+			"a slow player's action waits for their energy",
+			&objects.Energy{Speed: 25}, true, true,
+			8, 1,
+		},
+		{
+			"a player without energy cannot act, and the world advances a turn",
+			nil, true, true,
+			1, 0,
+		},
+		{
+			"a player without speed cannot act, and the world advances a turn",
+			&objects.Energy{Points: 100}, true, true,
+			1, 0,
+		},
+		{
+			"the world advances a turn while nobody is connected",
+			&objects.Energy{Speed: 100, Points: 100}, false, false,
+			1, 0,
+		},
+	}
+
+	for _, test := range tests {
+		world := entities.MakeWorld()
+		world.Mode = entities.TurnBasedMode
+
+		player := objects.New()
+		player.Set(objects.Position{X: 0, Y: 0})
+		if test.energy != nil {
+			player.Set(*test.energy)
+		}
+		world.Objects = world.Objects.Append(*player)
+
+		npc := objects.New()
+		npc.Set(objects.Position{X: 5, Y: 5})
+		npc.Set(objects.Energy{Speed: 10})
+		world.Objects = world.Objects.Append(*npc)
+
+		queue := newActionQueue()
+		if test.queued {
+			queue.Push(requests.MoveRequest{ActorID: player.ID, Direction: requests.South}, origin{})
+		}
+		var players []uuid.UUID
+		if test.connected {
+			players = append(players, player.ID)
+		}
+
+		world = s.takeTurns(world, queue, players)
+
+		if world.Tick != test.tick {
+			t.Errorf("%s: world is at tick %d, want %d", test.name, world.Tick, test.tick)
+		}
+		if queue.Queued(player.ID) {
+			t.Errorf("%s: the player's request is still queued", test.name)
+		}
+
+		actor, _ := world.Objects.FromID(player.ID)
+		if pos, _ := actor.Position(); pos.Y != test.y {
+			t.Errorf("%s: player is at y %d, want %d", test.name, pos.Y, test.y)
+		}
+
+		other, _ := world.Objects.FromID(npc.ID)
+		if energy, _ := other.Energy(); energy.Points != 10*int(test.tick) {
+			t.Errorf("%s: npc has %d energy, want %d", test.name, energy.Points, 10*test.tick)
+		}
+	}
+}
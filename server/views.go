@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// Internal topics, emitted with the actor's UUID whenever a connection starts
+// or stops receiving responses for that actor.
+const (
+	subscribeTopic   = "view.subscribe"
+	unsubscribeTopic = "view.unsubscribe"
+)
+
+// views tracks what each subscribed actor was last sent, so that only the
+// changes to their view need to be sent to them.
+type views struct {
+	subscriptions map[uuid.UUID]int
+	seen          map[uuid.UUID]map[uuid.UUID]objects.Entity
+}
+
+func newViews() *views {
+	return &views{
+		subscriptions: make(map[uuid.UUID]int),
+		seen:          make(map[uuid.UUID]map[uuid.UUID]objects.Entity),
+	}
+}
+
+// Subscribe starts tracking the actor's view. As a new connection knows
+// nothing of the world, the actor's view is reset so the next sync sends
+// everything in view.
+func (v *views) Subscribe(id uuid.UUID) {
+	v.subscriptions[id]++
+	v.seen[id] = make(map[uuid.UUID]objects.Entity)
+}
+
+// Unsubscribe stops tracking the actor's view once it has no subscribed
+// connections left.
+func (v *views) Unsubscribe(id uuid.UUID) {
+	v.subscriptions[id]--
+	if v.subscriptions[id] <= 0 {
+		delete(v.subscriptions, id)
+		delete(v.seen, id)
+	}
+}
+
+// Subscribers returns the IDs of all actors with a subscribed connection.
+func (v *views) Subscribers() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(v.subscriptions))
+	for id := range v.subscriptions {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// Sync compares what the actor can currently see against what it was last
+// sent, returning a response describing the entities which entered, changed
+// or left its view. A nil response is returned when nothing has changed.
+func (v *views) Sync(world entities.World, id uuid.UUID) (responses.Response, error) {
+	seen, ok := v.seen[id]
+	if !ok {
+		return nil, nil
+	}
+
+	visible := make(map[uuid.UUID]objects.Entity)
+	if actor, ok := world.Objects.FromID(id); ok {
+		for _, e := range requests.Perceive(world, actor) {
+			visible[e.ID] = e
+		}
+	}
+
+	// Responses are sorted by entity ID, so that the same changes are always
+	// sent in the same order.
+	resps := make([]responses.Response, 0)
+	for _, entityID := range sortedIDs(visible) {
+		e := visible[entityID]
+		before, ok := seen[entityID]
+		if !ok {
+			resps = append(resps, responses.EntityEnteredView{
+				ActorID: id,
+				Entity:  e,
+				Static:  e.Static(),
+			})
+			continue
+		}
+		if reflect.DeepEqual(before, e) {
+			continue
+		}
+
+		diff, err := objects.MakeDiff(before, e)
+		if err != nil {
+			return nil, err
+		}
+		if !diff.Empty() {
+			resps = append(resps, responses.EntityChanged{
+				ActorID:  id,
+				EntityID: entityID,
+				Diff:     diff,
+			})
+		}
+	}
+	// Static entities which still exist are remembered by the actor once out
+	// of view, so the actor is told whether to keep them.
+	for _, entityID := range sortedIDs(seen) {
+		if _, ok := visible[entityID]; !ok {
+			e, exists := world.Objects.FromID(entityID)
+			resps = append(resps, responses.EntityLeftView{
+				ActorID:  id,
+				EntityID: entityID,
+				Static:   exists && e.Static(),
+			})
+		}
+	}
+
+	v.seen[id] = visible
+
+	switch len(resps) {
+	case 0:
+		return nil, nil
+	case 1:
+		return resps[0], nil
+	default:
+		return responses.MakeWrapper(resps...), nil
+	}
+}
+
+// sortedIDs returns the IDs of the entities in ascending order.
+func sortedIDs(byID map[uuid.UUID]objects.Entity) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0
+	})
+
+	return ids
+}
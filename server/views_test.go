@@ -0,0 +1,180 @@
+package server
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/responses"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// An entity entering, changing and leaving the actor's view is synced to the
+// client as it happens, leaving the client's world matching what it can see.
+func TestViewsSync(t *testing.T) {
+	world := entities.MakeWorld()
+
+	actor := objects.New()
+	actor.Set(objects.Position{X: 0, Y: 0})
+	world.Objects = world.Objects.Append(*actor)
+
+	orc := objects.New()
+	orc.Set(objects.Position{X: 20, Y: 0})
+	orc.Set(objects.Health{Current: 5, Max: 5})
+	world.Objects = world.Objects.Append(*orc)
+
+	v := newViews()
+	v.Subscribe(actor.ID)
+	client := entities.MakeWorld()
+
+	// move places the orc, or detaches its health when hurt is true.
+	move := func(x int, hurt bool) func() {
+		return func() {
+			e, _ := world.Objects.FromID(orc.ID)
+			e.Set(objects.Position{X: x, Y: 0})
+			if hurt {
+				e.Remove(objects.HealthComponent)
+			}
+			world.Objects = world.Objects.MustUpdate(e)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		act   func()
+		resps []string
+		seen  bool
+	}{
+		{"only the actor is in view", func() {}, []string{"EntityEnteredView"}, false},
+		{"nothing changed", func() {}, nil, false},
+		{"the orc entered the view", move(2, false), []string{"EntityEnteredView"}, true},
+		{"the orc moved", move(1, false), []string{"EntityChanged"}, true},
+		{"the orc lost its health", move(1, true), []string{"EntityChanged"}, true},
+		{"the orc left the view", move(20, false), []string{"EntityLeftView"}, false},
+	}
+
+	for _, test := range tests {
+		test.act()
+
+		resp, err := v.Sync(world, actor.ID)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		var names []string
+		if wrapper, ok := resp.(responses.Wrapper); ok {
+			for _, r := range wrapper.Responses {
+				name, _ := responses.NameOf(r)
+				names = append(names, name)
+			}
+		} else if resp != nil {
+			name, _ := responses.NameOf(resp)
+			names = append(names, name)
+		}
+		if !reflect.DeepEqual(names, test.resps) {
+			t.Errorf("%s: synced %v, want %v", test.name, names, test.resps)
+		}
+
+		if resp != nil {
+			// Responses reach the client over the wire.
+			bites, err := responses.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = responses.Unmarshal(bites)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client, err = resp.Apply(client)
+			if err != nil {
+				t.Fatalf("%s: applying: %s", test.name, err)
+			}
+		}
+
+		seen, ok := client.Objects.FromID(orc.ID)
+		if ok != test.seen {
+			t.Errorf("%s: client sees the orc = %t, want %t", test.name, ok, test.seen)
+		}
+		if e, _ := world.Objects.FromID(orc.ID); ok && !reflect.DeepEqual(seen, e) {
+			t.Errorf("%s: client sees the orc as %v, want %v", test.name, seen.Fields(), e.Fields())
+		}
+	}
+}
+
+// Entities entering, changing and leaving the view together are synced in
+// order of their IDs, however they are stored.
+func TestViewsSyncOrder(t *testing.T) {
+	world := entities.MakeWorld()
+
+	actor := objects.New()
+	actor.Set(objects.Position{X: 0, Y: 0})
+	world.Objects = world.Objects.Append(*actor)
+
+	v := newViews()
+	v.Subscribe(actor.ID)
+	_, err := v.Sync(world, actor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orcs := make([]uuid.UUID, 0)
+	for i := 0; i < 10; i++ {
+		orc := objects.New()
+		orc.Set(objects.Position{X: 1 + i%3, Y: i / 3})
+		world.Objects = world.Objects.Prepend(*orc)
+		orcs = append(orcs, orc.ID)
+	}
+
+	// move places every orc in the column.
+	move := func(x int) func() {
+		return func() {
+			for _, id := range orcs {
+				e, _ := world.Objects.FromID(id)
+				pos, _ := e.Position()
+				e.Set(objects.Position{X: x, Y: pos.Y})
+				world.Objects = world.Objects.MustUpdate(e)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		act  func()
+	}{
+		{"the orcs entered the view", func() {}},
+		{"the orcs moved", move(4)},
+		{"the orcs left the view", move(20)},
+	}
+
+	for _, test := range tests {
+		test.act()
+
+		resp, err := v.Sync(world, actor.ID)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		wrapper, ok := resp.(responses.Wrapper)
+		if !ok {
+			t.Fatalf("%s: synced %T, want a Wrapper", test.name, resp)
+		}
+
+		ids := make([]string, 0, len(wrapper.Responses))
+		for _, r := range wrapper.Responses {
+			switch r := r.(type) {
+			case responses.EntityEnteredView:
+				ids = append(ids, r.Entity.ID.String())
+			case responses.EntityChanged:
+				ids = append(ids, r.EntityID.String())
+			case responses.EntityLeftView:
+				ids = append(ids, r.EntityID.String())
+			}
+		}
+		if len(ids) != len(orcs) || !sort.StringsAreSorted(ids) {
+			t.Errorf("%s: synced %v, want the %d orcs in order", test.name, ids, len(orcs))
+		}
+	}
+}
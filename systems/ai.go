@@ -0,0 +1,131 @@
+package systems
+
+import (
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// DefaultWanderChance is the percentage chance of an idle entity wandering
+// each time it is able to act, unless configured otherwise.
+const DefaultWanderChance = 25
+
+// AI is a system which acts for entities with an AI component, whenever they
+// are able to act. Hostile entities move towards the nearest living entity in
+// sight which is not controlled by AI, attacking it once they bump into it.
+// Otherwise, entities wander in a random direction WanderChance percent of
+// the time, and rest the remainder.
+//
+// Entities act as players do: moving costs energy in turn-based worlds, and
+// delays their timer in real-time worlds. Resting or failing to move costs the
+// same, so that idle entities do not build up energy.
+type AI struct {
+	WanderChance int
+}
+
+// Name returns the name of the system.
+func (sys AI) Name() string {
+	return "ai"
+}
+
+// Update has every entity with an AI component which is able to act do so.
+func (sys AI) Update(world entities.World) (entities.World, error) {
+	for _, e := range world.Objects.With(objects.AIComponent, objects.PositionComponent) {
+		// Entities may have been killed, or moved, by those acting before.
+		actor, ok := world.Objects.FromID(e.ID)
+		if !ok || !requests.CanAct(world, actor) {
+			continue
+		}
+
+		ctx := requests.NewContext(world)
+
+		cost := requests.MoveCost
+		direction, ok := sys.decide(ctx, world, actor)
+		if ok {
+			req := requests.MoveRequest{ActorID: actor.ID, Direction: direction}
+			next, resp, err := req.Execute(ctx, world)
+			if err == nil {
+				world = next
+				cost = requests.SpentEnergy(req, resp)
+			}
+		}
+
+		world = requests.Spend(world, actor.ID, cost)
+	}
+
+	return world, nil
+}
+
+// decide returns the direction the actor moves in, or false if it rests.
+func (sys AI) decide(ctx requests.Context, world entities.World, actor objects.Entity) (requests.Direction, bool) {
+	ai, _ := actor.Get(objects.AIComponent)
+	if ai.(objects.AI).Hostile {
+		if target, ok := nearestTarget(world, actor); ok {
+			pos, _ := actor.Position()
+			return requests.DirectionOf(sign(target.X-pos.X), sign(target.Y-pos.Y))
+		}
+	}
+
+	if ctx.RNG.Intn(100) >= sys.WanderChance {
+		return requests.North, false
+	}
+
+	return requests.Direction(ctx.RNG.Intn(int(requests.NorthWest) + 1)), true
+}
+
+// nearestTarget returns the position of the nearest living entity the actor
+// can see, which is not controlled by AI.
+func nearestTarget(world entities.World, actor objects.Entity) (objects.Position, bool) {
+	pos, _ := actor.Position()
+
+	var nearest objects.Position
+	found := false
+	for _, e := range requests.Perceive(world, actor) {
+		if uuid.Equal(e.ID, actor.ID) || e.Has(objects.AIComponent) || !e.Has(objects.HealthComponent, objects.EnergyComponent) {
+			continue
+		}
+
+		target, ok := e.Position()
+		if !ok {
+			continue
+		}
+		if !found || distance(pos, target) < distance(pos, nearest) {
+			nearest = target
+			found = true
+		}
+	}
+
+	return nearest, found
+}
+
+// distance returns how many steps apart the positions are, moving diagonally
+// where possible.
+func distance(a, b objects.Position) int {
+	dx, dy := abs(a.X-b.X), abs(a.Y-b.Y)
+	if dx > dy {
+		return dx
+	}
+
+	return dy
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,61 @@
+package systems
+
+import (
+	"github.com/clagraff/pitch/comms/requests"
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// Effects is a system which applies the effects active on entities, such as
+// poison, once every tick, and removes them once they wear off. Effects cannot
+// heal entities beyond their maximum health, and entities whose health runs
+// out are killed.
+type Effects struct{}
+
+// Name returns the name of the system.
+func (sys Effects) Name() string {
+	return "effects"
+}
+
+// Update applies every active effect, and removes those with no ticks
+// remaining.
+func (sys Effects) Update(world entities.World) (entities.World, error) {
+	for _, e := range world.Objects.With(objects.EffectsComponent) {
+		c, _ := e.Get(objects.EffectsComponent)
+		effects := c.(objects.Effects)
+
+		health, hasHealth := e.Health()
+
+		active := make([]objects.Effect, 0, len(effects.Active))
+		for _, effect := range effects.Active {
+			if hasHealth {
+				health.Current += effect.Health
+			}
+
+			effect.Remaining--
+			if effect.Remaining > 0 {
+				active = append(active, effect)
+			}
+		}
+
+		if hasHealth {
+			if health.Current <= 0 {
+				world = requests.Kill(world, e)
+				continue
+			}
+			if health.Current > health.Max {
+				health.Current = health.Max
+			}
+			e.Set(health)
+		}
+
+		if len(active) == 0 {
+			e.Remove(objects.EffectsComponent)
+		} else {
+			e.Set(objects.Effects{Active: active})
+		}
+		world.Objects = world.Objects.MustUpdate(e)
+	}
+
+	return world, nil
+}
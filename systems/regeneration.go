@@ -0,0 +1,44 @@
+package systems
+
+import (
+	"github.com/clagraff/pitch/entities"
+	"github.com/clagraff/pitch/entities/objects"
+)
+
+// DefaultRegenerationInterval is how many ticks pass between each time
+// entities regenerate health, unless configured otherwise.
+const DefaultRegenerationInterval = 50
+
+// Regeneration is a system which restores the health of living entities which
+// have been injured, every Interval ticks, up to their maximum health.
+type Regeneration struct {
+	Interval uint64
+	Amount   int
+}
+
+// Name returns the name of the system.
+func (sys Regeneration) Name() string {
+	return "regeneration"
+}
+
+// Update regenerates the health of injured entities when the current tick is
+// a multiple of the interval.
+func (sys Regeneration) Update(world entities.World) (entities.World, error) {
+	if sys.Interval == 0 || world.Tick%sys.Interval != 0 {
+		return world, nil
+	}
+
+	for _, e := range world.Objects.With(objects.HealthComponent) {
+		health, _ := e.Health()
+		if health.Current > 0 && health.Current < health.Max {
+			health.Current += sys.Amount
+			if health.Current > health.Max {
+				health.Current = health.Max
+			}
+			e.Set(health)
+			world.Objects = world.Objects.MustUpdate(e)
+		}
+	}
+
+	return world, nil
+}
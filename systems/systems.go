@@ -0,0 +1,30 @@
+// Package systems contains the world systems run by the server once every
+// tick, such as AI, regeneration and effects.
+package systems
+
+import (
+	"github.com/clagraff/pitch/entities"
+)
+
+// System is used to update the game world once every tick, independently of
+// any requests made by players.
+type System interface {
+	// Name returns a name for the system, used when logging.
+	Name() string
+	// Update returns the world as of the end of the current tick.
+	Update(entities.World) (entities.World, error)
+}
+
+// Defaults returns the systems run by a server unless configured otherwise.
+func Defaults() []System {
+	return []System{
+		AI{
+			WanderChance: DefaultWanderChance,
+		},
+		Effects{},
+		Regeneration{
+			Interval: DefaultRegenerationInterval,
+			Amount:   1,
+		},
+	}
+}
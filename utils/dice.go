@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+// Limits on dice expressions, so that a single expression cannot roll an
+// unbounded number of dice.
+const (
+	MaxDice       = 100
+	MaxSides      = 1000
+	MaxExplosions = 100
+)
+
+// RNG is a source of random numbers for rolling dice. *Source satisfies it,
+// so dice can be rolled against a seeded source to be reproducible.
+type RNG interface {
+	// Intn returns a random number in [0, n).
+	Intn(n int) int
+}
+
+// Dice describes a dice expression, such as 2d6+3. A pool of Count dice with
+// Sides sides is rolled, optionally exploding (rolling again on the highest
+// side) and keeping only the highest or lowest Keep dice. With advantage or
+// disadvantage the pool is rolled twice, and the higher or lower total used.
+// The modifier is added to the total.
+//
+// Dice with no Count are a constant, equal to the modifier.
+type Dice struct {
+	Count        int
+	Sides        int
+	Explode      bool
+	Keep         int
+	KeepLowest   bool
+	Advantage    bool
+	Disadvantage bool
+	Modifier     int
+}
+
+// DieRoll is a single die rolled as part of a dice expression. Dropped dice
+// are not counted towards the total.
+type DieRoll struct {
+	Value    int  `json:"value"`
+	Dropped  bool `json:"dropped,omitempty"`
+	Exploded bool `json:"exploded,omitempty"`
+}
+
+// DiceResult is the outcome of rolling dice: every die rolled, and the total.
+type DiceResult struct {
+	Dice     string    `json:"dice"`
+	Rolls    []DieRoll `json:"rolls"`
+	Modifier int       `json:"modifier"`
+	Total    int       `json:"total"`
+}
+
+// ParseDice parses a dice expression. Expressions are made up of the number
+// of dice, the number of sides and then any of the following, in order:
+//
+//	!      exploding dice, as in 3d6!
+//	khN    keep the highest N dice, as in 4d6kh3; k is short for kh
+//	klN    keep the lowest N dice, as in 4d6kl1
+//	adv    advantage, as in 1d20adv
+//	dis    disadvantage, as in 1d20dis
+//	+N -N  a modifier, as in 2d6+3
+//
+// The number of dice defaults to one, and d% is short for d100. A bare number
+// is a constant.
+func ParseDice(expr string) (Dice, error) {
+	p := diceParser{expr: strings.ToLower(strings.Replace(expr, " ", "", -1))}
+	if p.expr == "" {
+		return Dice{}, errors.New("empty dice expression")
+	}
+
+	d := Dice{}
+
+	count, hasCount := p.number()
+	if !p.consume("d") {
+		if !hasCount {
+			return Dice{}, p.errorf("expected a number of dice")
+		}
+		d.Modifier = count
+	} else {
+		d.Count = 1
+		if hasCount {
+			if count == 0 {
+				return Dice{}, p.errorf("expected at least one die")
+			}
+			d.Count = count
+		}
+
+		if p.consume("%") {
+			d.Sides = 100
+		} else if sides, ok := p.number(); ok {
+			d.Sides = sides
+		} else {
+			return Dice{}, p.errorf("expected a number of sides")
+		}
+
+		d.Explode = p.consume("!")
+
+		if p.consume("kl") {
+			d.KeepLowest = true
+			if d.Keep, _ = p.number(); d.Keep == 0 {
+				return Dice{}, p.errorf("expected a number of dice to keep")
+			}
+		} else if p.consume("kh") || p.consume("k") {
+			if d.Keep, _ = p.number(); d.Keep == 0 {
+				return Dice{}, p.errorf("expected a number of dice to keep")
+			}
+		}
+
+		if p.consume("adv") {
+			d.Advantage = true
+		} else if p.consume("dis") {
+			d.Disadvantage = true
+		}
+	}
+
+	for !p.done() {
+		sign := 1
+		if p.consume("-") {
+			sign = -1
+		} else if !p.consume("+") {
+			return Dice{}, p.errorf("unexpected %q", p.rest())
+		}
+
+		n, ok := p.number()
+		if !ok {
+			return Dice{}, p.errorf("expected a modifier")
+		}
+		d.Modifier += sign * n
+	}
+
+	return d, d.Validate()
+}
+
+// MustParseDice is like ParseDice, but panics if the expression is invalid.
+func MustParseDice(expr string) Dice {
+	d, err := ParseDice(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// Validate returns an error if the dice cannot be rolled.
+func (d Dice) Validate() error {
+	if d.Count == 0 {
+		return nil
+	}
+	if d.Count < 0 || d.Count > MaxDice {
+		return errors.Errorf("number of dice must be between 1 and %d", MaxDice)
+	}
+	if d.Sides < 1 || d.Sides > MaxSides {
+		return errors.Errorf("number of sides must be between 1 and %d", MaxSides)
+	}
+	if d.Explode && d.Sides < 2 {
+		return errors.New("exploding dice must have at least 2 sides")
+	}
+	if d.Keep < 0 || d.Keep > d.Count {
+		return errors.Errorf("cannot keep %d of %d dice", d.Keep, d.Count)
+	}
+	if d.Advantage && d.Disadvantage {
+		return errors.New("dice cannot have both advantage and disadvantage")
+	}
+
+	return nil
+}
+
+// String returns the dice as an expression, which can be parsed by ParseDice.
+func (d Dice) String() string {
+	if d.Count == 0 {
+		return strconv.Itoa(d.Modifier)
+	}
+
+	s := strconv.Itoa(d.Count) + "d" + strconv.Itoa(d.Sides)
+	if d.Explode {
+		s += "!"
+	}
+	if d.Keep > 0 {
+		if d.KeepLowest {
+			s += "kl"
+		} else {
+			s += "kh"
+		}
+		s += strconv.Itoa(d.Keep)
+	}
+	if d.Advantage {
+		s += "adv"
+	} else if d.Disadvantage {
+		s += "dis"
+	}
+	if d.Modifier > 0 {
+		s += "+" + strconv.Itoa(d.Modifier)
+	} else if d.Modifier < 0 {
+		s += strconv.Itoa(d.Modifier)
+	}
+
+	return s
+}
+
+// MarshalText marshals the dice as an expression.
+func (d Dice) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText unmarshals the dice from an expression.
+func (d *Dice) UnmarshalText(text []byte) error {
+	parsed, err := ParseDice(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// Roll rolls the dice against the random number source.
+func (d Dice) Roll(rng RNG) DiceResult {
+	result := DiceResult{
+		Dice:     d.String(),
+		Rolls:    make([]DieRoll, 0, d.Count),
+		Modifier: d.Modifier,
+	}
+
+	rolls, total := d.pool(rng)
+	if d.Advantage || d.Disadvantage {
+		others, otherTotal := d.pool(rng)
+		if (d.Advantage && otherTotal > total) || (d.Disadvantage && otherTotal < total) {
+			rolls, others = others, rolls
+			total = otherTotal
+		}
+		for i := range others {
+			others[i].Dropped = true
+		}
+		rolls = append(rolls, others...)
+	}
+
+	result.Rolls = append(result.Rolls, rolls...)
+	result.Total = total + d.Modifier
+
+	return result
+}
+
+// pool rolls a single pool of the dice, returning each die and the total of
+// those kept. A die's explosions count towards it when keeping the highest or
+// lowest dice, and are kept or dropped along with it.
+func (d Dice) pool(rng RNG) ([]DieRoll, int) {
+	rolls := make([]DieRoll, 0, d.Count)
+	starts := make([]int, 0, d.Count)
+	totals := make([]int, 0, d.Count)
+	for i := 0; i < d.Count; i++ {
+		starts = append(starts, len(rolls))
+
+		value := 1 + rng.Intn(d.Sides)
+		rolls = append(rolls, DieRoll{Value: value})
+		total := value
+
+		for explosions := 0; d.Explode && value == d.Sides && explosions < MaxExplosions; explosions++ {
+			value = 1 + rng.Intn(d.Sides)
+			rolls = append(rolls, DieRoll{Value: value, Exploded: true})
+			total += value
+		}
+		totals = append(totals, total)
+	}
+	starts = append(starts, len(rolls))
+
+	if d.Keep > 0 && d.Keep < d.Count {
+		order := make([]int, d.Count)
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			if d.KeepLowest {
+				return totals[order[i]] < totals[order[j]]
+			}
+			return totals[order[i]] > totals[order[j]]
+		})
+		for _, die := range order[d.Keep:] {
+			for i := starts[die]; i < starts[die+1]; i++ {
+				rolls[i].Dropped = true
+			}
+		}
+	}
+
+	total := 0
+	for _, r := range rolls {
+		if !r.Dropped {
+			total += r.Value
+		}
+	}
+
+	return rolls, total
+}
+
+// diceParser reads a dice expression from left to right.
+type diceParser struct {
+	expr string
+	pos  int
+}
+
+func (p *diceParser) done() bool {
+	return p.pos >= len(p.expr)
+}
+
+func (p *diceParser) rest() string {
+	return p.expr[p.pos:]
+}
+
+// consume moves past the token, returning false if it is not next.
+func (p *diceParser) consume(token string) bool {
+	if !strings.HasPrefix(p.rest(), token) {
+		return false
+	}
+
+	p.pos += len(token)
+	return true
+}
+
+// number reads the next number, returning false if there is none.
+func (p *diceParser) number() (int, bool) {
+	end := p.pos
+	for end < len(p.expr) && p.expr[end] >= '0' && p.expr[end] <= '9' {
+		end++
+	}
+	if end == p.pos {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(p.expr[p.pos:end])
+	if err != nil {
+		return 0, false
+	}
+
+	p.pos = end
+	return n, true
+}
+
+func (p *diceParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("invalid dice expression %q at %d: %s", p.expr, p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,159 @@
+package utils_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/clagraff/pitch/utils"
+)
+
+// sequence is an RNG which rolls the listed die values in order.
+type sequence []int
+
+func (s *sequence) Intn(n int) int {
+	value := (*s)[0]
+	*s = (*s)[1:]
+	return value - 1
+}
+
+func TestParseDice(t *testing.T) {
+	tests := []struct {
+		expr string
+		want utils.Dice
+	}{
+		{"5", utils.Dice{Modifier: 5}},
+		{"d6", utils.Dice{Count: 1, Sides: 6}},
+		{"2d6+3", utils.Dice{Count: 2, Sides: 6, Modifier: 3}},
+		{"2d6-1+4", utils.Dice{Count: 2, Sides: 6, Modifier: 3}},
+		{" 2D6 - 1 ", utils.Dice{Count: 2, Sides: 6, Modifier: -1}},
+		{"d%", utils.Dice{Count: 1, Sides: 100}},
+		{"3d6!", utils.Dice{Count: 3, Sides: 6, Explode: true}},
+		{"4d6kh3", utils.Dice{Count: 4, Sides: 6, Keep: 3}},
+		{"4d6k3", utils.Dice{Count: 4, Sides: 6, Keep: 3}},
+		{"4d6kl1", utils.Dice{Count: 4, Sides: 6, Keep: 1, KeepLowest: true}},
+		{"1d20adv", utils.Dice{Count: 1, Sides: 20, Advantage: true}},
+		{"1d20dis+2", utils.Dice{Count: 1, Sides: 20, Disadvantage: true, Modifier: 2}},
+		{"4d6!kh3adv+1", utils.Dice{Count: 4, Sides: 6, Explode: true, Keep: 3, Advantage: true, Modifier: 1}},
+	}
+
+	for _, test := range tests {
+		got, err := utils.ParseDice(test.expr)
+		if err != nil {
+			t.Errorf("ParseDice(%q) returned error: %s", test.expr, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseDice(%q) = %+v, want %+v", test.expr, got, test.want)
+		}
+
+		// Dice must survive being written back out as an expression.
+		again, err := utils.ParseDice(got.String())
+		if err != nil || again != got {
+			t.Errorf("ParseDice(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+		}
+	}
+}
+
+func TestParseDiceErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"d",
+		"dx",
+		"0d6",
+		"2d6+",
+		"2d6*2",
+		"4d6kh",
+		"4d6kl0",
+		"2d6kh3",
+		"1d20advdis",
+		"1d1!",
+		"101d6",
+		"1d1001",
+		"adv",
+	}
+
+	for _, expr := range tests {
+		d, err := utils.ParseDice(expr)
+		if err == nil {
+			t.Errorf("ParseDice(%q) = %+v, want an error", expr, d)
+		}
+	}
+}
+
+func TestDiceRoll(t *testing.T) {
+	tests := []struct {
+		expr  string
+		rolls sequence
+		want  int
+	}{
+		{"5", sequence{}, 5},
+		{"2d6+3", sequence{2, 5}, 10},
+		{"d%", sequence{42}, 42},
+		{"3d6!", sequence{6, 6, 2, 1, 3}, 18},
+		{"4d6kh3", sequence{1, 4, 6, 3}, 13},
+		{"4d6kl1", sequence{5, 4, 2, 3}, 2},
+		{"2d6!kh2", sequence{6, 4, 3}, 13},
+		{"2d6!kh1", sequence{6, 4, 3}, 10},
+		{"2d6!kl1", sequence{6, 4, 5}, 5},
+		{"1d20adv", sequence{7, 15}, 15},
+		{"1d20adv", sequence{15, 7}, 15},
+		{"1d20dis", sequence{7, 15}, 7},
+		{"1d20dis-1", sequence{15, 7}, 6},
+	}
+
+	for _, test := range tests {
+		rolls := append(sequence(nil), test.rolls...)
+		result := utils.MustParseDice(test.expr).Roll(&rolls)
+		if result.Total != test.want {
+			t.Errorf("rolling %s with %v = %d, want %d", test.expr, test.rolls, result.Total, test.want)
+		}
+		if len(rolls) != 0 {
+			t.Errorf("rolling %s with %v left %v unrolled", test.expr, test.rolls, rolls)
+		}
+	}
+}
+
+// describe lists the rolled dice, marking explosions with a + and putting
+// dropped dice in brackets.
+func describe(rolls []utils.DieRoll) string {
+	parts := make([]string, 0, len(rolls))
+	for _, r := range rolls {
+		part := strconv.Itoa(r.Value)
+		if r.Exploded {
+			part = "+" + part
+		}
+		if r.Dropped {
+			part = "(" + part + ")"
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func TestDiceRollKeepExploded(t *testing.T) {
+	tests := []struct {
+		expr  string
+		seed  int64
+		rolls string
+		want  int
+	}{
+		{"3d6!kh2", 1, "6 +2 (1) 6 +4", 18},
+		{"3d6!kh2", 6, "3 6 +1 (1)", 10},
+		{"3d6!kh2", 5, "(3) 5 6 +6 +2", 19},
+		{"3d6!kl1", 1, "(6) (+2) 1 (6) (+4)", 1},
+		{"3d6!kl1", 5, "3 (5) (6) (+6) (+2)", 3},
+		{"4d4!kh2", 0, "4 +1 (4) (+1) 4 +3 (2)", 12},
+	}
+
+	for _, test := range tests {
+		result := utils.MustParseDice(test.expr).Roll(utils.NewSource(test.seed))
+		if got := describe(result.Rolls); got != test.rolls {
+			t.Errorf("rolling %s with seed %d rolled %s, want %s", test.expr, test.seed, got, test.rolls)
+		}
+		if result.Total != test.want {
+			t.Errorf("rolling %s with seed %d = %d, want %d", test.expr, test.seed, result.Total, test.want)
+		}
+	}
+}
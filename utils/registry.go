@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-errors/errors"
+)
+
+// Registry maps names to factories for types chosen at runtime, such as
+// requests or components, and each type back to its name. Factories must
+// return pointers, but types are named by what they point to, so values and
+// pointers share the same name.
+//
+// Registry is safe for concurrent use.
+type Registry struct {
+	kind string
+
+	mu        sync.RWMutex
+	factories map[string]func() interface{}
+	names     map[reflect.Type]string
+}
+
+// NewRegistry returns an empty registry. The kind describes what is
+// registered, such as "request type", for use in errors.
+func NewRegistry(kind string) *Registry {
+	return &Registry{
+		kind:      kind,
+		factories: make(map[string]func() interface{}),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// Register makes the type returned by the factory available under the name.
+// An error is returned if the factory does not return a pointer, or if either
+// the name or the type has already been registered.
+func (r *Registry) Register(name string, factory func() interface{}) error {
+	if name == "" {
+		return errors.Errorf("%s name must not be empty", r.kind)
+	}
+
+	t := reflect.TypeOf(factory())
+	if t == nil || t.Kind() != reflect.Ptr {
+		return errors.Errorf("factory for %s %s must return a pointer", r.kind, name)
+	}
+	t = t.Elem()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		return errors.Errorf("%s %s is already registered", r.kind, name)
+	}
+	if existing, ok := r.names[t]; ok {
+		return errors.Errorf("%s %s is already registered as %s", r.kind, t, existing)
+	}
+
+	r.factories[name] = factory
+	r.names[t] = name
+
+	return nil
+}
+
+// Names returns the sorted names of every registered type.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NameOf returns the name the value's type was registered under, and whether
+// it has been registered at all.
+func (r *Registry) NameOf(v interface{}) (string, bool) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.names[t]
+	return name, ok
+}
+
+// New returns a pointer to a new, zero-valued instance of the named type, and
+// whether the name has been registered at all.
+func (r *Registry) New(name string) (interface{}, bool) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return factory(), true
+}
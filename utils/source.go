@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"encoding/json"
+)
+
+// Source is a seeded stream of random numbers whose position can be saved and
+// restored, so that a world rolls the same numbers each time it is replayed
+// from the same save. It uses the SplitMix64 algorithm.
+//
+// Source is not safe for concurrent use.
+type Source struct {
+	Seed  int64  `json:"seed"`
+	State uint64 `json:"state"`
+}
+
+// NewSource returns a source at the start of the stream for the seed.
+func NewSource(seed int64) *Source {
+	return &Source{Seed: seed, State: uint64(seed)}
+}
+
+// UnmarshalJSON unmarshals the source. A source saved with only a seed starts
+// at the beginning of its stream.
+func (s *Source) UnmarshalJSON(data []byte) error {
+	fields := struct {
+		Seed  int64   `json:"seed"`
+		State *uint64 `json:"state"`
+	}{}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	*s = *NewSource(fields.Seed)
+	if fields.State != nil {
+		s.State = *fields.State
+	}
+
+	return nil
+}
+
+// Uint64 returns the next number in the stream.
+func (s *Source) Uint64() uint64 {
+	s.State += 0x9e3779b97f4a7c15
+
+	z := s.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Intn returns a number in [0, n), without favouring any number in the range.
+// It panics if n is not positive.
+func (s *Source) Intn(n int) int {
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+
+	bound := uint64(n)
+	threshold := -bound % bound
+	for {
+		r := s.Uint64()
+		if r >= threshold {
+			return int(r % bound)
+		}
+	}
+}
+
+// Clone returns an independent copy of the source, at the same position.
+func (s *Source) Clone() *Source {
+	if s == nil {
+		return nil
+	}
+
+	clone := *s
+	return &clone
+}